@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// applyOptions configures the plan and apply commands.
+type applyOptions struct {
+	File     string
+	Provider string
+	Server   string
+	Zones    []string
+	TSIG     tsigConfig
+	API      apiConfig
+	Timeout  time.Duration
+	PlanOnly bool
+}
+
+// apiConfig holds the settings shared by the HTTP API providers.
+type apiConfig struct {
+	URL      string
+	Key      string
+	ServerID string
+}
+
+// runApply diffs the inventory against the records held by the selected provider and,
+// unless PlanOnly is set, pushes the changes to it.
+func runApply(opts applyOptions, out io.Writer) error {
+	provider, err := newProvider(opts)
+	if err != nil {
+		return err
+	}
+
+	_, root, err := loadInventory(opts.File)
+	if err != nil {
+		return err
+	}
+
+	records := collectRecords(root)
+
+	zones := opts.Zones
+	if len(zones) == 0 {
+		zones = recordZones(records)
+	}
+
+	for _, zone := range zones {
+		zone = canonicalZone(zone)
+
+		desired, err := desiredRecords(records, zone)
+		if err != nil {
+			return err
+		}
+
+		desired, skipped := supportedRecords(provider, desired)
+
+		current, err := provider.ListRecords(zone)
+		if err != nil {
+			return err
+		}
+
+		changes := diffRecords(desired, current)
+		printPlan(out, zone, changes)
+		for _, r := range skipped {
+			fmt.Fprintf(out, "  ! skipped %s: %s records are not supported by the %s provider\n", r, r.Type, opts.Provider)
+		}
+
+		if opts.PlanOnly || len(changes) == 0 {
+			continue
+		}
+
+		if err := applyChanges(provider, zone, changes); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "  applied %d change(s)\n", len(changes))
+	}
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+const applyFixture = `dns_records:
+  - host: web01
+    type: A
+    zone: example.lan.
+    record_value: 10.0.1.5
+  - host: web02
+    type: A
+    zone: example.lan.
+    record_value: 10.0.1.6 # DISABLED: unreachable
+`
+
+func TestRunApply_RequiresServer(t *testing.T) {
+	err := runApply(applyOptions{File: "zones.yaml"}, &bytes.Buffer{})
+	if err == nil || err.Error() != "--server is required" {
+		t.Fatalf("runApply error = %v, want --server is required", err)
+	}
+}
+
+func TestRunApply_PlanOnlyDoesNotModify(t *testing.T) {
+	fs := startFakeZoneServer(t, "example.lan.", tsigConfig{},
+		"web02.example.lan. 3600 IN A 10.0.1.6",
+	)
+
+	var out bytes.Buffer
+	err := runApply(applyOptions{
+		File:     writeInventory(t, applyFixture),
+		Server:   fs.addr,
+		Timeout:  2 * time.Second,
+		PlanOnly: true,
+	}, &out)
+	if err != nil {
+		t.Fatalf("runApply returned error: %v", err)
+	}
+
+	if fs.updates != 0 {
+		t.Fatalf("plan sent %d updates, want 0", fs.updates)
+	}
+	if !strings.Contains(out.String(), "Plan: 1 to add, 0 to change, 1 to destroy.") {
+		t.Fatalf("unexpected plan output:\n%s", out.String())
+	}
+}
+
+func TestRunApply_AppliesChanges(t *testing.T) {
+	tsig := tsigConfig{Name: "dnsctl", Secret: testTSIGSecret, Algorithm: "hmac-sha256"}
+	fs := startFakeZoneServer(t, "example.lan.", tsig,
+		"web02.example.lan. 3600 IN A 10.0.1.6",
+	)
+
+	var out bytes.Buffer
+	err := runApply(applyOptions{
+		File:    writeInventory(t, applyFixture),
+		Server:  fs.addr,
+		Zones:   []string{"example.lan"},
+		TSIG:    tsig,
+		Timeout: 2 * time.Second,
+	}, &out)
+	if err != nil {
+		t.Fatalf("runApply returned error: %v", err)
+	}
+
+	got := fs.snapshot()
+	if len(got) != 1 || got["web01.example.lan. A 10.0.1.5"] != 3600 {
+		t.Fatalf("zone after apply = %v, want only web01", got)
+	}
+
+	// A second run must be a no-op.
+	out.Reset()
+	if err := runApply(applyOptions{
+		File:    writeInventory(t, applyFixture),
+		Server:  fs.addr,
+		TSIG:    tsig,
+		Timeout: 2 * time.Second,
+	}, &out); err != nil {
+		t.Fatalf("second runApply returned error: %v", err)
+	}
+	if !strings.Contains(out.String(), "no changes") {
+		t.Fatalf("second apply output = %q, want no changes", out.String())
+	}
+}
@@ -0,0 +1,201 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// checkICMP identifies results produced by the ICMP echo probe.
+const checkICMP = "icmp"
+
+// cacheEntry is a single cached probe outcome.
+type cacheEntry struct {
+	Status  string    `json:"status"`
+	Checked time.Time `json:"checked"`
+}
+
+// probeSample is one past probe of a host, kept for the --interactive review.
+type probeSample struct {
+	Status  string        `json:"status"`
+	RTT     time.Duration `json:"rtt,omitempty"`
+	Checked time.Time     `json:"checked"`
+}
+
+const (
+	// historyLen is the number of past probes kept per host.
+	historyLen = 5
+	// historyAge is how long past probes are kept; unlike cached results they outlive the TTL.
+	historyAge = 30 * 24 * time.Hour
+)
+
+// probeCache is an on-disk store of probe results keyed by check type and IP, so that
+// consecutive runs over overlapping inventories do not probe the same hosts again.
+// Only definitive results (up or down) are stored.
+type probeCache struct {
+	path string
+	ttl  time.Duration
+	now  func() time.Time
+	// fresh ignores stored results while still recording new ones.
+	fresh   bool
+	Entries map[string]cacheEntry `json:"entries"`
+	// History holds the latest probes of each host, newest first, under the same keys.
+	History map[string][]probeSample `json:"history,omitempty"`
+}
+
+// probeCachePath returns the default cache location under the user cache directory.
+func probeCachePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "dnsctl", "probes.json"), nil
+}
+
+// loadProbeCache reads the cache at path. A missing or unreadable cache file is not an
+// error: the cache is disposable and starts out empty.
+func loadProbeCache(path string, ttl time.Duration) *probeCache {
+	c := &probeCache{path: path, ttl: ttl, now: time.Now, Entries: map[string]cacheEntry{}, History: map[string][]probeSample{}}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return c
+	}
+	if err := json.Unmarshal(data, c); err != nil || c.Entries == nil {
+		c.Entries = map[string]cacheEntry{}
+		c.History = map[string][]probeSample{}
+	}
+	if c.History == nil {
+		c.History = map[string][]probeSample{}
+	}
+	return c
+}
+
+// cacheKey identifies a result by check type and IP.
+func cacheKey(check, ip string) string {
+	return check + "|" + ip
+}
+
+// get returns the cached status of ip if it is younger than the TTL.
+func (c *probeCache) get(check, ip string) (probeStatus, bool) {
+	if c.fresh {
+		return probeUnknown, false
+	}
+
+	e, ok := c.Entries[cacheKey(check, ip)]
+	if !ok || c.now().Sub(e.Checked) > c.ttl {
+		return probeUnknown, false
+	}
+
+	switch e.Status {
+	case probeUp.String():
+		return probeUp, true
+	case probeDown.String():
+		return probeDown, true
+	}
+	return probeUnknown, false
+}
+
+// put records a definitive result; unknown results are ignored.
+func (c *probeCache) put(check, ip string, status probeStatus) {
+	if status == probeUnknown {
+		return
+	}
+	c.Entries[cacheKey(check, ip)] = cacheEntry{Status: status.String(), Checked: c.now()}
+}
+
+// record adds a definitive result to the history of ip, dropping the oldest beyond historyLen.
+func (c *probeCache) record(check, ip string, status probeStatus, rtt time.Duration) {
+	if status == probeUnknown {
+		return
+	}
+	key := cacheKey(check, ip)
+	samples := append([]probeSample{{Status: status.String(), RTT: rtt, Checked: c.now()}}, c.History[key]...)
+	c.History[key] = samples[:min(len(samples), historyLen)]
+}
+
+// history returns the past probes of ip, newest first.
+func (c *probeCache) history(check, ip string) []probeSample {
+	return c.History[cacheKey(check, ip)]
+}
+
+// save drops expired entries and writes the cache atomically, creating its directory if needed.
+func (c *probeCache) save() error {
+	for k, e := range c.Entries {
+		if c.now().Sub(e.Checked) > c.ttl {
+			delete(c.Entries, k)
+		}
+	}
+	for k, samples := range c.History {
+		samples = slices.DeleteFunc(samples, func(p probeSample) bool {
+			return c.now().Sub(p.Checked) > historyAge
+		})
+		if len(samples) == 0 {
+			delete(c.History, k)
+			continue
+		}
+		c.History[k] = samples
+	}
+
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), ".probes-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path)
+}
+
+// splitCached returns the results already known from the cache, in job order with
+// probeUnknown for the rest, and the jobs that still need probing.
+func splitCached(c *probeCache, check string, jobs []pingJob) ([]pingResult, []pingJob) {
+	results := make([]pingResult, len(jobs))
+	var pending []pingJob
+
+	for i, j := range jobs {
+		results[i] = pingResult{job: j, status: probeUnknown}
+		if status, ok := c.get(check, j.IP); ok {
+			results[i].status = status
+			continue
+		}
+		pending = append(pending, j)
+	}
+
+	return results, pending
+}
+
+// mergeProbed fills the unknown entries of results with fresh, which holds one result per
+// pending job in the order splitCached returned them, and stores the fresh results in c
+// and in its history.
+func mergeProbed(c *probeCache, check string, results, fresh []pingResult) {
+	next := 0
+	for i := range results {
+		if results[i].status != probeUnknown || next >= len(fresh) {
+			continue
+		}
+		results[i].status = fresh[next].status
+		results[i].rtt = fresh[next].rtt
+		results[i].err = fresh[next].err
+		c.put(check, fresh[next].job.IP, fresh[next].status)
+		c.record(check, fresh[next].job.IP, fresh[next].status, fresh[next].rtt)
+		next++
+	}
+}
@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestProbeCache_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "probes.json")
+
+	c := loadProbeCache(path, time.Minute)
+	c.put(checkICMP, "10.0.0.1", probeUp)
+	c.put(checkICMP, "10.0.0.2", probeDown)
+	c.put(checkICMP, "10.0.0.3", probeUnknown)
+	if err := c.save(); err != nil {
+		t.Fatalf("save returned error: %v", err)
+	}
+
+	c = loadProbeCache(path, time.Minute)
+	if s, ok := c.get(checkICMP, "10.0.0.1"); !ok || s != probeUp {
+		t.Errorf("get(10.0.0.1) = %s, %v; want up, true", s, ok)
+	}
+	if s, ok := c.get(checkICMP, "10.0.0.2"); !ok || s != probeDown {
+		t.Errorf("get(10.0.0.2) = %s, %v; want down, true", s, ok)
+	}
+	if _, ok := c.get(checkICMP, "10.0.0.3"); ok {
+		t.Errorf("unknown results must not be cached")
+	}
+	if _, ok := c.get("arp", "10.0.0.1"); ok {
+		t.Errorf("results are keyed by check type")
+	}
+}
+
+func TestProbeCache_TTL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "probes.json")
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	c := loadProbeCache(path, time.Minute)
+	c.now = func() time.Time { return now }
+	c.put(checkICMP, "10.0.0.1", probeUp)
+
+	now = now.Add(30 * time.Second)
+	if _, ok := c.get(checkICMP, "10.0.0.1"); !ok {
+		t.Fatalf("entry younger than the TTL should be reused")
+	}
+
+	now = now.Add(time.Minute)
+	if _, ok := c.get(checkICMP, "10.0.0.1"); ok {
+		t.Fatalf("entry older than the TTL should be ignored")
+	}
+
+	if err := c.save(); err != nil {
+		t.Fatalf("save returned error: %v", err)
+	}
+	if len(loadProbeCache(path, time.Minute).Entries) != 0 {
+		t.Fatalf("save should drop expired entries")
+	}
+}
+
+func TestLoadProbeCache_Corrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "probes.json")
+	if err := os.WriteFile(path, []byte("{not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := loadProbeCache(path, time.Minute)
+	if c.Entries == nil || len(c.Entries) != 0 {
+		t.Fatalf("corrupt cache should load empty, got %v", c.Entries)
+	}
+}
+
+func TestSplitCachedAndMerge(t *testing.T) {
+	c := loadProbeCache(filepath.Join(t.TempDir(), "probes.json"), time.Minute)
+	c.put(checkICMP, "10.0.0.2", probeDown)
+
+	jobs := []pingJob{{IP: "10.0.0.1"}, {IP: "10.0.0.2"}, {IP: "10.0.0.3"}}
+	results, pending := splitCached(c, checkICMP, jobs)
+
+	if len(pending) != 2 || pending[0].IP != "10.0.0.1" || pending[1].IP != "10.0.0.3" {
+		t.Fatalf("pending = %v, want 10.0.0.1 and 10.0.0.3", pending)
+	}
+
+	mergeProbed(c, checkICMP, results, []pingResult{
+		{job: pending[0], status: probeUp},
+		{job: pending[1], status: probeUnknown},
+	})
+
+	want := []probeStatus{probeUp, probeDown, probeUnknown}
+	for i, r := range results {
+		if r.job.IP != jobs[i].IP || r.status != want[i] {
+			t.Errorf("result %d = %s/%s, want %s/%s", i, r.job.IP, r.status, jobs[i].IP, want[i])
+		}
+	}
+	if _, ok := c.get(checkICMP, "10.0.0.1"); !ok {
+		t.Errorf("fresh result was not stored")
+	}
+
+	c.fresh = true
+	if _, pending := splitCached(c, checkICMP, jobs); len(pending) != len(jobs) {
+		t.Errorf("fresh cache should probe every job, got %d pending", len(pending))
+	}
+}
+
+func TestProbeCache_History(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "probes.json")
+	now := time.Now()
+
+	c := loadProbeCache(path, time.Minute)
+	for i := range historyLen + 2 {
+		c.now = func() time.Time { return now.Add(time.Duration(i) * time.Second) }
+		c.record(checkICMP, "10.0.0.1", probeUp, time.Duration(i)*time.Millisecond)
+	}
+	c.record(checkICMP, "10.0.0.2", probeUnknown, 0)
+
+	history := c.history(checkICMP, "10.0.0.1")
+	if len(history) != historyLen || history[0].RTT != (historyLen+1)*time.Millisecond {
+		t.Fatalf("history = %+v, want the %d newest probes, newest first", history, historyLen)
+	}
+	if c.history(checkICMP, "10.0.0.2") != nil {
+		t.Fatalf("unknown results should not be recorded")
+	}
+
+	// history outlives the TTL of cached results, but not historyAge
+	c.now = func() time.Time { return now.Add(time.Hour) }
+	c.record(checkICMP, "10.0.0.3", probeDown, 0)
+	c.now = func() time.Time { return now.Add(historyAge + time.Hour/2) }
+	if err := c.save(); err != nil {
+		t.Fatalf("save returned error: %v", err)
+	}
+
+	loaded := loadProbeCache(path, time.Minute)
+	if len(loaded.history(checkICMP, "10.0.0.1")) != 0 || len(loaded.history(checkICMP, "10.0.0.3")) != 1 {
+		t.Fatalf("history after save = %v, want only 10.0.0.3", loaded.History)
+	}
+}
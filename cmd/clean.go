@@ -2,35 +2,118 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
+	"slices"
 	"syscall"
 	"time"
 
-	"github.com/goccy/go-yaml"
 	"github.com/goccy/go-yaml/ast"
-	"github.com/goccy/go-yaml/parser"
 )
 
-// runCleanZones reads a YAML file, validates DNS records and nameservers by pinging them,
-// comments out unreachable entries, and generates PTR records for A records.
-func runCleanZones(filePath string, pingTimeout time.Duration, numWorkers int, dryRun bool) error {
-	if filePath == "" {
-		return fmt.Errorf("--file is required")
+// cleanOptions configures a clean-zones run.
+type cleanOptions struct {
+	File    string
+	Timeout time.Duration
+	Workers int
+	DryRun  bool
+	// AllowPartial writes output even when the run was interrupted before every host was probed.
+	AllowPartial bool
+	// MaxDuration bounds the whole probing phase; hosts not probed in time are treated as
+	// if the run had been interrupted. Zero means no deadline.
+	MaxDuration time.Duration
+	Limits      probeLimits
+	// Liveness selects how hosts are checked.
+	Liveness livenessOptions
+	// Quiet suppresses progress output on stderr.
+	Quiet bool
+	// CacheTTL is how long probe results are reused across runs; zero disables the cache.
+	CacheTTL time.Duration
+	// NoCache ignores cached results. Fresh results still refresh the cache.
+	NoCache bool
+	// Interactive asks the operator about every entry before it is disabled.
+	Interactive bool
+	// Git commits the changes to the repository holding File instead of printing them.
+	Git gitOptions
+	// FailOn lists the findings ("unreachable", "changes", "nameservers") that make the run
+	// exit with exitFindings. unreachable counts enabled entries that did not answer, not
+	// entries that are already disabled. The command line defaults to defaultFailOn.
+	FailOn []string
+	// Notify lists the targets told about records that were disabled or re-enabled.
+	Notify notifyOptions
+	// CachePath overrides the cache location, which defaults to probeCachePath.
+	CachePath string
+}
+
+// openProbeCache loads the probe cache configured by opts, or returns nil when caching is disabled.
+func openProbeCache(opts cleanOptions) (*probeCache, error) {
+	if opts.CacheTTL <= 0 {
+		return nil, nil
 	}
 
-	data, err := os.ReadFile(filePath)
-	if err != nil {
+	path := opts.CachePath
+	if path == "" {
+		var err error
+		if path, err = probeCachePath(); err != nil {
+			return nil, fmt.Errorf("probe cache: %w", err)
+		}
+	}
+
+	cache := loadProbeCache(path, opts.CacheTTL)
+	cache.fresh = opts.NoCache
+	return cache, nil
+}
+
+// runCleanZones reads a YAML file, validates DNS records and nameservers by pinging them,
+// comments out unreachable entries, re-enables entries it disabled earlier that answer again,
+// and generates PTR records for A records. The SOA serial of every zone whose records changed
+// is bumped, and every zone is checked for a live primary nameserver. State changes are sent
+// to the notify targets.
+// With Interactive set, the operator accepts, skips or keeps each entry before anything is
+// written. With Git enabled, the result is written back to the file and committed instead of
+// printed. If the run is interrupted, unprobed entries are left untouched and no output is
+// written unless AllowPartial is set; either way the run exits with exitInterrupted. Findings
+// listed in FailOn make it exit with exitFindings once the output has been written.
+func runCleanZones(opts cleanOptions) error {
+	if err := validateFailOn(opts.FailOn); err != nil {
 		return err
 	}
+	if opts.Interactive && opts.File == stdinPath {
+		return fmt.Errorf("--interactive reads answers from stdin and cannot be combined with --file -")
+	}
 
-	file, err := parser.ParseBytes(data, parser.ParseComments)
+	var repo *gitRepo
+	if opts.Git.enabled() {
+		if opts.File == stdinPath || opts.DryRun {
+			return fmt.Errorf("--git-commit and --git-branch need a --file to write and cannot be combined with --file - or --dry-run")
+		}
+		var err error
+		if repo, err = openGitRepo(opts.File); err != nil {
+			return err
+		}
+		if err := repo.checkClean(); err != nil {
+			return err
+		}
+		if opts.Git.Branch != "" {
+			if err := repo.checkNewBranch(opts.Git.Branch); err != nil {
+				return err
+			}
+		}
+	}
+
+	file, root, err := loadInventory(opts.File)
 	if err != nil {
 		return err
 	}
-
-	root := file.Docs[0].Body.(*ast.MappingNode)
+	// a malformed soa section would otherwise only fail after probing
+	if _, err := collectSOA(root); err != nil {
+		return err
+	}
+	before := file.String()
+	beforeRecords := collectRecords(root)
 
 	nsJobs := collectNameserverJobs(root)
 	dnsJobs := collectDNSRecordJobs(root)
@@ -43,40 +126,295 @@ func runCleanZones(filePath string, pingTimeout time.Duration, numWorkers int, d
 	)
 	defer stop()
 
-	results := runPingWorkers(
+	if opts.MaxDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.MaxDuration)
+		defer cancel()
+	}
+
+	check, err := newLivenessCheck(opts.Liveness)
+	if err != nil {
+		return err
+	}
+
+	cache, err := openProbeCache(opts)
+	if err != nil {
+		return err
+	}
+
+	var results []pingResult
+	pending := allJobs
+	if cache != nil {
+		results, pending = splitCached(cache, opts.Liveness.check(), allJobs)
+		if n := len(allJobs) - len(pending); n > 0 {
+			logger.Info("reusing cached probe results", "count", n)
+		}
+	}
+
+	probed := runPingWorkers(
 		ctx,
-		allJobs,
-		pingTimeout,
-		numWorkers,
+		pending,
+		opts.Timeout,
+		opts.Workers,
+		opts.Limits,
+		check,
+		newProgress(os.Stderr, opts.Quiet),
 	)
 
-	// apply results single-threaded
-	for _, r := range results {
-		if !r.ok {
+	if cache == nil {
+		results = probed
+	} else {
+		mergeProbed(cache, opts.Liveness.check(), results, probed)
+		// a stale cache only costs a re-probe, so failing to save it is not fatal
+		if err := cache.save(); err != nil {
+			logger.Warn("could not save probe cache", "error", err)
+		}
+	}
+
+	unknown := unprobed(results)
+	reason := stopReason(ctx, unknown)
+	logger.Debug("probing finished", "hosts", len(results), "unprobed", len(unknown))
+	if len(unknown) > 0 && !opts.AllowPartial {
+		return withExitCode(exitInterrupted, fmt.Errorf("run %s: %d of %d hosts were not probed, no output written (use --allow-partial to write anyway)",
+			reason, len(unknown), len(results)))
+	}
+
+	// entries the operator skips or keeps are left enabled
+	var decisions map[int]reviewDecision
+	if opts.Interactive {
+		if items := reviewItems(results, cache, opts.Liveness.check()); len(items) > 0 {
+			if decisions, err = reviewUnreachable(stdin, os.Stderr, items); err != nil {
+				return withExitCode(exitInterrupted, err)
+			}
+		}
+	}
+
+	// a dry run writes nothing, so its log says what a real run would do
+	did := func(done, planned string) string {
+		if opts.DryRun {
+			return "would " + planned
+		}
+		return done
+	}
+
+	// apply results single-threaded; unknown results leave the record as it is
+	var changes, keptDown, marked []stateChange
+	var unreachable int
+	for i, r := range results {
+		switch r.status {
+		case probeDown:
+			// kept entries are not findings; they are listed in the summary instead
+			if r.job.Keep {
+				c := changeFromJob(r.job, "kept", "unreachable")
+				logger.Info("kept unreachable record", "name", c.Name, "type", c.Type, "ip", c.IP)
+				keptDown = append(keptDown, c)
+				continue
+			}
+
+			// entries an earlier run disabled were counted by that run
+			if isDisabled(r.job.Node) {
+				continue
+			}
+			unreachable++
+
+			switch decisions[i] {
+			case reviewSkip:
+				logger.Info("left unreachable record enabled", "ip", r.job.IP)
+				continue
+			case reviewKeep:
+				if err := markKept(r.job.Node); err != nil {
+					return err
+				}
+				logger.Info("marked unreachable record as kept", "ip", r.job.IP)
+				marked = append(marked, changeFromJob(r.job, "kept", "kept by operator"))
+				continue
+			}
+
 			commentOut(r.job.Node, "unreachable")
+			c := changeFromJob(r.job, "disabled", "unreachable")
+			logger.Info(did("disabled record", "disable record"), "name", c.Name, "type", c.Type, "ip", c.IP, "reason", c.Reason)
+			changes = append(changes, c)
+		case probeUp:
+			// only markers written by an earlier run are lifted, never hand-written ones
+			if uncommentOut(r.job.Node, "unreachable") {
+				c := changeFromJob(r.job, "enabled", "reachable again")
+				logger.Info(did("re-enabled record", "re-enable record"), "name", c.Name, "type", c.Type, "ip", c.IP, "reason", c.Reason)
+				changes = append(changes, c)
+			}
+		}
+	}
+
+	for _, r := range unknown {
+		logger.Warn("host was not probed, left unchanged", "ip", r.job.IP)
+	}
+
+	added, err := createMissingPTRs(root)
+	if err != nil {
+		return err
+	}
+	for _, p := range added {
+		logger.Info(did("created PTR record", "create PTR record"), "host", p.Host, "zone", p.Zone, "value", p.Value)
+	}
+
+	bumps, err := bumpSerials(root, changedZones(beforeRecords, collectRecords(root)), time.Now())
+	if err != nil {
+		return err
+	}
+	for _, b := range bumps {
+		logger.Info(did("bumped zone serial", "bump zone serial"), "zone", b.Zone, "from", b.Old, "to", b.New)
+	}
+
+	nsIssues := cleanNameserverIssues(root, results)
+	for _, issue := range nsIssues {
+		if issue.Fatal {
+			logger.Error(issue.Message)
+		} else {
+			logger.Warn(issue.Message)
 		}
 	}
 
-	createMissingPTRs(root)
+	after := file.String()
 
-	if dryRun {
+	if !opts.Quiet {
+		printCleanSummary(os.Stderr, len(results), unreachable, changes, keptDown)
+	}
+
+	if opts.DryRun {
 		fmt.Println("# dry-run enabled, no output written")
+		return cleanResult(opts, unreachable, before != after, fatalIssues(nsIssues), len(unknown), reason)
+	}
+
+	if repo != nil {
+		if err := commitCleanZones(repo, opts, file, before != after, gitCommitMessage(opts.File, changes, marked, added)); err != nil {
+			return err
+		}
+	} else {
+		if len(unknown) > 0 {
+			fmt.Printf("# WARNING: run %s, %d of %d hosts were not probed and were left unchanged\n",
+				reason, len(unknown), len(results))
+		}
+		fmt.Print(after)
+	}
+
+	sendNotifications(context.Background(), opts.Notify, notifyEvent{
+		Event:   "state-change",
+		Source:  "clean-zones",
+		File:    opts.File,
+		Time:    time.Now().UTC(),
+		Changes: changes,
+	}, logger)
+
+	return cleanResult(opts, unreachable, before != after, fatalIssues(nsIssues), len(unknown), reason)
+}
+
+// stopReason describes why probing ended early. The rate limiter may give up on a job
+// before the --max-duration deadline has actually passed, so the jobs' errors count too.
+func stopReason(ctx context.Context, unknown []pingResult) string {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return "stopped by --max-duration"
+	}
+	for _, r := range unknown {
+		if errors.Is(r.err, context.DeadlineExceeded) {
+			return "stopped by --max-duration"
+		}
+	}
+	return "interrupted"
+}
+
+// cleanNameserverIssues checks the nameservers as the run left them. A nameserver that did
+// not answer counts as dead even when it was kept or left enabled.
+func cleanNameserverIssues(root *ast.MappingNode, results []pingResult) []nameserverIssue {
+	down := map[string]bool{}
+	for _, r := range results {
+		if r.status == probeDown {
+			down[r.job.IP] = true
+		}
+	}
+
+	return checkNameservers(
+		collectNameservers(root),
+		recordZones(collectRecords(root)),
+		collectTSIGKeyNames(root),
+		func(ns nameserver) bool { return !down[ns.IP] },
+	)
+}
+
+// fatalIssues counts the issues that fail the nameserver check.
+func fatalIssues(issues []nameserverIssue) int {
+	var n int
+	for _, issue := range issues {
+		if issue.Fatal {
+			n++
+		}
+	}
+	return n
+}
+
+// commitCleanZones writes the inventory back to its file and commits it, on a new branch
+// if one was requested. A run that changed nothing creates neither.
+func commitCleanZones(repo *gitRepo, opts cleanOptions, file *ast.File, changed bool, message string) error {
+	if !changed {
+		logger.Info("inventory unchanged, nothing to commit", "file", opts.File)
 		return nil
 	}
 
-	out, err := yaml.Marshal(file)
-	if err != nil {
+	if opts.Git.Branch != "" {
+		if err := repo.createBranch(opts.Git.Branch); err != nil {
+			return err
+		}
+	}
+	if err := saveInventory(opts.File, file); err != nil {
 		return err
 	}
+	if err := repo.commit(message); err != nil {
+		where := "the current branch"
+		if opts.Git.Branch != "" {
+			where = "branch " + opts.Git.Branch
+		}
+		return fmt.Errorf("%w; %s was written on %s but is not committed", err, opts.File, where)
+	}
 
-	fmt.Println(string(out))
+	logger.Info("committed inventory changes", "file", opts.File, "branch", opts.Git.Branch)
 	return nil
 }
 
-// kv creates a YAML mapping value node with the given key and string value.
-func kv(k, v string) *ast.MappingValueNode {
-	return &ast.MappingValueNode{
-		Key:   &ast.StringNode{Value: k},
-		Value: &ast.StringNode{Value: v},
+// printCleanSummary writes the counts of a run and the kept entries that did not answer.
+func printCleanSummary(w io.Writer, hosts, unreachable int, changes, keptDown []stateChange) {
+	var disabled, enabled int
+	for _, c := range changes {
+		switch c.State {
+		case "disabled":
+			disabled++
+		case "enabled":
+			enabled++
+		}
 	}
+
+	fmt.Fprintf(w, "summary: %d host(s), %d unreachable, %d disabled, %d re-enabled, %d kept but down\n",
+		hosts, unreachable, disabled, enabled, len(keptDown))
+	if len(keptDown) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "kept but down:")
+	for _, c := range keptDown {
+		fmt.Fprintf(w, "  %s\n", c.describe())
+	}
+}
+
+// cleanResult maps the outcome of a run that produced its output to an exit status.
+// An interruption outranks findings.
+func cleanResult(opts cleanOptions, unreachable int, changed bool, nsIssues, unprobed int, reason string) error {
+	if unprobed > 0 {
+		return withExitCode(exitInterrupted, fmt.Errorf("run %s: %d host(s) were not probed", reason, unprobed))
+	}
+	if slices.Contains(opts.FailOn, "unreachable") && unreachable > 0 {
+		return withExitCode(exitFindings, fmt.Errorf("%d unreachable host(s) found", unreachable))
+	}
+	if slices.Contains(opts.FailOn, "changes") && changed {
+		return withExitCode(exitFindings, fmt.Errorf("inventory is out of date: clean-zones changed it"))
+	}
+	if slices.Contains(opts.FailOn, "nameservers") && nsIssues > 0 {
+		return withExitCode(exitFindings, fmt.Errorf("%d nameserver problem(s) found", nsIssues))
+	}
+	return nil
 }
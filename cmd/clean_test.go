@@ -1,15 +1,21 @@
 package cmd
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"io"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
+	"syscall"
 	"testing"
 	"time"
 )
 
 func TestRunCleanZones_RequiresFileFlag(t *testing.T) {
-	err := runCleanZones("", time.Second, 1, true)
+	err := runCleanZones(cleanOptions{Timeout: time.Second, Workers: 1, DryRun: true})
 	if err == nil {
 		t.Fatalf("runCleanZones returned nil, want error")
 	}
@@ -21,7 +27,7 @@ func TestRunCleanZones_RequiresFileFlag(t *testing.T) {
 func TestRunCleanZones_ReadFailure(t *testing.T) {
 	missing := filepath.Join(t.TempDir(), "does-not-exist.yaml")
 
-	err := runCleanZones(missing, time.Second, 1, true)
+	err := runCleanZones(cleanOptions{File: missing, Timeout: time.Second, Workers: 1, DryRun: true})
 	if err == nil {
 		t.Fatalf("runCleanZones returned nil, want read error")
 	}
@@ -35,7 +41,7 @@ func TestRunCleanZones_ParseFailure(t *testing.T) {
 		t.Fatalf("failed to write invalid YAML fixture: %v", err)
 	}
 
-	err := runCleanZones(path, time.Second, 1, true)
+	err := runCleanZones(cleanOptions{File: path, Timeout: time.Second, Workers: 1, DryRun: true})
 	if err == nil {
 		t.Fatalf("runCleanZones returned nil, want parse error")
 	}
@@ -59,7 +65,7 @@ func TestRunCleanZones_DryRunNoJobs(t *testing.T) {
 		os.Stdout = oldStdout
 	}()
 
-	runErr := runCleanZones(path, time.Second, 1, true)
+	runErr := runCleanZones(cleanOptions{File: path, Timeout: time.Second, Workers: 1, DryRun: true})
 
 	if err := w.Close(); err != nil {
 		t.Fatalf("failed to close stdout writer: %v", err)
@@ -77,3 +83,503 @@ func TestRunCleanZones_DryRunNoJobs(t *testing.T) {
 		t.Fatalf("runCleanZones stdout = %q, want dry-run message", string(out))
 	}
 }
+
+const cleanFixture = `# inventory
+nameservers:
+  - name: ns1
+    ip_address: 10.0.0.53
+dns_records:
+  # web tier
+  - host: web01
+    type: A
+    zone: example.lan.
+    record_value: 10.0.1.5
+  - host: web02
+    type: A
+    zone: example.lan.
+    record_value: 10.0.1.6
+`
+
+// captureStdout runs fn and returns what it wrote to os.Stdout.
+func captureStdout(t *testing.T, fn func() error) (string, error) {
+	t.Helper()
+
+	oldStdout := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to create stdout pipe: %v", err)
+	}
+	os.Stdout = w
+	defer func() {
+		os.Stdout = oldStdout
+	}()
+
+	done := make(chan []byte)
+	go func() {
+		out, _ := io.ReadAll(r)
+		done <- out
+	}()
+
+	runErr := fn()
+	w.Close()
+	return string(<-done), runErr
+}
+
+func TestRunCleanZones_WritesDisabledAndPTRs(t *testing.T) {
+	stubProbe(t, func(ctx context.Context, ip string, timeout time.Duration) bool {
+		return ip != "10.0.1.6"
+	})
+
+	path := writeInventory(t, cleanFixture)
+	out, err := captureStdout(t, func() error {
+		return runCleanZones(cleanOptions{File: path, Timeout: time.Second, Workers: 2})
+	})
+	if err != nil {
+		t.Fatalf("runCleanZones returned error: %v", err)
+	}
+
+	for _, want := range []string{"# inventory", "# web tier", "host: web02 # DISABLED: unreachable"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("output missing %q:\n%s", want, out)
+		}
+	}
+
+	outPath := writeInventory(t, out)
+	_, root, err := loadInventory(outPath)
+	if err != nil {
+		t.Fatalf("output does not parse: %v\n%s", err, out)
+	}
+
+	var disabled, ptrs int
+	for _, r := range collectRecords(root) {
+		if r.Disabled {
+			disabled++
+		}
+		if r.Type == "PTR" {
+			ptrs++
+		}
+	}
+	if disabled != 1 || ptrs != 2 {
+		t.Fatalf("output has %d disabled records and %d PTRs, want 1 and 2:\n%s", disabled, ptrs, out)
+	}
+}
+
+func TestRunCleanZones_InterruptedRefusesOutput(t *testing.T) {
+	stubProbe(t, func(ctx context.Context, ip string, timeout time.Duration) bool {
+		syscall.Kill(os.Getpid(), syscall.SIGINT)
+		<-ctx.Done()
+		return false
+	})
+
+	path := writeInventory(t, cleanFixture)
+	out, err := captureStdout(t, func() error {
+		return runCleanZones(cleanOptions{File: path, Timeout: time.Second, Workers: 1})
+	})
+	if err == nil || !strings.Contains(err.Error(), "interrupted") || ExitCode(err) != exitInterrupted {
+		t.Fatalf("runCleanZones error = %v (exit %d), want interrupted error", err, ExitCode(err))
+	}
+	if out != "" {
+		t.Fatalf("runCleanZones wrote output after interruption:\n%s", out)
+	}
+
+	out, err = captureStdout(t, func() error {
+		return runCleanZones(cleanOptions{File: path, Timeout: time.Second, Workers: 1, AllowPartial: true})
+	})
+	// the partial output is written, but the exit status still reports the interruption
+	if ExitCode(err) != exitInterrupted {
+		t.Fatalf("runCleanZones with AllowPartial error = %v (exit %d), want exit %d", err, ExitCode(err), exitInterrupted)
+	}
+	if !strings.HasPrefix(out, "# WARNING: run interrupted") || strings.Contains(out, "DISABLED") {
+		t.Fatalf("partial output should carry a warning and no DISABLED markers:\n%s", out)
+	}
+}
+
+func TestRunCleanZones_MaxDuration(t *testing.T) {
+	stubProbe(t, func(ctx context.Context, ip string, timeout time.Duration) bool {
+		<-ctx.Done()
+		return false
+	})
+
+	path := writeInventory(t, cleanFixture)
+	_, err := captureStdout(t, func() error {
+		return runCleanZones(cleanOptions{File: path, Timeout: time.Minute, Workers: 2, MaxDuration: 20 * time.Millisecond})
+	})
+	if err == nil || !strings.Contains(err.Error(), "--max-duration") {
+		t.Fatalf("runCleanZones error = %v, want max duration error", err)
+	}
+}
+
+func TestRunCleanZones_MaxDurationRateLimited(t *testing.T) {
+	stubProbe(t, func(ctx context.Context, ip string, timeout time.Duration) bool {
+		return true
+	})
+
+	// the rate limiter gives up on the second host long before the deadline passes
+	path := writeInventory(t, cleanFixture)
+	_, err := captureStdout(t, func() error {
+		return runCleanZones(cleanOptions{File: path, Timeout: time.Second, Workers: 1, MaxDuration: time.Minute, Limits: probeLimits{Rate: 0.01}, Quiet: true})
+	})
+	if err == nil || !strings.Contains(err.Error(), "stopped by --max-duration") {
+		t.Fatalf("runCleanZones error = %v, want max duration error", err)
+	}
+}
+
+func TestRunCleanZones_MaxDurationRateLimitedCached(t *testing.T) {
+	stubProbe(t, func(ctx context.Context, ip string, timeout time.Duration) bool {
+		return true
+	})
+
+	// merging fresh results into cached ones must keep the reason a job was skipped
+	path := writeInventory(t, cleanFixture)
+	_, err := captureStdout(t, func() error {
+		return runCleanZones(cleanOptions{
+			File:        path,
+			Timeout:     time.Second,
+			Workers:     1,
+			MaxDuration: time.Minute,
+			Limits:      probeLimits{Rate: 0.01},
+			Quiet:       true,
+			CacheTTL:    10 * time.Minute,
+			CachePath:   filepath.Join(t.TempDir(), "probes.json"),
+		})
+	})
+	if err == nil || !strings.Contains(err.Error(), "stopped by --max-duration") {
+		t.Fatalf("runCleanZones error = %v, want max duration error", err)
+	}
+}
+
+func TestRunCleanZones_ReusesCache(t *testing.T) {
+	var probed []string
+	stubProbe(t, func(ctx context.Context, ip string, timeout time.Duration) bool {
+		probed = append(probed, ip)
+		return ip != "10.0.1.6"
+	})
+
+	path := writeInventory(t, cleanFixture)
+	opts := cleanOptions{
+		File:      path,
+		Timeout:   time.Second,
+		Workers:   1,
+		Quiet:     true,
+		CacheTTL:  time.Minute,
+		CachePath: filepath.Join(t.TempDir(), "probes.json"),
+	}
+
+	first, err := captureStdout(t, func() error { return runCleanZones(opts) })
+	if err != nil {
+		t.Fatalf("first run returned error: %v", err)
+	}
+	if len(probed) != 3 {
+		t.Fatalf("first run probed %d hosts, want 3", len(probed))
+	}
+
+	logs := captureLogs(t)
+
+	second, err := captureStdout(t, func() error { return runCleanZones(opts) })
+	if err != nil {
+		t.Fatalf("second run returned error: %v", err)
+	}
+	// --quiet only silences progress output, not the log
+	if !strings.Contains(logs.String(), "reusing cached probe results") {
+		t.Fatalf("second run did not log the cache reuse:\n%s", logs.String())
+	}
+	if len(probed) != 3 {
+		t.Fatalf("second run probed %d more hosts, want 0", len(probed)-3)
+	}
+	if first != second {
+		t.Fatalf("cached run produced different output:\n%s\nvs\n%s", first, second)
+	}
+
+	opts.NoCache = true
+	if _, err := captureStdout(t, func() error { return runCleanZones(opts) }); err != nil {
+		t.Fatalf("--no-cache run returned error: %v", err)
+	}
+	if len(probed) != 6 {
+		t.Fatalf("--no-cache run probed %d hosts, want 3", len(probed)-3)
+	}
+}
+
+func TestRunCleanZones_NotifiesStateChanges(t *testing.T) {
+	stubProbe(t, func(ctx context.Context, ip string, timeout time.Duration) bool {
+		return ip != "10.0.1.5"
+	})
+
+	rec := &webhookRecorder{}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	path := writeInventory(t, `dns_records:
+  - host: web01
+    type: A
+    zone: example.lan.
+    record_value: 10.0.1.5
+  - host: web02 # DISABLED: unreachable
+    type: A
+    zone: example.lan.
+    record_value: 10.0.1.6
+  - host: web03 # DISABLED: retired
+    type: A
+    zone: example.lan.
+    record_value: 10.0.1.7
+`)
+
+	out, err := captureStdout(t, func() error {
+		return runCleanZones(cleanOptions{
+			File:    path,
+			Timeout: time.Second,
+			Workers: 1,
+			Notify:  notifyOptions{Webhooks: []string{srv.URL}, Timeout: time.Second},
+		})
+	})
+	if err != nil {
+		t.Fatalf("runCleanZones returned error: %v", err)
+	}
+
+	for _, want := range []string{"host: web01 # DISABLED: unreachable", "host: web02\n", "host: web03 # DISABLED: retired"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("output missing %q:\n%s", want, out)
+		}
+	}
+
+	if len(rec.bodies) != 1 {
+		t.Fatalf("webhook received %d events, want 1", len(rec.bodies))
+	}
+	var ev notifyEvent
+	if err := json.Unmarshal(rec.bodies[0], &ev); err != nil {
+		t.Fatal(err)
+	}
+	if len(ev.Changes) != 2 ||
+		ev.Changes[0].Name != "web01" || ev.Changes[0].State != "disabled" ||
+		ev.Changes[1].Name != "web02" || ev.Changes[1].State != "enabled" {
+		t.Fatalf("event changes = %+v", ev.Changes)
+	}
+}
+
+func TestRunCleanZones_Logs(t *testing.T) {
+	stubProbe(t, func(ctx context.Context, ip string, timeout time.Duration) bool {
+		return ip != "10.0.1.6"
+	})
+	logs := captureLogs(t)
+
+	path := writeInventory(t, cleanFixture)
+	out, err := captureStdout(t, func() error {
+		return runCleanZones(cleanOptions{File: path, Timeout: time.Second, Workers: 1, Quiet: true})
+	})
+	if err != nil {
+		t.Fatalf("runCleanZones returned error: %v", err)
+	}
+	if strings.Contains(out, "level=") || strings.Contains(out, `"msg"`) {
+		t.Fatalf("logs leaked into stdout:\n%s", out)
+	}
+
+	counts := map[string]int{}
+	for _, e := range logEntries(t, logs) {
+		counts[e["msg"].(string)]++
+		if e["msg"] == "disabled record" && (e["ip"] != "10.0.1.6" || e["name"] != "web02") {
+			t.Errorf("disabled record entry = %v", e)
+		}
+	}
+
+	if counts["probe"] != 3 || counts["disabled record"] != 1 || counts["created PTR record"] != 2 {
+		t.Fatalf("log message counts = %v", counts)
+	}
+}
+
+func TestRunCleanZones_DryRunLogs(t *testing.T) {
+	stubProbe(t, func(ctx context.Context, ip string, timeout time.Duration) bool {
+		return ip != "10.0.1.6"
+	})
+	logs := captureLogs(t)
+
+	path := writeInventory(t, "soa:\n  - zone: example.lan.\n    serial: 1\n"+cleanFixture)
+	if _, err := captureStdout(t, func() error {
+		return runCleanZones(cleanOptions{File: path, Timeout: time.Second, Workers: 1, Quiet: true, DryRun: true})
+	}); err != nil {
+		t.Fatalf("runCleanZones returned error: %v", err)
+	}
+
+	counts := map[string]int{}
+	for _, e := range logEntries(t, logs) {
+		counts[e["msg"].(string)]++
+	}
+	if counts["would disable record"] != 1 || counts["would create PTR record"] != 2 || counts["would bump zone serial"] != 1 ||
+		counts["created PTR record"] != 0 || counts["bumped zone serial"] != 0 {
+		t.Fatalf("dry-run log message counts = %v", counts)
+	}
+}
+
+func TestRunCleanZones_Stdin(t *testing.T) {
+	stubProbe(t, func(ctx context.Context, ip string, timeout time.Duration) bool {
+		return true
+	})
+	stubStdin(t, cleanFixture)
+
+	out, err := captureStdout(t, func() error {
+		return runCleanZones(cleanOptions{File: "-", Timeout: time.Second, Workers: 1, Quiet: true})
+	})
+	if err != nil {
+		t.Fatalf("runCleanZones(-) returned error: %v", err)
+	}
+
+	if !strings.HasPrefix(out, "# inventory\n") {
+		t.Fatalf("stdout should start with the YAML document:\n%s", out)
+	}
+	if _, err := parseMapping(out); err != nil {
+		t.Fatalf("stdout is not YAML: %v\n%s", err, out)
+	}
+}
+
+func TestRunCleanZones_FailOn(t *testing.T) {
+	stubProbe(t, func(ctx context.Context, ip string, timeout time.Duration) bool {
+		return ip != "10.0.1.6"
+	})
+
+	clean := `dns_records:
+  - host: web01
+    type: A
+    zone: example.lan.
+    record_value: 10.0.1.5
+  - host: web01
+    type: PTR
+    zone: 1.0.10.in-addr.arpa.
+    record_value: "5"
+`
+
+	tests := []struct {
+		name    string
+		content string
+		failOn  []string
+		want    int
+	}{
+		{"no fail-on", cleanFixture, nil, exitOK},
+		{"unreachable found", cleanFixture, []string{"unreachable"}, exitFindings},
+		{"changes made", cleanFixture, []string{"changes"}, exitFindings},
+		{"clean inventory", clean, []string{"unreachable", "changes"}, exitOK},
+		{"already disabled", strings.Replace(cleanFixture, "host: web02", "host: web02 # DISABLED: unreachable", 1), []string{"unreachable"}, exitOK},
+		{"live primary", cleanFixture, []string{"nameservers"}, exitOK},
+		{"no primary", strings.Replace(cleanFixture, "10.0.0.53\n", "10.0.0.53\n    role: secondary\n", 1), []string{"nameservers"}, exitFindings},
+		{"generated reverse zone", strings.Replace(cleanFixture, "10.0.0.53\n", "10.0.0.53\n    zones: [example.lan.]\n", 1), []string{"nameservers"}, exitOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeInventory(t, tt.content)
+			out, err := captureStdout(t, func() error {
+				return runCleanZones(cleanOptions{File: path, Timeout: time.Second, Workers: 1, Quiet: true, FailOn: tt.failOn})
+			})
+			if got := ExitCode(err); got != tt.want {
+				t.Fatalf("exit code = %d (%v), want %d", got, err, tt.want)
+			}
+			if !strings.Contains(out, "dns_records:") {
+				t.Fatalf("output must be written even when findings fail the run:\n%s", out)
+			}
+		})
+	}
+
+	path := writeInventory(t, cleanFixture)
+	_, err := captureStdout(t, func() error {
+		return runCleanZones(cleanOptions{File: path, Timeout: time.Second, FailOn: []string{"typos"}})
+	})
+	if ExitCode(err) != exitError || !strings.Contains(err.Error(), "--fail-on") {
+		t.Fatalf("invalid --fail-on error = %v", err)
+	}
+}
+
+func TestRunCleanZones_Interactive(t *testing.T) {
+	stubProbe(t, func(ctx context.Context, ip string, timeout time.Duration) bool {
+		return false
+	})
+
+	content := cleanFixture + `  - host: vip
+    type: A
+    zone: example.lan.
+    record_value: 10.0.1.9
+    dnsctl_keep: true
+`
+	path := writeInventory(t, content)
+
+	// ns1 is accepted, web01 skipped and web02 kept always; vip is never asked about
+	stubStdin(t, "a\ns\nk\n")
+	out, err := captureStdout(t, func() error {
+		return runCleanZones(cleanOptions{File: path, Timeout: time.Second, Workers: 1, Quiet: true, Interactive: true})
+	})
+	if err != nil {
+		t.Fatalf("runCleanZones returned error: %v", err)
+	}
+
+	for _, want := range []string{
+		"name: ns1 # DISABLED: unreachable",
+		"host: web01\n",
+		"record_value: 10.0.1.6\n    dnsctl_keep: true\n",
+		"host: vip\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output is missing %q:\n%s", want, out)
+		}
+	}
+
+	stubStdin(t, "a\nq\n")
+	out, err = captureStdout(t, func() error {
+		return runCleanZones(cleanOptions{File: path, Timeout: time.Second, Workers: 1, Quiet: true, Interactive: true})
+	})
+	if !errors.Is(err, errReviewAborted) || ExitCode(err) != exitInterrupted {
+		t.Fatalf("aborted review error = %v (exit %d), want errReviewAborted", err, ExitCode(err))
+	}
+	if out != "" {
+		t.Fatalf("aborted review wrote output:\n%s", out)
+	}
+
+	if err := runCleanZones(cleanOptions{File: stdinPath, Interactive: true}); err == nil || !strings.Contains(err.Error(), "--interactive") {
+		t.Fatalf("--interactive with --file - error = %v", err)
+	}
+}
+
+func TestRunCleanZones_KeptEntries(t *testing.T) {
+	stubProbe(t, func(ctx context.Context, ip string, timeout time.Duration) bool {
+		return ip == "10.0.0.53"
+	})
+
+	content := `dns_records:
+  # dnsctl:keep
+  - host: vip
+    type: A
+    zone: example.lan.
+    record_value: 10.0.1.9
+  - host: web01 # dnsctl:keep
+    type: A
+    zone: example.lan.
+    record_value: 10.0.1.5
+  - host: web02
+    type: A
+    zone: example.lan.
+    record_value: 10.0.1.6
+    dnsctl_keep: true
+`
+	path := writeInventory(t, content)
+	out, err := captureStdout(t, func() error {
+		return runCleanZones(cleanOptions{File: path, Timeout: time.Second, Workers: 1, Quiet: true, FailOn: []string{"unreachable"}})
+	})
+	if err != nil {
+		t.Fatalf("runCleanZones returned error: %v, kept entries must not count as unreachable", err)
+	}
+	if strings.Contains(out, "DISABLED") {
+		t.Fatalf("kept entries were disabled:\n%s", out)
+	}
+}
+
+func TestPrintCleanSummary(t *testing.T) {
+	var out strings.Builder
+	printCleanSummary(&out, 5, 2,
+		[]stateChange{{Name: "web02", State: "disabled"}, {Name: "web03", State: "enabled"}},
+		[]stateChange{{Name: "vip", Type: "A", Zone: "example.lan.", IP: "10.0.1.9"}, {Name: "ns2", IP: "10.0.0.54"}},
+	)
+
+	want := `summary: 5 host(s), 2 unreachable, 1 disabled, 1 re-enabled, 2 kept but down
+kept but down:
+  vip A example.lan. 10.0.1.9
+  ns2 10.0.0.54
+`
+	if out.String() != want {
+		t.Fatalf("summary =\n%s\nwant\n%s", out.String(), want)
+	}
+}
@@ -0,0 +1,328 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// defaultCloudflareURL is the base URL of the Cloudflare v4 API.
+const defaultCloudflareURL = "https://api.cloudflare.com/client/v4"
+
+// cloudflareConfig holds the connection settings for a Cloudflare v4 compatible API.
+type cloudflareConfig struct {
+	URL   string
+	Token string
+}
+
+// cloudflareClient manages records through a Cloudflare v4 style REST API.
+// Zone IDs are looked up by name once and cached.
+type cloudflareClient struct {
+	Config  cloudflareConfig
+	HTTP    *http.Client
+	zoneIDs map[string]string
+}
+
+type cfEnvelope struct {
+	Success    bool            `json:"success"`
+	Errors     []cfError       `json:"errors"`
+	Result     json.RawMessage `json:"result"`
+	ResultInfo *cfResultInfo   `json:"result_info,omitempty"`
+}
+
+type cfError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type cfResultInfo struct {
+	Page       int `json:"page"`
+	TotalPages int `json:"total_pages"`
+}
+
+type cfZone struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type cfRecord struct {
+	ID       string  `json:"id,omitempty"`
+	Type     string  `json:"type"`
+	Name     string  `json:"name"`
+	Content  string  `json:"content"`
+	TTL      uint32  `json:"ttl"`
+	Priority *uint16 `json:"priority,omitempty"`
+	Proxied  *bool   `json:"proxied,omitempty"`
+}
+
+// cloudflareAutoTTL is the TTL Cloudflare reports for records whose TTL it manages,
+// which includes every proxied record.
+const cloudflareAutoTTL = 1
+
+// cloudflareTypes lists the record types the Cloudflare provider can represent.
+var cloudflareTypes = map[string]bool{
+	"A":     true,
+	"AAAA":  true,
+	"CNAME": true,
+	"MX":    true,
+	"TXT":   true,
+	"NS":    true,
+	"PTR":   true,
+}
+
+// SupportsType reports whether rtype is one of cloudflareTypes.
+func (c *cloudflareClient) SupportsType(rtype string) bool {
+	return cloudflareTypes[rtype]
+}
+
+// newCloudflareClient returns a client for cfg, defaulting to the public Cloudflare API.
+func newCloudflareClient(cfg cloudflareConfig, timeout time.Duration) *cloudflareClient {
+	if cfg.URL == "" {
+		cfg.URL = defaultCloudflareURL
+	}
+	return &cloudflareClient{
+		Config:  cfg,
+		HTTP:    &http.Client{Timeout: timeout},
+		zoneIDs: map[string]string{},
+	}
+}
+
+// do sends an authenticated API request and unwraps the v4 response envelope.
+func (c *cloudflareClient) do(method, path string, body any) (cfEnvelope, error) {
+	var env cfEnvelope
+
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return env, err
+		}
+		r = bytes.NewReader(data)
+	}
+
+	target := strings.TrimSuffix(c.Config.URL, "/") + path
+	req, err := http.NewRequest(method, target, r)
+	if err != nil {
+		return env, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.Config.Token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return env, fmt.Errorf("cloudflare %s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(&env); err != nil {
+		return env, fmt.Errorf("cloudflare %s %s: %s: %w", method, path, resp.Status, err)
+	}
+
+	if !env.Success || resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var msgs []string
+		for _, e := range env.Errors {
+			msgs = append(msgs, fmt.Sprintf("%d %s", e.Code, e.Message))
+		}
+		return env, fmt.Errorf("cloudflare %s %s: %s: %s", method, path, resp.Status, strings.Join(msgs, "; "))
+	}
+
+	return env, nil
+}
+
+// zoneID resolves a zone name to its API identifier.
+func (c *cloudflareClient) zoneID(zone string) (string, error) {
+	name := strings.TrimSuffix(canonicalZone(zone), ".")
+	if id, ok := c.zoneIDs[name]; ok {
+		return id, nil
+	}
+
+	env, err := c.do(http.MethodGet, "/zones?name="+url.QueryEscape(name), nil)
+	if err != nil {
+		return "", err
+	}
+
+	var zones []cfZone
+	if err := json.Unmarshal(env.Result, &zones); err != nil {
+		return "", err
+	}
+	if len(zones) == 0 {
+		return "", fmt.Errorf("cloudflare: zone %s not found", name)
+	}
+
+	c.zoneIDs[name] = zones[0].ID
+	return zones[0].ID, nil
+}
+
+// ListRecords returns every supported record in zone, following pagination.
+func (c *cloudflareClient) ListRecords(zone string) ([]resourceRecord, error) {
+	id, err := c.zoneID(zone)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []resourceRecord
+	for page := 1; ; page++ {
+		env, err := c.do(http.MethodGet, fmt.Sprintf("/zones/%s/dns_records?per_page=100&page=%d", id, page), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var recs []cfRecord
+		if err := json.Unmarshal(env.Result, &recs); err != nil {
+			return nil, err
+		}
+
+		for _, cf := range recs {
+			if !cloudflareTypes[cf.Type] {
+				continue
+			}
+			r, err := fromCloudflare(cf)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, r)
+		}
+
+		if env.ResultInfo == nil || page >= env.ResultInfo.TotalPages {
+			break
+		}
+	}
+
+	return managedRecords(out, canonicalZone(zone)), nil
+}
+
+// CreateRecord adds rec to zone.
+func (c *cloudflareClient) CreateRecord(zone string, rec resourceRecord) error {
+	id, err := c.zoneID(zone)
+	if err != nil {
+		return err
+	}
+
+	cf, err := toCloudflare(rec)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.do(http.MethodPost, "/zones/"+id+"/dns_records", cf)
+	return err
+}
+
+// UpdateRecord overwrites the record identified by old.ID with rec. The proxy flag of
+// old is kept, so that an update never turns proxying off.
+func (c *cloudflareClient) UpdateRecord(zone string, old, rec resourceRecord) error {
+	if old.ID == "" {
+		return fmt.Errorf("cloudflare: cannot update %s without a record id", old)
+	}
+
+	id, err := c.zoneID(zone)
+	if err != nil {
+		return err
+	}
+
+	cf, err := toCloudflare(rec)
+	if err != nil {
+		return err
+	}
+	cf.Proxied = old.Proxied
+
+	_, err = c.do(http.MethodPut, "/zones/"+id+"/dns_records/"+old.ID, cf)
+	return err
+}
+
+// DeleteRecord removes the record identified by rec.ID.
+func (c *cloudflareClient) DeleteRecord(zone string, rec resourceRecord) error {
+	if rec.ID == "" {
+		return fmt.Errorf("cloudflare: cannot delete %s without a record id", rec)
+	}
+
+	id, err := c.zoneID(zone)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.do(http.MethodDelete, "/zones/"+id+"/dns_records/"+rec.ID, nil)
+	return err
+}
+
+// toCloudflare converts a normalized record to the API representation. Names lose
+// their trailing dot, MX preference moves to the priority field and TXT strings are unquoted.
+func toCloudflare(r resourceRecord) (cfRecord, error) {
+	if !cloudflareTypes[r.Type] {
+		return cfRecord{}, fmt.Errorf("cloudflare: %s records are not supported", r.Type)
+	}
+
+	rr, err := dns.NewRR(r.String())
+	if err != nil {
+		return cfRecord{}, err
+	}
+
+	cf := cfRecord{
+		Type: r.Type,
+		Name: strings.TrimSuffix(r.Name, "."),
+		TTL:  r.TTL,
+	}
+
+	switch v := rr.(type) {
+	case *dns.A:
+		cf.Content = v.A.String()
+	case *dns.AAAA:
+		cf.Content = v.AAAA.String()
+	case *dns.CNAME:
+		cf.Content = strings.TrimSuffix(v.Target, ".")
+	case *dns.NS:
+		cf.Content = strings.TrimSuffix(v.Ns, ".")
+	case *dns.PTR:
+		cf.Content = strings.TrimSuffix(v.Ptr, ".")
+	case *dns.MX:
+		pref := v.Preference
+		cf.Priority = &pref
+		cf.Content = strings.TrimSuffix(v.Mx, ".")
+	case *dns.TXT:
+		cf.Content = strings.Join(v.Txt, "")
+	}
+
+	return cf, nil
+}
+
+// fromCloudflare converts an API record to its normalized form, keeping the record ID and
+// proxy flag. An automatic TTL becomes autoTTL.
+func fromCloudflare(cf cfRecord) (resourceRecord, error) {
+	content := cf.Content
+	switch cf.Type {
+	case "CNAME", "NS", "PTR":
+		content = dns.Fqdn(content)
+	case "MX":
+		var pref uint16
+		if cf.Priority != nil {
+			pref = *cf.Priority
+		}
+		content = strconv.Itoa(int(pref)) + " " + dns.Fqdn(content)
+	case "TXT":
+		if !strings.HasPrefix(content, `"`) {
+			content = strconv.Quote(content)
+		}
+	}
+
+	rr, err := dns.NewRR(fmt.Sprintf("%s %d IN %s %s", dns.Fqdn(cf.Name), cf.TTL, cf.Type, content))
+	if err != nil {
+		return resourceRecord{}, fmt.Errorf("cloudflare record %s %s: %w", cf.Name, cf.Type, err)
+	}
+
+	r := resourceFromRR(rr)
+	r.ID = cf.ID
+	r.Proxied = cf.Proxied
+	if cf.TTL == cloudflareAutoTTL {
+		r.TTL = autoTTL
+	}
+	return r, nil
+}
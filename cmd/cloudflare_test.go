@@ -0,0 +1,312 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testCloudflareToken = "cf-token"
+
+// fakeCloudflare is an in-memory implementation of the Cloudflare v4 DNS records API.
+type fakeCloudflare struct {
+	mu       sync.Mutex
+	zoneID   string
+	zoneName string
+	records  map[string]cfRecord
+	nextID   int
+	pageSize int
+	requests []string
+}
+
+func startFakeCloudflare(t *testing.T, zoneName string, records ...cfRecord) (*fakeCloudflare, *httptest.Server) {
+	t.Helper()
+
+	fc := &fakeCloudflare{zoneID: "zone123", zoneName: zoneName, records: map[string]cfRecord{}, pageSize: 100}
+	for _, r := range records {
+		fc.nextID++
+		r.ID = "rec" + strconv.Itoa(fc.nextID)
+		fc.records[r.ID] = r
+	}
+
+	srv := httptest.NewServer(fc)
+	t.Cleanup(srv.Close)
+	return fc, srv
+}
+
+func (fc *fakeCloudflare) reply(w http.ResponseWriter, status int, result any, info *cfResultInfo) {
+	data, _ := json.Marshal(result)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(cfEnvelope{Success: status < 300, Result: data, ResultInfo: info})
+}
+
+func (fc *fakeCloudflare) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	fc.requests = append(fc.requests, r.Method+" "+r.URL.Path)
+
+	if r.Header.Get("Authorization") != "Bearer "+testCloudflareToken {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(cfEnvelope{Errors: []cfError{{Code: 9109, Message: "Invalid access token"}}})
+		return
+	}
+
+	recordsPath := "/zones/" + fc.zoneID + "/dns_records"
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/zones":
+		var zones []cfZone
+		if r.URL.Query().Get("name") == fc.zoneName {
+			zones = append(zones, cfZone{ID: fc.zoneID, Name: fc.zoneName})
+		}
+		fc.reply(w, http.StatusOK, zones, nil)
+
+	case r.Method == http.MethodGet && r.URL.Path == recordsPath:
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		var all []cfRecord
+		for i := 1; i <= fc.nextID; i++ {
+			if rec, ok := fc.records["rec"+strconv.Itoa(i)]; ok {
+				all = append(all, rec)
+			}
+		}
+		total := (len(all) + fc.pageSize - 1) / fc.pageSize
+		start := (page - 1) * fc.pageSize
+		end := start + fc.pageSize
+		if start > len(all) {
+			start = len(all)
+		}
+		if end > len(all) {
+			end = len(all)
+		}
+		fc.reply(w, http.StatusOK, all[start:end], &cfResultInfo{Page: page, TotalPages: total})
+
+	case r.Method == http.MethodPost && r.URL.Path == recordsPath:
+		var rec cfRecord
+		json.NewDecoder(r.Body).Decode(&rec)
+		fc.nextID++
+		rec.ID = "rec" + strconv.Itoa(fc.nextID)
+		fc.records[rec.ID] = rec
+		fc.reply(w, http.StatusOK, rec, nil)
+
+	case strings.HasPrefix(r.URL.Path, recordsPath+"/"):
+		id := strings.TrimPrefix(r.URL.Path, recordsPath+"/")
+		if _, ok := fc.records[id]; !ok {
+			fc.reply(w, http.StatusNotFound, nil, nil)
+			return
+		}
+		switch r.Method {
+		case http.MethodPut:
+			var rec cfRecord
+			json.NewDecoder(r.Body).Decode(&rec)
+			rec.ID = id
+			fc.records[id] = rec
+			fc.reply(w, http.StatusOK, rec, nil)
+		case http.MethodDelete:
+			delete(fc.records, id)
+			fc.reply(w, http.StatusOK, map[string]string{"id": id}, nil)
+		}
+
+	default:
+		fc.reply(w, http.StatusNotFound, nil, nil)
+	}
+}
+
+func (fc *fakeCloudflare) contents() map[string]string {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	out := map[string]string{}
+	for _, r := range fc.records {
+		key := r.Name + " " + r.Type
+		if r.Priority != nil {
+			out[key] = fmt.Sprintf("%d %s", *r.Priority, r.Content)
+		} else {
+			out[key] = r.Content
+		}
+	}
+	return out
+}
+
+func TestCloudflareConversionRoundTrip(t *testing.T) {
+	cases := []resourceRecord{
+		{Name: "web.example.com.", Type: "A", TTL: 300, Data: "192.0.2.10"},
+		{Name: "www.example.com.", Type: "CNAME", TTL: 300, Data: "web.example.com."},
+		{Name: "example.com.", Type: "MX", TTL: 300, Data: "10 mail.example.com."},
+		{Name: "example.com.", Type: "TXT", TTL: 300, Data: `"v=spf1 -all"`},
+	}
+
+	for _, want := range cases {
+		cf, err := toCloudflare(want)
+		if err != nil {
+			t.Fatalf("toCloudflare(%s) returned error: %v", want, err)
+		}
+		if strings.HasSuffix(cf.Name, ".") {
+			t.Fatalf("toCloudflare(%s) name = %q, want no trailing dot", want, cf.Name)
+		}
+
+		got, err := fromCloudflare(cf)
+		if err != nil {
+			t.Fatalf("fromCloudflare(%+v) returned error: %v", cf, err)
+		}
+		if got.key() != want.key() || got.TTL != want.TTL {
+			t.Fatalf("round trip of %s = %s", want, got)
+		}
+	}
+
+	if _, err := toCloudflare(resourceRecord{Name: "_sip._tcp.example.com.", Type: "SRV", TTL: 300, Data: "0 5 5060 sip.example.com."}); err == nil {
+		t.Fatalf("toCloudflare(SRV) returned nil, want unsupported error")
+	}
+}
+
+func TestCloudflareClient_ListRecordsPaginates(t *testing.T) {
+	fc, srv := startFakeCloudflare(t, "example.com",
+		cfRecord{Type: "A", Name: "a.example.com", Content: "192.0.2.1", TTL: 300},
+		cfRecord{Type: "A", Name: "b.example.com", Content: "192.0.2.2", TTL: 300},
+		cfRecord{Type: "SRV", Name: "_sip._tcp.example.com", Content: "ignored", TTL: 300},
+		cfRecord{Type: "A", Name: "c.example.com", Content: "192.0.2.3", TTL: 300},
+	)
+	fc.pageSize = 2
+
+	c := newCloudflareClient(cloudflareConfig{URL: srv.URL, Token: testCloudflareToken}, 2*time.Second)
+	got, err := c.ListRecords("example.com.")
+	if err != nil {
+		t.Fatalf("ListRecords returned error: %v", err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("ListRecords returned %d records, want 3: %v", len(got), got)
+	}
+	for _, r := range got {
+		if r.ID == "" {
+			t.Fatalf("ListRecords record %s has no ID", r)
+		}
+	}
+}
+
+func TestCloudflareClient_Errors(t *testing.T) {
+	_, srv := startFakeCloudflare(t, "example.com")
+
+	bad := newCloudflareClient(cloudflareConfig{URL: srv.URL, Token: "wrong"}, 2*time.Second)
+	if _, err := bad.ListRecords("example.com."); err == nil || !strings.Contains(err.Error(), "Invalid access token") {
+		t.Fatalf("ListRecords with bad token error = %v, want API error message", err)
+	}
+
+	c := newCloudflareClient(cloudflareConfig{URL: srv.URL, Token: testCloudflareToken}, 2*time.Second)
+	if _, err := c.ListRecords("missing.com."); err == nil {
+		t.Fatalf("ListRecords(missing zone) returned nil, want error")
+	}
+	if err := c.DeleteRecord("example.com.", resourceRecord{Name: "a.example.com."}); err == nil {
+		t.Fatalf("DeleteRecord without ID returned nil, want error")
+	}
+}
+
+func TestRunApply_Cloudflare(t *testing.T) {
+	fc, srv := startFakeCloudflare(t, "example.com",
+		cfRecord{Type: "A", Name: "web.example.com", Content: "192.0.2.10", TTL: 60},
+		cfRecord{Type: "A", Name: "old.example.com", Content: "192.0.2.99", TTL: 3600},
+	)
+
+	path := writeInventory(t, `dns_records:
+  - host: web
+    type: A
+    zone: example.com.
+    record_value: 192.0.2.10
+  - host: "@"
+    type: MX
+    zone: example.com.
+    record_value: 10 mail.example.com.
+`)
+
+	var out bytes.Buffer
+	err := runApply(applyOptions{
+		File:     path,
+		Provider: "cloudflare",
+		API:      apiConfig{URL: srv.URL, Key: testCloudflareToken},
+		Timeout:  2 * time.Second,
+	}, &out)
+	if err != nil {
+		t.Fatalf("runApply returned error: %v", err)
+	}
+
+	if !strings.Contains(out.String(), "Plan: 1 to add, 1 to change, 1 to destroy.") {
+		t.Fatalf("unexpected plan output:\n%s", out.String())
+	}
+
+	got := fc.contents()
+	want := map[string]string{
+		"web.example.com A": "192.0.2.10",
+		"example.com MX":    "10 mail.example.com",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("records after apply = %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Fatalf("records after apply = %v, want %v", got, want)
+		}
+	}
+	for _, r := range fc.records {
+		if r.Name == "web.example.com" && r.TTL != defaultTTL {
+			t.Fatalf("web.example.com TTL = %d, want %d", r.TTL, defaultTTL)
+		}
+	}
+}
+
+func TestRunApply_CloudflareProxiedAndUnsupported(t *testing.T) {
+	proxied := true
+	fc, srv := startFakeCloudflare(t, "example.com",
+		cfRecord{Type: "A", Name: "www.example.com", Content: "192.0.2.10", TTL: cloudflareAutoTTL, Proxied: &proxied},
+		cfRecord{Type: "A", Name: "api.example.com", Content: "192.0.2.11", TTL: 60, Proxied: &proxied},
+	)
+
+	path := writeInventory(t, `dns_records:
+  - host: www
+    type: A
+    zone: example.com.
+    record_value: 192.0.2.10
+  - host: api
+    type: A
+    zone: example.com.
+    record_value: 192.0.2.11
+  - host: _sip._tcp
+    type: SRV
+    zone: example.com.
+    record_value: 0 5 5060 sip.example.com.
+`)
+
+	var out bytes.Buffer
+	err := runApply(applyOptions{
+		File:     path,
+		Provider: "cloudflare",
+		API:      apiConfig{URL: srv.URL, Key: testCloudflareToken},
+		Timeout:  2 * time.Second,
+	}, &out)
+	if err != nil {
+		t.Fatalf("runApply returned error: %v\n%s", err, out.String())
+	}
+
+	for _, want := range []string{
+		"Plan: 0 to add, 1 to change, 0 to destroy.",
+		"! skipped _sip._tcp.example.com. 3600 IN SRV 0 5 5060 sip.example.com.: SRV records are not supported",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("plan output missing %q:\n%s", want, out.String())
+		}
+	}
+
+	for _, r := range fc.records {
+		if r.Proxied == nil || !*r.Proxied {
+			t.Fatalf("%s lost its proxy flag: %+v", r.Name, r)
+		}
+		if r.Name == "www.example.com" && r.TTL != cloudflareAutoTTL {
+			t.Fatalf("www.example.com has an automatic TTL and must not be updated: %+v", r)
+		}
+	}
+}
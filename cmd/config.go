@@ -0,0 +1,332 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/goccy/go-yaml"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// projectConfig is the per-directory config file, read after the user config.
+const projectConfig = ".dnsctl.yaml"
+
+// envPrefix prefixes the environment variables that override config file values.
+const envPrefix = "DNSCTL_"
+
+// secretKeys lists the settings whose values are masked by config show.
+var secretKeys = map[string]bool{
+	"tsig-secret": true,
+	"api-key":     true,
+}
+
+// configFile is one parsed config file. Keys are flag names. Scalar top-level keys apply
+// to every command that has the flag, a top-level mapping named after a command applies
+// to that command and its subcommands only, and profiles holds named sets of the same
+// structure:
+//
+//	timeout: 5s
+//	clean-zones:
+//	  timeout: 2s
+//	  workers: 16
+//	ipam:
+//	  reserve: [10.0.1.1-10.0.1.19]
+//	profiles:
+//	  lab:
+//	    server: 10.0.0.53
+type configFile struct {
+	Path string
+	Data map[string]any
+}
+
+// configValue is the effective value of a setting and where it came from.
+type configValue struct {
+	Value  string
+	Source string
+}
+
+// configPaths returns the config files to read, lowest precedence first.
+func configPaths() []string {
+	var paths []string
+	if dir, err := os.UserConfigDir(); err == nil {
+		paths = append(paths, filepath.Join(dir, "dnsctl", "config.yaml"))
+	}
+	return append(paths, projectConfig)
+}
+
+// loadConfigFiles parses every existing file in paths; missing files are skipped.
+func loadConfigFiles(paths []string) ([]configFile, error) {
+	var files []configFile
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var m map[string]any
+		if err := yaml.Unmarshal(data, &m); err != nil {
+			return nil, fmt.Errorf("config %s: %w", p, err)
+		}
+		if m == nil {
+			m = map[string]any{}
+		}
+		files = append(files, configFile{Path: p, Data: m})
+	}
+	return files, nil
+}
+
+// activeProfile returns the selected profile and where the selection came from:
+// the --profile flag, then DNSCTL_PROFILE, then the last config file with a profile key.
+func activeProfile(files []configFile, flagValue string, getenv func(string) string) (string, string) {
+	if flagValue != "" {
+		return flagValue, "flag --profile"
+	}
+	if v := getenv(envPrefix + "PROFILE"); v != "" {
+		return v, "env " + envPrefix + "PROFILE"
+	}
+	for i := len(files) - 1; i >= 0; i-- {
+		if v, ok := files[i].Data["profile"]; ok {
+			return fmt.Sprint(v), files[i].Path
+		}
+	}
+	return "", ""
+}
+
+// configLayer is a set of flag values from one place in one config file.
+type configLayer struct {
+	source string
+	values map[string]string
+}
+
+// configLayers flattens files into the layers that apply to command, lowest precedence
+// first: for each file its global keys, its command section, the profile's global keys
+// and the profile's command section.
+func configLayers(files []configFile, profile, command string) ([]configLayer, error) {
+	var layers []configLayer
+	found := profile == ""
+
+	for _, f := range files {
+		layers = append(layers, scopeLayers(f.Data, f.Path, command)...)
+
+		if profile == "" {
+			continue
+		}
+		profiles, _ := f.Data["profiles"].(map[string]any)
+		p, ok := profiles[profile].(map[string]any)
+		if !ok {
+			continue
+		}
+		found = true
+		layers = append(layers, scopeLayers(p, fmt.Sprintf("%s (profile %s)", f.Path, profile), command)...)
+	}
+
+	if !found {
+		var paths []string
+		for _, f := range files {
+			paths = append(paths, f.Path)
+		}
+		if len(paths) == 0 {
+			return nil, fmt.Errorf("profile %q not found: no config file", profile)
+		}
+		return nil, fmt.Errorf("profile %q not found in %s", profile, strings.Join(paths, ", "))
+	}
+
+	return layers, nil
+}
+
+// scopeLayers returns the global and command-specific layers of one mapping.
+func scopeLayers(m map[string]any, source, command string) []configLayer {
+	global := configLayer{source: source, values: map[string]string{}}
+	for k, v := range m {
+		if k == "profile" || k == "profiles" {
+			continue
+		}
+		if _, isSection := v.(map[string]any); isSection {
+			continue
+		}
+		global.values[k] = configString(v)
+	}
+
+	layers := []configLayer{global}
+	if section, ok := m[command].(map[string]any); ok {
+		scoped := configLayer{source: fmt.Sprintf("%s [%s]", source, command), values: map[string]string{}}
+		for k, v := range section {
+			scoped.values[k] = configString(v)
+		}
+		layers = append(layers, scoped)
+	}
+	return layers
+}
+
+// configString renders a YAML value the way it would be written on the command line.
+func configString(v any) string {
+	if list, ok := v.([]any); ok {
+		parts := make([]string, len(list))
+		for i, item := range list {
+			parts[i] = fmt.Sprint(item)
+		}
+		return strings.Join(parts, ",")
+	}
+	return fmt.Sprint(v)
+}
+
+// envName returns the environment variable that overrides flag name.
+func envName(name string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// resolveConfig computes the effective value and source of every flag of a command.
+// Precedence, lowest first: flag default, config layers, DNSCTL_* environment, command line.
+func resolveConfig(flags *pflag.FlagSet, layers []configLayer, getenv func(string) string) map[string]configValue {
+	out := map[string]configValue{}
+
+	flags.VisitAll(func(f *pflag.Flag) {
+		if f.Name == "help" || f.Name == "profile" {
+			return
+		}
+
+		v := configValue{Value: f.DefValue, Source: "default"}
+		for _, l := range layers {
+			if s, ok := l.values[f.Name]; ok {
+				v = configValue{Value: s, Source: l.source}
+			}
+		}
+		if s := getenv(envName(f.Name)); s != "" {
+			v = configValue{Value: s, Source: "env " + envName(f.Name)}
+		}
+		if f.Changed {
+			v = configValue{Value: f.Value.String(), Source: "flag --" + f.Name}
+		}
+
+		out[f.Name] = v
+	})
+
+	return out
+}
+
+// configSection returns the config section of cmd: the name of its top-level command,
+// so that "ipam next" reads the ipam section.
+func configSection(cmd *cobra.Command) string {
+	for cmd.HasParent() && cmd.Parent().HasParent() {
+		cmd = cmd.Parent()
+	}
+	return cmd.Name()
+}
+
+// commandConfig loads the config files and resolves the flags of cmd.
+func commandConfig(cmd *cobra.Command) (map[string]configValue, string, string, error) {
+	files, err := loadConfigFiles(configPaths())
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	profile, profileSource := activeProfile(files, profileName, os.Getenv)
+	layers, err := configLayers(files, profile, configSection(cmd))
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	return resolveConfig(cmd.Flags(), layers, os.Getenv), profile, profileSource, nil
+}
+
+// applyConfig sets every flag of cmd that was not given on the command line from the
+// config files and environment. It runs before cobra checks for required flags, so a
+// required --file may come from the config.
+func applyConfig(cmd *cobra.Command) error {
+	values, _, _, err := commandConfig(cmd)
+	if err != nil {
+		return err
+	}
+
+	for name, v := range values {
+		if v.Source == "default" || strings.HasPrefix(v.Source, "flag ") {
+			continue
+		}
+		if err := cmd.Flags().Set(name, v.Value); err != nil {
+			return fmt.Errorf("%s: invalid value for %s: %w", v.Source, name, err)
+		}
+	}
+	return nil
+}
+
+// configCommands returns the commands below parent that take flags, depth first.
+func configCommands(parent *cobra.Command) []*cobra.Command {
+	var out []*cobra.Command
+	for _, c := range parent.Commands() {
+		if c.Hidden || c.Name() == "help" {
+			continue
+		}
+		if c.HasAvailableFlags() {
+			out = append(out, c)
+		}
+		out = append(out, configCommands(c)...)
+	}
+	return out
+}
+
+// commandName returns the path of cmd below the root command, e.g. "ipam next".
+func commandName(cmd *cobra.Command) string {
+	return strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()+" ")
+}
+
+// runConfigShow prints the effective settings of each command, or of the named commands,
+// with the source of every value. Secrets are masked. Naming a command with subcommands,
+// such as ipam, selects all of them.
+func runConfigShow(names []string, out io.Writer) error {
+	var cmds []*cobra.Command
+	for _, c := range configCommands(rootCmd) {
+		if len(names) > 0 && !slices.Contains(names, commandName(c)) && !slices.Contains(names, configSection(c)) {
+			continue
+		}
+		cmds = append(cmds, c)
+	}
+	if len(cmds) == 0 {
+		return fmt.Errorf("no command named %s", strings.Join(names, ", "))
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	header := false
+
+	for _, c := range cmds {
+		values, profile, profileSource, err := commandConfig(c)
+		if err != nil {
+			return err
+		}
+
+		if !header {
+			if profile != "" {
+				fmt.Fprintf(out, "profile: %s (%s)\n\n", profile, profileSource)
+			}
+			fmt.Fprintln(tw, "COMMAND\tSETTING\tVALUE\tSOURCE")
+			header = true
+		}
+
+		keys := make([]string, 0, len(values))
+		for k := range values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			v := values[k]
+			if secretKeys[k] && v.Value != "" {
+				v.Value = "********"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", commandName(c), k, v.Value, v.Source)
+		}
+	}
+
+	return tw.Flush()
+}
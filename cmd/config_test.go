@@ -0,0 +1,241 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+const userConfigFixture = `timeout: 5s
+workers: 4
+profile: home
+clean-zones:
+  timeout: 3s
+profiles:
+  home:
+    server: 192.168.1.53
+  lab:
+    server: 10.0.0.53
+    clean-zones:
+      workers: 32
+`
+
+const projectConfigFixture = `workers: 12
+notify-webhook:
+  - http://a.example/hook
+  - http://b.example/hook
+`
+
+// writeConfigFiles installs user and project config files and returns their paths.
+func writeConfigFiles(t *testing.T, user, project string) (string, string) {
+	t.Helper()
+
+	home := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", home)
+	userPath := filepath.Join(home, "dnsctl", "config.yaml")
+	if user != "" {
+		if err := os.MkdirAll(filepath.Dir(userPath), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(userPath, []byte(user), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	work := t.TempDir()
+	t.Chdir(work)
+	if project != "" {
+		if err := os.WriteFile(projectConfig, []byte(project), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return userPath, projectConfig
+}
+
+// testFlags returns a flag set shaped like the clean-zones flags.
+func testFlags() *pflag.FlagSet {
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	fs.Duration("timeout", 2*time.Second, "")
+	fs.Int("workers", 8, "")
+	fs.String("server", "", "")
+	fs.StringSlice("notify-webhook", nil, "")
+	fs.String("tsig-secret", "", "")
+	return fs
+}
+
+func noEnv(string) string { return "" }
+
+func TestConfigLayers_Precedence(t *testing.T) {
+	writeConfigFiles(t, userConfigFixture, projectConfigFixture)
+
+	files, err := loadConfigFiles(configPaths())
+	if err != nil {
+		t.Fatalf("loadConfigFiles returned error: %v", err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("loaded %d files, want 2", len(files))
+	}
+
+	profile, source := activeProfile(files, "", noEnv)
+	if profile != "home" || !strings.HasSuffix(source, "config.yaml") {
+		t.Fatalf("activeProfile = %q from %q, want home from the user config", profile, source)
+	}
+
+	layers, err := configLayers(files, "lab", "clean-zones")
+	if err != nil {
+		t.Fatalf("configLayers returned error: %v", err)
+	}
+
+	fs := testFlags()
+	values := resolveConfig(fs, layers, noEnv)
+
+	tests := map[string]configValue{
+		"timeout":        {"3s", "[clean-zones]"},
+		"workers":        {"12", ".dnsctl.yaml"},
+		"server":         {"10.0.0.53", "(profile lab)"},
+		"notify-webhook": {"http://a.example/hook,http://b.example/hook", ".dnsctl.yaml"},
+		"tsig-secret":    {"", "default"},
+	}
+	for name, want := range tests {
+		got := values[name]
+		if got.Value != want.Value || !strings.Contains(got.Source, want.Source) {
+			t.Errorf("%s = %q from %q, want %q from ...%s", name, got.Value, got.Source, want.Value, want.Source)
+		}
+	}
+
+	// the lab profile's clean-zones section in the user file loses to the project file's global key
+	if values["workers"].Value != "12" {
+		t.Errorf("project config should override the user profile, got workers = %s", values["workers"].Value)
+	}
+}
+
+func TestResolveConfig_EnvAndFlags(t *testing.T) {
+	layers := []configLayer{{source: "file", values: map[string]string{"timeout": "3s", "workers": "16"}}}
+	env := map[string]string{"DNSCTL_WORKERS": "20", "DNSCTL_TSIG_SECRET": "s3cret"}
+
+	fs := testFlags()
+	if err := fs.Parse([]string{"--timeout", "9s"}); err != nil {
+		t.Fatal(err)
+	}
+
+	values := resolveConfig(fs, layers, func(k string) string { return env[k] })
+
+	if v := values["timeout"]; v.Value != "9s" || v.Source != "flag --timeout" {
+		t.Errorf("timeout = %+v, want the command line value", v)
+	}
+	if v := values["workers"]; v.Value != "20" || v.Source != "env DNSCTL_WORKERS" {
+		t.Errorf("workers = %+v, want the environment value", v)
+	}
+	if v := values["tsig-secret"]; v.Value != "s3cret" {
+		t.Errorf("tsig-secret = %+v, want the environment value", v)
+	}
+}
+
+func TestActiveProfile(t *testing.T) {
+	files := []configFile{{Path: "a", Data: map[string]any{"profile": "one"}}, {Path: "b", Data: map[string]any{"profile": "two"}}}
+	env := func(k string) string {
+		if k == "DNSCTL_PROFILE" {
+			return "three"
+		}
+		return ""
+	}
+
+	if p, _ := activeProfile(files, "", noEnv); p != "two" {
+		t.Errorf("later file should win, got %q", p)
+	}
+	if p, _ := activeProfile(files, "", env); p != "three" {
+		t.Errorf("DNSCTL_PROFILE should win over files, got %q", p)
+	}
+	if p, _ := activeProfile(files, "four", env); p != "four" {
+		t.Errorf("--profile should win over everything, got %q", p)
+	}
+}
+
+func TestConfigLayers_UnknownProfile(t *testing.T) {
+	files := []configFile{{Path: "a.yaml", Data: map[string]any{}}}
+	if _, err := configLayers(files, "missing", "plan"); err == nil || !strings.Contains(err.Error(), `"missing" not found in a.yaml`) {
+		t.Fatalf("configLayers error = %v", err)
+	}
+}
+
+func TestApplyConfig(t *testing.T) {
+	writeConfigFiles(t, "", "clean-zones:\n  file: zones.yaml\n  workers: many\n")
+
+	var f string
+	var w int
+	cmd := &cobra.Command{Use: "clean-zones"}
+	cmd.Flags().StringVar(&f, "file", "", "")
+	cmd.Flags().IntVar(&w, "workers", 8, "")
+
+	err := applyConfig(cmd)
+	if err == nil || !strings.Contains(err.Error(), "invalid value for workers") {
+		t.Fatalf("applyConfig error = %v, want invalid workers", err)
+	}
+
+	if err := os.WriteFile(projectConfig, []byte("clean-zones:\n  file: zones.yaml\n  workers: 3\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyConfig(cmd); err != nil {
+		t.Fatalf("applyConfig returned error: %v", err)
+	}
+	if f != "zones.yaml" || w != 3 {
+		t.Fatalf("file = %q, workers = %d; want zones.yaml, 3", f, w)
+	}
+	if !cmd.Flags().Changed("file") {
+		t.Fatalf("config values must satisfy required flag checks")
+	}
+}
+
+func TestRunConfigShow(t *testing.T) {
+	writeConfigFiles(t, "", "tsig-secret: hunter2\nplan:\n  server: 10.0.0.53\n")
+
+	var out bytes.Buffer
+	if err := runConfigShow([]string{"plan"}, &out); err != nil {
+		t.Fatalf("runConfigShow returned error: %v", err)
+	}
+
+	text := out.String()
+	if strings.Contains(text, "hunter2") {
+		t.Fatalf("config show leaks secrets:\n%s", text)
+	}
+	for _, want := range []string{"COMMAND", "tsig-secret", "********", ".dnsctl.yaml [plan]", "provider"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("config show missing %q:\n%s", want, text)
+		}
+	}
+	if strings.Contains(text, "clean-zones") {
+		t.Errorf("config show plan should only list plan:\n%s", text)
+	}
+
+	if err := runConfigShow([]string{"nope"}, &out); err == nil {
+		t.Errorf("runConfigShow should reject unknown commands")
+	}
+}
+
+func TestRunConfigShow_Subcommands(t *testing.T) {
+	writeConfigFiles(t, "", "ipam:\n  reserve: [10.0.1.1-10.0.1.19, 10.0.1.254]\n")
+
+	if got := configSection(ipamNextCmd); got != "ipam" {
+		t.Fatalf("configSection(ipam next) = %q, want ipam", got)
+	}
+
+	var out bytes.Buffer
+	if err := runConfigShow([]string{"ipam"}, &out); err != nil {
+		t.Fatalf("runConfigShow returned error: %v", err)
+	}
+
+	text := out.String()
+	if !strings.Contains(text, "ipam next") || !strings.Contains(text, "10.0.1.1-10.0.1.19,10.0.1.254") || !strings.Contains(text, ".dnsctl.yaml [ipam]") {
+		t.Fatalf("config show ipam should list the ipam next settings from the ipam section:\n%s", text)
+	}
+	if strings.Contains(text, "record add") {
+		t.Errorf("config show ipam should only list ipam commands:\n%s", text)
+	}
+}
@@ -8,11 +8,11 @@ import (
 )
 
 // createMissingPTRs generates reverse DNS (PTR) records for existing A records in the YAML.
-// It automatically creates PTR entries for IPs in the 10.0.0.0/8 range.
-func createMissingPTRs(root *ast.MappingNode) {
+// It automatically creates PTR entries for IPs in the 10.0.0.0/8 range and returns them.
+func createMissingPTRs(root *ast.MappingNode) ([]dnsRecord, error) {
 	dnsNode := mappingValue(root, "dns_records")
 	if dnsNode == nil {
-		return
+		return nil, nil
 	}
 
 	seq := dnsNode.(*ast.SequenceNode)
@@ -26,6 +26,7 @@ func createMissingPTRs(root *ast.MappingNode) {
 		}
 	}
 
+	var added []dnsRecord
 	for _, item := range seq.Values {
 		m := item.(*ast.MappingNode)
 		if stringValue(m, "type") != "A" {
@@ -54,15 +55,25 @@ func createMissingPTRs(root *ast.MappingNode) {
 			continue
 		}
 
-		ptr := &ast.MappingNode{
-			Values: []*ast.MappingValueNode{
-				kv("host", stringValue(m, "host")),
-				kv("type", "PTR"),
-				kv("zone", zone),
-				kv("record_value", last),
-			},
+		ptr, err := appendMapping(root, "dns_records", [][2]string{
+			{"host", stringValue(m, "host")},
+			{"type", "PTR"},
+			{"zone", zone},
+			{"record_value", last},
+		})
+		if err != nil {
+			return nil, err
 		}
-
-		seq.Values = append(seq.Values, ptr)
+		existing[key] = true
+		added = append(added, dnsRecord{
+			Host:    stringValue(m, "host"),
+			Type:    "PTR",
+			Zone:    zone,
+			Value:   last,
+			Section: "dns_records",
+			Node:    ptr,
+		})
 	}
+
+	return added, nil
 }
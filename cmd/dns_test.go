@@ -87,6 +87,22 @@ func TestCollectNameserverJobs_PrefixKeyIncluded(t *testing.T) {
 	}
 }
 
+func TestCollectNameserverJobs_SkipsNonListSections(t *testing.T) {
+	root, err := parseMapping(`nameservers_tsig: example-key
+nameservers:
+  - ip_address: 10.0.0.53
+  - just-a-string
+`)
+	if err != nil {
+		t.Fatalf("parseMapping returned error: %v", err)
+	}
+
+	jobs := collectNameserverJobs(root)
+	if len(jobs) != 1 || jobs[0].IP != "10.0.0.53" {
+		t.Fatalf("collectNameserverJobs = %+v, want only 10.0.0.53", jobs)
+	}
+}
+
 func TestCollectDNSRecordJobs_EmptyRoot(t *testing.T) {
 	root := &ast.MappingNode{Values: []*ast.MappingValueNode{}}
 
@@ -209,3 +225,35 @@ func TestCreateMissingPTRs_DoesNotDuplicateExistingPTR(t *testing.T) {
 		t.Fatalf("createMissingPTRs created duplicate PTR: got %d records, want %d", len(seq.Values), before)
 	}
 }
+
+func TestCollectJobs_Keep(t *testing.T) {
+	path := writeInventory(t, `nameservers:
+  - name: ns1 # dnsctl:keep
+    ip_address: 10.0.0.53
+  - name: ns2
+    ip_address: 10.0.0.54
+dns_records:
+  - host: web01
+    type: A
+    record_value: 10.0.1.5
+  # dnsctl:keep
+  - host: vip
+    type: A
+    record_value: 10.0.1.9
+`)
+	_, root, err := loadInventory(path)
+	if err != nil {
+		t.Fatalf("loadInventory returned error: %v", err)
+	}
+
+	got := map[string]bool{}
+	for _, j := range append(collectNameserverJobs(root), collectDNSRecordJobs(root)...) {
+		got[j.IP] = j.Keep
+	}
+	want := map[string]bool{"10.0.0.53": true, "10.0.0.54": false, "10.0.1.5": false, "10.0.1.9": true}
+	for ip, keep := range want {
+		if got[ip] != keep {
+			t.Errorf("job %s Keep = %v, want %v", ip, got[ip], keep)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// driftOptions configures the drift and pull commands.
+type driftOptions struct {
+	File    string
+	Servers []string
+	Zones   []string
+	TSIG    tsigConfig
+	Timeout time.Duration
+	IXFR    bool
+	// Write appends records found only on the servers to dns_records.
+	Write bool
+}
+
+// zoneDrift holds the comparison of one zone on one server against the inventory.
+type zoneDrift struct {
+	Zone       string
+	Server     string
+	ServerOnly []resourceRecord
+	// Disabled holds served records that the inventory lists but has disabled.
+	Disabled   []resourceRecord
+	YAMLOnly   []resourceRecord
+	TTLDiffers []recordChange
+}
+
+// empty reports whether the server and inventory agree.
+func (d zoneDrift) empty() bool {
+	return len(d.ServerOnly) == 0 && len(d.Disabled) == 0 && len(d.YAMLOnly) == 0 && len(d.TTLDiffers) == 0
+}
+
+// compareZone classifies the differences between the inventory and a server's records.
+// Served records matching one of the disabled inventory records are reported as
+// disabled rather than as server only, so that pull does not add them back.
+func compareZone(zone, server string, desired, disabled, current []resourceRecord) zoneDrift {
+	d := zoneDrift{Zone: zone, Server: server}
+
+	off := map[string]bool{}
+	for _, r := range disabled {
+		off[r.key()] = true
+	}
+
+	for _, c := range diffRecords(desired, current) {
+		switch c.Action {
+		case changeCreate:
+			d.YAMLOnly = append(d.YAMLOnly, c.New)
+		case changeDelete:
+			if off[c.Old.key()] {
+				d.Disabled = append(d.Disabled, c.Old)
+				continue
+			}
+			d.ServerOnly = append(d.ServerOnly, c.Old)
+		case changeUpdate:
+			d.TTLDiffers = append(d.TTLDiffers, c)
+		}
+	}
+
+	return d
+}
+
+// printDrift writes a human-readable drift report.
+func printDrift(w io.Writer, d zoneDrift) {
+	fmt.Fprintf(w, "zone %s @ %s:\n", d.Zone, d.Server)
+
+	if d.empty() {
+		fmt.Fprintln(w, "  in sync")
+		return
+	}
+
+	for _, r := range d.ServerOnly {
+		fmt.Fprintf(w, "  server only: %s\n", r)
+	}
+	for _, r := range d.Disabled {
+		fmt.Fprintf(w, "  disabled in inventory: %s\n", r)
+	}
+	for _, r := range d.YAMLOnly {
+		fmt.Fprintf(w, "  yaml only:   %s\n", r)
+	}
+	for _, c := range d.TTLDiffers {
+		fmt.Fprintf(w, "  ttl differs: %s (server ttl %d)\n", c.New, c.Old.TTL)
+	}
+}
+
+// disabledRecords returns the disabled inventory records of zone in server form.
+// Disabled entries are not validated, so the ones that cannot be converted are skipped.
+func disabledRecords(records []dnsRecord, zone string) []resourceRecord {
+	zones := hostZones(records)
+
+	var out []resourceRecord
+	for _, rec := range records {
+		if !rec.Disabled || rec.Zone != zone || !managedTypes[rec.Type] {
+			continue
+		}
+
+		rr, err := rrFromRecord(rec, zones)
+		if err != nil {
+			continue
+		}
+		out = append(out, resourceFromRR(rr))
+	}
+	return out
+}
+
+// driftServers returns the servers to compare against: the explicit list if given,
+// otherwise every enabled nameserver from the inventory.
+func driftServers(explicit []string, nameservers []nameserver) []string {
+	if len(explicit) > 0 {
+		return explicit
+	}
+
+	var out []string
+	for _, ns := range nameservers {
+		if ns.Disabled {
+			continue
+		}
+		out = append(out, ns.IP)
+	}
+	return out
+}
+
+// runDrift transfers each zone from each nameserver and reports differences against the
+// inventory. With Write set, records present only on a server are added to the inventory.
+func runDrift(opts driftOptions, out io.Writer) error {
+	file, root, err := loadInventory(opts.File)
+	if err != nil {
+		return err
+	}
+
+	// pulling into stdin turns pull into a filter: the inventory goes to stdout, the report to stderr
+	report := out
+	if opts.Write && opts.File == stdinPath {
+		report = os.Stderr
+	}
+
+	records := collectRecords(root)
+
+	servers := driftServers(opts.Servers, collectNameservers(root))
+	if len(servers) == 0 {
+		return fmt.Errorf("no nameservers to query: add a nameservers section or pass --server")
+	}
+
+	zones := opts.Zones
+	if len(zones) == 0 {
+		zones = recordZones(records)
+	}
+
+	var added int
+	seen := map[string]bool{}
+
+	for _, zone := range zones {
+		zone = canonicalZone(zone)
+
+		desired, err := desiredRecords(records, zone)
+		if err != nil {
+			return err
+		}
+		disabled := disabledRecords(records, zone)
+
+		for _, srv := range servers {
+			client := &rfc2136Client{
+				Server:  srv,
+				TSIG:    opts.TSIG,
+				Timeout: opts.Timeout,
+				IXFR:    opts.IXFR,
+			}
+
+			current, err := client.ListRecords(zone)
+			if err != nil {
+				return err
+			}
+
+			d := compareZone(zone, srv, desired, disabled, current)
+			printDrift(report, d)
+
+			if !opts.Write {
+				continue
+			}
+
+			for _, r := range d.ServerOnly {
+				if seen[r.key()] {
+					continue
+				}
+				seen[r.key()] = true
+
+				if _, err := appendMapping(root, "dns_records", inventoryFields(r, zone)); err != nil {
+					return err
+				}
+				added++
+			}
+		}
+	}
+
+	if !opts.Write {
+		return nil
+	}
+
+	if added == 0 {
+		fmt.Fprintln(report, "# inventory already contains every served record")
+		if opts.File != stdinPath {
+			return nil
+		}
+	}
+
+	if err := saveInventory(opts.File, file); err != nil {
+		return err
+	}
+	if added > 0 {
+		fmt.Fprintf(report, "# added %d record(s) to %s\n", added, opts.File)
+	}
+	return nil
+}
+
+// inventoryFields converts a served record into dns_records fields for zone.
+// PTR records follow the inventory convention of host = target, record_value = label.
+func inventoryFields(r resourceRecord, zone string) [][2]string {
+	label := "@"
+	if r.Name != zone {
+		label = strings.TrimSuffix(r.Name, "."+zone)
+	}
+
+	host, value := label, r.Data
+	if r.Type == "PTR" {
+		host, value = r.Data, label
+	}
+
+	fields := [][2]string{
+		{"host", host},
+		{"type", r.Type},
+		{"zone", zone},
+		{"record_value", value},
+	}
+	if r.TTL != defaultTTL {
+		fields = append(fields, [2]string{"ttl", strconv.FormatUint(uint64(r.TTL), 10)})
+	}
+
+	return fields
+}
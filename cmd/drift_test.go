@@ -0,0 +1,206 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompareZone(t *testing.T) {
+	desired := []resourceRecord{
+		{Name: "a.lan.", Type: "A", TTL: 3600, Data: "10.0.0.1"},
+		{Name: "b.lan.", Type: "A", TTL: 3600, Data: "10.0.0.2"},
+	}
+	current := []resourceRecord{
+		{Name: "b.lan.", Type: "A", TTL: 60, Data: "10.0.0.2"},
+		{Name: "c.lan.", Type: "A", TTL: 3600, Data: "10.0.0.3"},
+	}
+
+	d := compareZone("lan.", "ns1", desired, nil, current)
+	if len(d.YAMLOnly) != 1 || d.YAMLOnly[0].Name != "a.lan." {
+		t.Fatalf("YAMLOnly = %v, want a.lan.", d.YAMLOnly)
+	}
+	if len(d.ServerOnly) != 1 || d.ServerOnly[0].Name != "c.lan." {
+		t.Fatalf("ServerOnly = %v, want c.lan.", d.ServerOnly)
+	}
+	if len(d.TTLDiffers) != 1 || d.TTLDiffers[0].Old.TTL != 60 {
+		t.Fatalf("TTLDiffers = %v, want b.lan. with server ttl 60", d.TTLDiffers)
+	}
+}
+
+func TestCompareZone_DisabledInInventory(t *testing.T) {
+	disabled := []resourceRecord{{Name: "c.lan.", Type: "A", TTL: 3600, Data: "10.0.0.3"}}
+	current := []resourceRecord{{Name: "c.lan.", Type: "A", TTL: 300, Data: "10.0.0.3"}}
+
+	d := compareZone("lan.", "ns1", nil, disabled, current)
+	if len(d.ServerOnly) != 0 {
+		t.Fatalf("ServerOnly = %v, want none", d.ServerOnly)
+	}
+	if len(d.Disabled) != 1 || d.Disabled[0].Name != "c.lan." {
+		t.Fatalf("Disabled = %v, want c.lan.", d.Disabled)
+	}
+}
+
+func TestDriftServers(t *testing.T) {
+	nss := []nameserver{{IP: "10.0.0.53"}, {IP: "10.0.0.54", Disabled: true}}
+
+	if got := driftServers(nil, nss); len(got) != 1 || got[0] != "10.0.0.53" {
+		t.Fatalf("driftServers = %v, want [10.0.0.53]", got)
+	}
+	if got := driftServers([]string{"127.0.0.1:5353"}, nss); len(got) != 1 || got[0] != "127.0.0.1:5353" {
+		t.Fatalf("driftServers with explicit list = %v, want [127.0.0.1:5353]", got)
+	}
+}
+
+func TestInventoryFields(t *testing.T) {
+	a := inventoryFields(resourceRecord{Name: "web.lan.", Type: "A", TTL: 300, Data: "10.0.0.1"}, "lan.")
+	if a[0][1] != "web" || a[3][1] != "10.0.0.1" || len(a) != 5 || a[4][1] != "300" {
+		t.Fatalf("A fields = %v", a)
+	}
+
+	ptr := inventoryFields(resourceRecord{Name: "5.0.0.10.in-addr.arpa.", Type: "PTR", TTL: defaultTTL, Data: "web.lan."}, "0.0.10.in-addr.arpa.")
+	if ptr[0][1] != "web.lan." || ptr[3][1] != "5" || len(ptr) != 4 {
+		t.Fatalf("PTR fields = %v", ptr)
+	}
+
+	apex := inventoryFields(resourceRecord{Name: "lan.", Type: "MX", TTL: defaultTTL, Data: "10 mail.lan."}, "lan.")
+	if apex[0][1] != "@" {
+		t.Fatalf("apex host = %q, want @", apex[0][1])
+	}
+}
+
+func TestRunDrift_NoServers(t *testing.T) {
+	path := writeInventory(t, "dns_records: []\n")
+	if err := runDrift(driftOptions{File: path}, &bytes.Buffer{}); err == nil {
+		t.Fatalf("runDrift without nameservers returned nil, want error")
+	}
+}
+
+func TestRunDrift_ReportsAndWrites(t *testing.T) {
+	fs := startFakeZoneServer(t, "example.lan.", tsigConfig{},
+		"web01.example.lan. 3600 IN A 10.0.1.5",
+		"web03.example.lan. 300 IN A 10.0.1.7",
+	)
+
+	path := writeInventory(t, `# inventory
+dns_records:
+  # web tier
+  - host: web01
+    type: A
+    zone: example.lan.
+    record_value: 10.0.1.5
+  - host: web02
+    type: A
+    zone: example.lan.
+    record_value: 10.0.1.6
+`)
+
+	var out bytes.Buffer
+	opts := driftOptions{File: path, Servers: []string{fs.addr}, Timeout: 2 * time.Second}
+	if err := runDrift(opts, &out); err != nil {
+		t.Fatalf("runDrift returned error: %v", err)
+	}
+	for _, want := range []string{
+		"server only: web03.example.lan. 300 IN A 10.0.1.7",
+		"yaml only:   web02.example.lan. 3600 IN A 10.0.1.6",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("drift output missing %q:\n%s", want, out.String())
+		}
+	}
+
+	opts.Write = true
+	out.Reset()
+	if err := runDrift(opts, &out); err != nil {
+		t.Fatalf("runDrift with write returned error: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read written inventory: %v", err)
+	}
+	written := string(data)
+	for _, want := range []string{"# inventory", "# web tier", "host: web03", "ttl: 300"} {
+		if !strings.Contains(written, want) {
+			t.Fatalf("written inventory missing %q:\n%s", want, written)
+		}
+	}
+
+	_, root, err := loadInventory(path)
+	if err != nil {
+		t.Fatalf("written inventory does not parse: %v", err)
+	}
+	if got := len(collectRecords(root)); got != 3 {
+		t.Fatalf("written inventory has %d records, want 3", got)
+	}
+}
+
+func TestRunPull_KeepsDisabledRecordsDisabled(t *testing.T) {
+	fs := startFakeZoneServer(t, "example.lan.", tsigConfig{},
+		"web01.example.lan. 3600 IN A 10.0.1.5",
+		"web02.example.lan. 3600 IN A 10.0.1.6",
+	)
+
+	path := writeInventory(t, `dns_records:
+  - host: web01
+    type: A
+    zone: example.lan.
+    record_value: 10.0.1.5
+  - host: web02 # DISABLED: unreachable
+    type: A
+    zone: example.lan.
+    record_value: 10.0.1.6
+`)
+
+	var out bytes.Buffer
+	opts := driftOptions{File: path, Servers: []string{fs.addr}, Timeout: 2 * time.Second, Write: true}
+	if err := runDrift(opts, &out); err != nil {
+		t.Fatalf("runDrift returned error: %v", err)
+	}
+	if !strings.Contains(out.String(), "disabled in inventory: web02.example.lan. 3600 IN A 10.0.1.6") {
+		t.Fatalf("drift output does not report the disabled record:\n%s", out.String())
+	}
+
+	_, root, err := loadInventory(path)
+	if err != nil {
+		t.Fatalf("written inventory does not parse: %v", err)
+	}
+	records := collectRecords(root)
+	if len(records) != 2 || !records[1].Disabled {
+		t.Fatalf("pull must not add a live copy of a disabled record: %+v", records)
+	}
+}
+
+func TestRunPull_Stdin(t *testing.T) {
+	fs := startFakeZoneServer(t, "example.lan.", tsigConfig{},
+		"web01.example.lan. 3600 IN A 10.0.1.5",
+		"web03.example.lan. 3600 IN A 10.0.1.7",
+	)
+	stubStdin(t, `dns_records:
+  - host: web01
+    type: A
+    zone: example.lan.
+    record_value: 10.0.1.5
+`)
+
+	var report bytes.Buffer
+	opts := driftOptions{File: "-", Servers: []string{fs.addr}, Timeout: 2 * time.Second, Write: true}
+	stdout, err := captureStdout(t, func() error { return runDrift(opts, &report) })
+	if err != nil {
+		t.Fatalf("runDrift(-) returned error: %v", err)
+	}
+
+	if report.Len() != 0 {
+		t.Fatalf("filter mode should keep the report off stdout, got %q", report.String())
+	}
+
+	root, err := parseMapping(stdout)
+	if err != nil {
+		t.Fatalf("stdout is not YAML: %v\n%s", err, stdout)
+	}
+	if got := len(collectRecords(root)); got != 2 {
+		t.Fatalf("pulled inventory has %d records, want 2:\n%s", got, stdout)
+	}
+}
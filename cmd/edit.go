@@ -0,0 +1,457 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-yaml/ast"
+	"github.com/miekg/dns"
+)
+
+// editSection is the record section that record add, rm and set operate on.
+const editSection = "dns_records"
+
+// recordOptions identifies an inventory record and the values to give it.
+type recordOptions struct {
+	File  string
+	Host  string
+	Type  string
+	Zone  string
+	Value string
+	// TTL is written as a ttl key when non-zero.
+	TTL uint32
+	// PTR also adds, removes or updates the reverse record of an A or AAAA record.
+	PTR bool
+}
+
+// record returns the record described by opts, normalized like collectRecords would.
+func (o recordOptions) record() dnsRecord {
+	rec := dnsRecord{
+		Host:    o.Host,
+		Type:    strings.ToUpper(o.Type),
+		Zone:    canonicalZone(o.Zone),
+		Value:   o.Value,
+		TTL:     defaultTTL,
+		Section: editSection,
+	}
+	if o.TTL > 0 {
+		rec.TTL = o.TTL
+	}
+	return rec
+}
+
+// validate checks that the record can be identified and is a type dnsctl manages.
+func (o recordOptions) validate() error {
+	var missing []string
+	for _, f := range [][2]string{{"--host", o.Host}, {"--type", o.Type}, {"--zone", o.Zone}} {
+		if f[1] == "" {
+			missing = append(missing, f[0])
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%s required", strings.Join(missing, ", "))
+	}
+
+	t := strings.ToUpper(o.Type)
+	if !managedTypes[t] {
+		return fmt.Errorf("unsupported record type %s", t)
+	}
+	if o.PTR && t != "A" && t != "AAAA" {
+		return fmt.Errorf("--ptr only applies to A and AAAA records")
+	}
+	return nil
+}
+
+// recordFields returns the dns_records fields of rec, with a ttl key if ttl is set.
+func recordFields(rec dnsRecord, ttl uint32) [][2]string {
+	fields := [][2]string{
+		{"host", rec.Host},
+		{"type", rec.Type},
+		{"zone", rec.Zone},
+		{"record_value", rec.Value},
+	}
+	if ttl > 0 {
+		fields = append(fields, [2]string{"ttl", strconv.FormatUint(uint64(ttl), 10)})
+	}
+	return fields
+}
+
+// recordText describes rec for reports as owner name, type and data.
+func recordText(rec dnsRecord) string {
+	data := rec.Value
+	if rec.Type == "PTR" {
+		data = rec.Host
+	}
+	return rec.Name() + " " + rec.Type + " " + data
+}
+
+// recordKey returns the normalized identity of rec, or "" if the record does not parse.
+func recordKey(rec dnsRecord, zones map[string]string) string {
+	rr, err := rrFromRecord(rec, zones)
+	if err != nil {
+		return ""
+	}
+	return resourceFromRR(rr).key()
+}
+
+// recordMatch is an entry of the edit section and its position in the sequence.
+type recordMatch struct {
+	rec dnsRecord
+	idx int
+}
+
+// findRecords returns the entries of the edit section with the owner name and type of
+// rec. With matchValue set, only entries with the same normalized value are returned.
+// PTR records are named by their value, so they are always looked up by name alone.
+func findRecords(root *ast.MappingNode, rec dnsRecord, matchValue bool) []recordMatch {
+	seq, ok := mappingValue(root, editSection).(*ast.SequenceNode)
+	if !ok {
+		return nil
+	}
+
+	zones := hostZones(collectRecords(root))
+	want := ""
+	if matchValue {
+		want = recordKey(rec, zones)
+	}
+
+	var out []recordMatch
+	for i, item := range seq.Values {
+		m, ok := item.(*ast.MappingNode)
+		if !ok {
+			continue
+		}
+		r := recordFromNode(m, editSection)
+		if r.Type != rec.Type || r.Name() != rec.Name() {
+			continue
+		}
+		if want != "" && recordKey(r, zones) != want {
+			continue
+		}
+		out = append(out, recordMatch{rec: r, idx: i})
+	}
+	return out
+}
+
+// removeRecords deletes the matched entries from the edit section.
+func removeRecords(root *ast.MappingNode, matches []recordMatch) {
+	seq := mappingValue(root, editSection).(*ast.SequenceNode)
+
+	// remove from the end so that the remaining indexes stay valid
+	sort.Slice(matches, func(i, j int) bool { return matches[i].idx > matches[j].idx })
+	for _, m := range matches {
+		removeSequenceItem(seq, m.idx)
+	}
+}
+
+// reverseZone returns the reverse zone and record_value of the PTR record for ip. The
+// most specific reverse zone already in the inventory is used; IPv4 addresses outside
+// every known zone fall back to their /24 zone, the layout clean-zones writes.
+func reverseZone(records []dnsRecord, ip string) (string, string, error) {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return "", "", fmt.Errorf("%q is not an IP address", ip)
+	}
+
+	arpa, err := dns.ReverseAddr(ip)
+	if err != nil {
+		return "", "", err
+	}
+
+	if zone, ok := coveringZone(recordZones(records), arpa); ok {
+		return zone, strings.TrimSuffix(arpa, "."+zone), nil
+	}
+
+	if ip4 := addr.To4(); ip4 != nil {
+		zone := fmt.Sprintf("%d.%d.%d.in-addr.arpa.", ip4[2], ip4[1], ip4[0])
+		return zone, strconv.Itoa(int(ip4[3])), nil
+	}
+	return "", "", fmt.Errorf("no reverse zone in the inventory covers %s", ip)
+}
+
+// coveringZone returns the most specific of zones that contains the owner name.
+func coveringZone(zones []string, name string) (string, bool) {
+	best := ""
+	for _, z := range zones {
+		if strings.HasSuffix(name, "."+z) && len(z) > len(best) {
+			best = z
+		}
+	}
+	return best, best != ""
+}
+
+// ptrRecord returns the PTR record that points rec's address back at its host.
+func ptrRecord(records []dnsRecord, rec dnsRecord) (dnsRecord, error) {
+	zone, label, err := reverseZone(records, rec.Value)
+	if err != nil {
+		return dnsRecord{}, err
+	}
+	return dnsRecord{Host: rec.Host, Type: "PTR", Zone: zone, Value: label, TTL: defaultTTL, Section: editSection}, nil
+}
+
+// pointsAt reports whether the PTR record ptr targets the host of rec.
+func pointsAt(ptr, rec dnsRecord) bool {
+	target := strings.TrimSuffix(strings.ToLower(ptr.Host), ".")
+	host := strings.ToLower(rec.Host)
+	return target == host || target == strings.TrimSuffix(rec.Name(), ".")
+}
+
+// reversePTRs returns the PTR record for rec's address, the existing PTR entries for
+// the address that point at rec's host and those that point elsewhere.
+func reversePTRs(root *ast.MappingNode, rec dnsRecord) (dnsRecord, []recordMatch, []recordMatch, error) {
+	ptr, err := ptrRecord(collectRecords(root), rec)
+	if err != nil {
+		return dnsRecord{}, nil, nil, err
+	}
+
+	var own, other []recordMatch
+	for _, m := range findRecords(root, dnsRecord{Type: "PTR", Zone: ptr.Zone, Value: ptr.Value}, false) {
+		if pointsAt(m.rec, rec) {
+			own = append(own, m)
+		} else {
+			other = append(other, m)
+		}
+	}
+	return ptr, own, other, nil
+}
+
+// editInventory loads the inventory, applies edit, bumps the SOA serial of the zones it
+// changed and writes the result back. Changes are reported on out, or on stderr when the
+// inventory goes to stdout.
+func editInventory(path string, out io.Writer, edit func(root *ast.MappingNode, report io.Writer) error) error {
+	file, root, err := loadInventory(path)
+	if err != nil {
+		return err
+	}
+
+	report := out
+	if path == stdinPath {
+		report = os.Stderr
+	}
+
+	// a malformed soa section would otherwise only fail after the edit
+	if _, err := collectSOA(root); err != nil {
+		return err
+	}
+
+	before := collectRecords(root)
+	if err := edit(root, report); err != nil {
+		return err
+	}
+
+	bumps, err := bumpSerials(root, changedZones(before, collectRecords(root)), time.Now())
+	if err != nil {
+		return err
+	}
+	for _, b := range bumps {
+		fmt.Fprintf(report, "bumped %s\n", b)
+	}
+	return saveInventory(path, file)
+}
+
+// runRecordAdd adds a record to dns_records, and its PTR record if requested.
+// A record with the same name, type and value is rejected, even if it is disabled.
+func runRecordAdd(opts recordOptions, out io.Writer) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	if opts.Value == "" {
+		return fmt.Errorf("--value required")
+	}
+
+	return editInventory(opts.File, out, func(root *ast.MappingNode, report io.Writer) error {
+		return addRecord(root, opts, report)
+	})
+}
+
+// addRecord adds the record described by opts to root, and its PTR record if requested.
+func addRecord(root *ast.MappingNode, opts recordOptions, report io.Writer) error {
+	rec := opts.record()
+	records := collectRecords(root)
+	zones := hostZones(append(records, rec))
+
+	rr, err := rrFromRecord(rec, zones)
+	if err != nil {
+		return err
+	}
+	key := resourceFromRR(rr).key()
+	for _, r := range records {
+		if recordKey(r, zones) != key {
+			continue
+		}
+		if r.Disabled {
+			return fmt.Errorf("%s %s %s already exists in %s (disabled)", rec.Name(), rec.Type, rec.Value, r.Section)
+		}
+		return fmt.Errorf("%s %s %s already exists in %s", rec.Name(), rec.Type, rec.Value, r.Section)
+	}
+
+	var ptr dnsRecord
+	var existing []recordMatch
+	if opts.PTR {
+		var other []recordMatch
+		if ptr, existing, other, err = reversePTRs(root, rec); err != nil {
+			return err
+		}
+		if len(other) > 0 {
+			return fmt.Errorf("%s already has a PTR record for %s", rec.Value, other[0].rec.Host)
+		}
+	}
+
+	if _, err := appendMapping(root, editSection, recordFields(rec, opts.TTL)); err != nil {
+		return err
+	}
+	fmt.Fprintf(report, "added %s\n", recordText(rec))
+
+	if !opts.PTR {
+		return nil
+	}
+	if len(existing) > 0 {
+		fmt.Fprintf(report, "%s already exists\n", recordText(ptr))
+		return nil
+	}
+	if _, err := appendMapping(root, editSection, recordFields(ptr, 0)); err != nil {
+		return err
+	}
+	fmt.Fprintf(report, "added %s\n", recordText(ptr))
+	return nil
+}
+
+// runRecordRemove deletes a record from dns_records, and the PTR records pointing at it
+// if requested. Without --value the host, type and zone must match exactly one record.
+func runRecordRemove(opts recordOptions, out io.Writer) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+
+	rec := opts.record()
+	return editInventory(opts.File, out, func(root *ast.MappingNode, report io.Writer) error {
+		matches, err := uniqueRecord(root, rec, "pass --value to pick one")
+		if err != nil {
+			return err
+		}
+
+		remove := matches
+		if opts.PTR {
+			_, own, _, err := reversePTRs(root, matches[0].rec)
+			if err != nil {
+				return err
+			}
+			remove = append(remove, own...)
+		}
+
+		for _, m := range remove {
+			fmt.Fprintf(report, "removed %s\n", recordText(m.rec))
+		}
+		removeRecords(root, remove)
+		return nil
+	})
+}
+
+// runRecordSet changes the value or TTL of the single dns_records entry with the given
+// host, type and zone, keeping its comments. With PTR set, the reverse record moves to
+// the new address.
+func runRecordSet(opts recordOptions, out io.Writer) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	if opts.Value == "" && opts.TTL == 0 {
+		return fmt.Errorf("nothing to change: pass --value or --ttl")
+	}
+
+	lookup := opts.record()
+	lookup.Value = ""
+
+	return editInventory(opts.File, out, func(root *ast.MappingNode, report io.Writer) error {
+		matches, err := uniqueRecord(root, lookup, "remove the old value with record rm --value and add the new one")
+		if err != nil {
+			return err
+		}
+		old := matches[0].rec
+
+		next := old
+		if opts.Value != "" {
+			next.Value = opts.Value
+		}
+		if opts.TTL > 0 {
+			next.TTL = opts.TTL
+		}
+
+		zones := hostZones(collectRecords(root))
+		if _, err := rrFromRecord(next, zones); err != nil {
+			return err
+		}
+
+		var oldPTRs, newPTRs []recordMatch
+		var ptr dnsRecord
+		if opts.PTR && next.Value != old.Value {
+			if _, oldPTRs, _, err = reversePTRs(root, old); err != nil {
+				return err
+			}
+			var other []recordMatch
+			if ptr, newPTRs, other, err = reversePTRs(root, next); err != nil {
+				return err
+			}
+			if len(other) > 0 {
+				return fmt.Errorf("%s already has a PTR record for %s", next.Value, other[0].rec.Host)
+			}
+		}
+
+		if opts.Value != "" {
+			if err := setMappingValue(old.Node, "record_value", next.Value); err != nil {
+				return err
+			}
+		}
+		if opts.TTL > 0 {
+			if err := setMappingValue(old.Node, "ttl", strconv.FormatUint(uint64(next.TTL), 10)); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(report, "updated %s (ttl %d)\n", recordText(next), next.TTL)
+
+		if !opts.PTR || next.Value == old.Value {
+			return nil
+		}
+
+		for _, m := range oldPTRs {
+			fmt.Fprintf(report, "removed %s\n", recordText(m.rec))
+		}
+		removeRecords(root, oldPTRs)
+		if len(newPTRs) > 0 {
+			return nil
+		}
+		if _, err := appendMapping(root, editSection, recordFields(ptr, 0)); err != nil {
+			return err
+		}
+		fmt.Fprintf(report, "added %s\n", recordText(ptr))
+		return nil
+	})
+}
+
+// uniqueRecord returns the single dns_records entry matching rec, or an error if there
+// is none or more than one; hint tells the user how to resolve an ambiguous match.
+func uniqueRecord(root *ast.MappingNode, rec dnsRecord, hint string) ([]recordMatch, error) {
+	matches := findRecords(root, rec, rec.Value != "")
+
+	desc := rec.Name() + " " + rec.Type
+	if rec.Value != "" {
+		desc += " " + rec.Value
+	}
+
+	switch {
+	case len(matches) == 0:
+		return nil, fmt.Errorf("no record %s in %s", desc, editSection)
+	case len(matches) > 1:
+		values := make([]string, len(matches))
+		for i, m := range matches {
+			values[i] = m.rec.Value
+		}
+		return nil, fmt.Errorf("%d records match %s (%s): %s", len(matches), desc, strings.Join(values, ", "), hint)
+	}
+	return matches, nil
+}
@@ -0,0 +1,290 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+const editFixture = `# lab inventory
+dns_records:
+  # web tier
+  - host: web01
+    type: A
+    zone: example.lan.
+    record_value: 10.0.1.5 # rack 3
+  - host: web01
+    type: PTR
+    zone: 1.0.10.in-addr.arpa.
+    record_value: 5
+  - host: www
+    type: CNAME
+    zone: example.lan.
+    record_value: web01
+  - host: old01 # DISABLED: unreachable
+    type: A
+    zone: example.lan.
+    record_value: 10.0.1.9
+`
+
+func readInventory(t *testing.T, path string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read inventory: %v", err)
+	}
+	return string(data)
+}
+
+func TestRecordOptions_Validate(t *testing.T) {
+	tests := []struct {
+		opts recordOptions
+		want string
+	}{
+		{recordOptions{Type: "A"}, "--host, --zone required"},
+		{recordOptions{Host: "a", Type: "LOC", Zone: "example.lan."}, "unsupported record type LOC"},
+		{recordOptions{Host: "a", Type: "cname", Zone: "example.lan.", PTR: true}, "--ptr only applies to A and AAAA records"},
+		{recordOptions{Host: "a", Type: "aaaa", Zone: "example.lan.", PTR: true}, ""},
+	}
+
+	for _, tt := range tests {
+		err := tt.opts.validate()
+		if tt.want == "" {
+			if err != nil {
+				t.Errorf("validate(%+v) = %v, want nil", tt.opts, err)
+			}
+			continue
+		}
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("validate(%+v) = %v, want %q", tt.opts, err, tt.want)
+		}
+	}
+}
+
+func TestReverseZone(t *testing.T) {
+	records := []dnsRecord{
+		{Type: "PTR", Zone: "10.in-addr.arpa."},
+		{Type: "PTR", Zone: "1.0.10.in-addr.arpa."},
+		{Type: "PTR", Zone: "8.b.d.0.1.0.0.2.ip6.arpa."},
+	}
+
+	tests := []struct {
+		ip, zone, label string
+	}{
+		{"10.0.1.5", "1.0.10.in-addr.arpa.", "5"},
+		{"10.0.2.7", "10.in-addr.arpa.", "7.2.0"},
+		{"192.168.4.20", "4.168.192.in-addr.arpa.", "20"},
+		{"2001:db8::1", "8.b.d.0.1.0.0.2.ip6.arpa.", "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0"},
+	}
+
+	for _, tt := range tests {
+		zone, label, err := reverseZone(records, tt.ip)
+		if err != nil || zone != tt.zone || label != tt.label {
+			t.Errorf("reverseZone(%s) = %q, %q, %v, want %q, %q", tt.ip, zone, label, err, tt.zone, tt.label)
+		}
+	}
+
+	if _, _, err := reverseZone(nil, "fd00::1"); err == nil {
+		t.Errorf("reverseZone for IPv6 without a reverse zone returned nil, want error")
+	}
+}
+
+func TestRunRecordAdd(t *testing.T) {
+	path := writeInventory(t, editFixture)
+
+	var out bytes.Buffer
+	err := runRecordAdd(recordOptions{File: path, Host: "web02", Type: "a", Zone: "example.lan", Value: "10.0.1.6", TTL: 300, PTR: true}, &out)
+	if err != nil {
+		t.Fatalf("runRecordAdd returned error: %v", err)
+	}
+
+	got := readInventory(t, path)
+	if !strings.HasPrefix(got, editFixture) {
+		t.Fatalf("existing entries and comments were not preserved:\n%s", got)
+	}
+	want := `  - host: web02
+    type: A
+    zone: example.lan.
+    record_value: 10.0.1.6
+    ttl: 300
+  - host: web02
+    type: PTR
+    zone: 1.0.10.in-addr.arpa.
+    record_value: 6
+`
+	if added := strings.TrimPrefix(got, editFixture); added != want {
+		t.Fatalf("added entries =\n%s\nwant\n%s", added, want)
+	}
+	if !strings.Contains(out.String(), "added web02.example.lan. A 10.0.1.6") || !strings.Contains(out.String(), "added 6.1.0.10.in-addr.arpa. PTR web02") {
+		t.Fatalf("unexpected report:\n%s", out.String())
+	}
+}
+
+func TestRunRecordAdd_RejectsDuplicates(t *testing.T) {
+	tests := []struct {
+		name string
+		opts recordOptions
+		want string
+	}{
+		{"same record", recordOptions{Host: "WEB01", Type: "A", Zone: "example.lan.", Value: "10.0.1.5"}, "already exists in dns_records"},
+		{"disabled record", recordOptions{Host: "old01", Type: "A", Zone: "example.lan.", Value: "10.0.1.9"}, "(disabled)"},
+		{"qualified cname", recordOptions{Host: "www", Type: "CNAME", Zone: "example.lan.", Value: "web01.example.lan."}, "already exists"},
+		{"ptr taken", recordOptions{Host: "web09", Type: "A", Zone: "example.lan.", Value: "10.0.1.5", PTR: true}, "10.0.1.5 already has a PTR record for web01"},
+		{"invalid value", recordOptions{Host: "web09", Type: "A", Zone: "example.lan.", Value: "not-an-ip"}, "record web09.example.lan. A"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeInventory(t, editFixture)
+			tt.opts.File = path
+
+			err := runRecordAdd(tt.opts, &bytes.Buffer{})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("runRecordAdd error = %v, want %q", err, tt.want)
+			}
+			if got := readInventory(t, path); got != editFixture {
+				t.Fatalf("rejected add modified the inventory:\n%s", got)
+			}
+		})
+	}
+}
+
+func TestRunRecordAdd_ExistingPTR(t *testing.T) {
+	path := writeInventory(t, editFixture)
+
+	var out bytes.Buffer
+	err := runRecordAdd(recordOptions{File: path, Host: "web01", Type: "TXT", Zone: "example.lan.", Value: "hello"}, &out)
+	if err != nil {
+		t.Fatalf("runRecordAdd returned error: %v", err)
+	}
+	if got := readInventory(t, path); strings.Count(got, "type: PTR") != 1 {
+		t.Fatalf("a PTR was added without --ptr:\n%s", got)
+	}
+}
+
+func TestRunRecordRemove(t *testing.T) {
+	path := writeInventory(t, editFixture)
+
+	var out bytes.Buffer
+	if err := runRecordRemove(recordOptions{File: path, Host: "web01", Type: "A", Zone: "example.lan.", PTR: true}, &out); err != nil {
+		t.Fatalf("runRecordRemove returned error: %v", err)
+	}
+
+	want := `# lab inventory
+dns_records:
+  - host: www
+    type: CNAME
+    zone: example.lan.
+    record_value: web01
+  - host: old01 # DISABLED: unreachable
+    type: A
+    zone: example.lan.
+    record_value: 10.0.1.9
+`
+	if got := readInventory(t, path); got != want {
+		t.Fatalf("inventory after rm =\n%s\nwant\n%s", got, want)
+	}
+	if !strings.Contains(out.String(), "removed web01.example.lan. A 10.0.1.5") || !strings.Contains(out.String(), "removed 5.1.0.10.in-addr.arpa. PTR web01") {
+		t.Fatalf("unexpected report:\n%s", out.String())
+	}
+
+	err := runRecordRemove(recordOptions{File: path, Host: "web01", Type: "A", Zone: "example.lan."}, &out)
+	if err == nil || !strings.Contains(err.Error(), "no record web01.example.lan. A") {
+		t.Fatalf("runRecordRemove of a missing record error = %v", err)
+	}
+}
+
+func TestRunRecordRemove_Ambiguous(t *testing.T) {
+	path := writeInventory(t, editFixture+`  - host: web01
+    type: A
+    zone: example.lan.
+    record_value: 10.0.1.15
+`)
+
+	err := runRecordRemove(recordOptions{File: path, Host: "web01", Type: "A", Zone: "example.lan."}, &bytes.Buffer{})
+	if err == nil || !strings.Contains(err.Error(), "2 records match") || !strings.Contains(err.Error(), "--value") {
+		t.Fatalf("runRecordRemove error = %v, want ambiguous match", err)
+	}
+
+	if err := runRecordRemove(recordOptions{File: path, Host: "web01", Type: "A", Zone: "example.lan.", Value: "10.0.1.15"}, &bytes.Buffer{}); err != nil {
+		t.Fatalf("runRecordRemove with --value returned error: %v", err)
+	}
+	if got := readInventory(t, path); got != editFixture {
+		t.Fatalf("inventory after rm --value =\n%s", got)
+	}
+}
+
+func TestRunRecordSet(t *testing.T) {
+	path := writeInventory(t, editFixture)
+
+	var out bytes.Buffer
+	err := runRecordSet(recordOptions{File: path, Host: "web01", Type: "A", Zone: "example.lan.", Value: "10.0.1.7", TTL: 600, PTR: true}, &out)
+	if err != nil {
+		t.Fatalf("runRecordSet returned error: %v", err)
+	}
+
+	want := `# lab inventory
+dns_records:
+  # web tier
+  - host: web01
+    type: A
+    zone: example.lan.
+    record_value: 10.0.1.7 # rack 3
+    ttl: 600
+  - host: www
+    type: CNAME
+    zone: example.lan.
+    record_value: web01
+  - host: old01 # DISABLED: unreachable
+    type: A
+    zone: example.lan.
+    record_value: 10.0.1.9
+  - host: web01
+    type: PTR
+    zone: 1.0.10.in-addr.arpa.
+    record_value: 7
+`
+	if got := readInventory(t, path); got != want {
+		t.Fatalf("inventory after set =\n%s\nwant\n%s", got, want)
+	}
+
+	if err := runRecordSet(recordOptions{File: path, Host: "web01", Type: "A", Zone: "example.lan."}, &out); err == nil {
+		t.Fatalf("runRecordSet without --value or --ttl returned nil, want error")
+	}
+	if err := runRecordSet(recordOptions{File: path, Host: "web01", Type: "A", Zone: "example.lan.", Value: "10.0.1"}, &out); err == nil {
+		t.Fatalf("runRecordSet with an invalid address returned nil, want error")
+	}
+}
+
+func TestRunRecordSet_KeepsDisabledMarker(t *testing.T) {
+	path := writeInventory(t, editFixture)
+
+	if err := runRecordSet(recordOptions{File: path, Host: "old01", Type: "A", Zone: "example.lan.", Value: "10.0.1.10"}, &bytes.Buffer{}); err != nil {
+		t.Fatalf("runRecordSet returned error: %v", err)
+	}
+
+	_, root, err := loadInventory(path)
+	if err != nil {
+		t.Fatalf("loadInventory returned error: %v", err)
+	}
+	records := collectRecords(root)
+	if r := records[3]; r.Value != "10.0.1.10" || !r.Disabled {
+		t.Fatalf("record after set = %+v, want new value and still disabled", r)
+	}
+}
+
+func TestRunRecordAdd_Stdin(t *testing.T) {
+	stubStdin(t, editFixture)
+
+	out, err := captureStdout(t, func() error {
+		return runRecordAdd(recordOptions{File: stdinPath, Host: "mail", Type: "MX", Zone: "example.lan.", Value: "10 web01"}, &bytes.Buffer{})
+	})
+	if err != nil {
+		t.Fatalf("runRecordAdd returned error: %v", err)
+	}
+	if !strings.HasPrefix(out, editFixture) || !strings.Contains(out, `record_value: "10 web01"`) {
+		t.Fatalf("stdout does not hold the edited inventory:\n%s", out)
+	}
+}
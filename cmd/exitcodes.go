@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Process exit codes. Anything that is not a finding or an interruption exits with exitError.
+const (
+	exitOK          = 0
+	exitError       = 1
+	exitFindings    = 2
+	exitInterrupted = 3
+)
+
+// failOnValues lists the conditions --fail-on accepts. failOnNone turns findings off.
+var failOnValues = []string{"unreachable", "changes", "nameservers", failOnNone}
+
+// failOnNone is the --fail-on value that makes findings exit with exitOK.
+const failOnNone = "none"
+
+// defaultFailOn is the --fail-on default: a run that found enabled records down is a
+// finding, so that cron jobs and CI notice a decaying inventory without extra flags.
+// Records an earlier run already disabled do not fail later runs.
+var defaultFailOn = []string{"unreachable"}
+
+// exitCodeError is an error that asks for a specific process exit code.
+type exitCodeError struct {
+	code int
+	err  error
+}
+
+func (e *exitCodeError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitCodeError) Unwrap() error {
+	return e.err
+}
+
+// withExitCode wraps err so that the process exits with code.
+func withExitCode(code int, err error) error {
+	return &exitCodeError{code: code, err: err}
+}
+
+// ExitCode returns the process exit code for an error returned by Execute.
+func ExitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+
+	var ec *exitCodeError
+	if errors.As(err, &ec) {
+		return ec.code
+	}
+	return exitError
+}
+
+// validateFailOn rejects unknown --fail-on conditions, and failOnNone combined with others.
+func validateFailOn(conditions []string) error {
+	for _, c := range conditions {
+		if !slices.Contains(failOnValues, c) {
+			return fmt.Errorf("invalid --fail-on %q (want %s)", c, strings.Join(failOnValues, " or "))
+		}
+	}
+	if slices.Contains(conditions, failOnNone) && len(conditions) > 1 {
+		return fmt.Errorf("--fail-on %s cannot be combined with other conditions", failOnNone)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestExitCode(t *testing.T) {
+	findings := withExitCode(exitFindings, errors.New("2 unreachable host(s) found"))
+
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{nil, exitOK},
+		{errors.New("boom"), exitError},
+		{findings, exitFindings},
+		{fmt.Errorf("wrapped: %w", withExitCode(exitInterrupted, errors.New("interrupted"))), exitInterrupted},
+	}
+
+	for _, tt := range tests {
+		if got := ExitCode(tt.err); got != tt.want {
+			t.Errorf("ExitCode(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+
+	if findings.Error() != "2 unreachable host(s) found" {
+		t.Errorf("exitCodeError should keep the message, got %q", findings.Error())
+	}
+}
+
+func TestValidateFailOn(t *testing.T) {
+	for _, ok := range [][]string{nil, {"unreachable"}, {"unreachable", "changes", "nameservers"}, {failOnNone}} {
+		if err := validateFailOn(ok); err != nil {
+			t.Errorf("validateFailOn(%v) = %v, want nil", ok, err)
+		}
+	}
+	for _, bad := range [][]string{{"typos"}, {failOnNone, "unreachable"}} {
+		if err := validateFailOn(bad); err == nil {
+			t.Errorf("validateFailOn(%v) returned nil, want error", bad)
+		}
+	}
+
+	if got := cleanZonesCmd.Flags().Lookup("fail-on").DefValue; got != "[unreachable]" {
+		t.Errorf("--fail-on default = %s, want [unreachable]", got)
+	}
+}
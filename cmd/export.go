@@ -0,0 +1,194 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+)
+
+// exportFormats lists the supported resolver configuration formats.
+var exportFormats = []string{"dnsmasq", "unbound", "hosts"}
+
+// runExport renders the enabled inventory records as resolver configuration.
+// Output goes to outPath, or to out when outPath is empty.
+func runExport(filePath, format, outPath string, out io.Writer) error {
+	_, root, err := loadInventory(filePath)
+	if err != nil {
+		return err
+	}
+
+	records, err := enabledRecords(collectRecords(root))
+	if err != nil {
+		return err
+	}
+
+	var render func(io.Writer, []resourceRecord)
+	switch format {
+	case "dnsmasq":
+		render = renderDnsmasq
+	case "unbound":
+		render = renderUnbound
+	case "hosts":
+		render = renderHosts
+	default:
+		return fmt.Errorf("unknown export format %q (want one of %s)", format, strings.Join(exportFormats, ", "))
+	}
+
+	if outPath == "" {
+		render(out, records)
+		return nil
+	}
+
+	f, err := os.Create(outPath)
+	if err != nil {
+		return err
+	}
+	render(f, records)
+	return f.Close()
+}
+
+// enabledRecords returns the normalized records of every zone, skipping DISABLED entries.
+func enabledRecords(records []dnsRecord) ([]resourceRecord, error) {
+	var out []resourceRecord
+	for _, zone := range recordZones(records) {
+		rs, err := desiredRecords(records, zone)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, rs...)
+	}
+	return out, nil
+}
+
+// reverseIP converts an in-addr.arpa or ip6.arpa owner name back into an IP address.
+func reverseIP(name string) net.IP {
+	name = strings.TrimSuffix(strings.ToLower(name), ".")
+
+	switch {
+	case strings.HasSuffix(name, ".in-addr.arpa"):
+		labels := strings.Split(strings.TrimSuffix(name, ".in-addr.arpa"), ".")
+		if len(labels) != 4 {
+			return nil
+		}
+		for i, j := 0, len(labels)-1; i < j; i, j = i+1, j-1 {
+			labels[i], labels[j] = labels[j], labels[i]
+		}
+		return net.ParseIP(strings.Join(labels, ".")).To4()
+
+	case strings.HasSuffix(name, ".ip6.arpa"):
+		nibbles := strings.Split(strings.TrimSuffix(name, ".ip6.arpa"), ".")
+		if len(nibbles) != 32 {
+			return nil
+		}
+		var b strings.Builder
+		for i := len(nibbles) - 1; i >= 0; i-- {
+			b.WriteString(nibbles[i])
+			if i%4 == 0 && i != 0 {
+				b.WriteByte(':')
+			}
+		}
+		return net.ParseIP(b.String())
+	}
+
+	return nil
+}
+
+// hostName strips the trailing dot from a fully qualified name.
+func hostName(fqdn string) string {
+	return strings.TrimSuffix(fqdn, ".")
+}
+
+// renderDnsmasq writes dnsmasq directives. A/AAAA records become host-record=, which
+// matches the name exactly; address= would also answer for every name below it, and at
+// the apex for the whole zone. dnsmasq answers the reverse lookup of a host-record too,
+// so PTRs matching one are left out; the rest map to ptr-record=, cname= and friends.
+func renderDnsmasq(w io.Writer, records []resourceRecord) {
+	ptrs := map[string]bool{}
+	for _, r := range records {
+		if r.Type != "PTR" {
+			continue
+		}
+		if ip := reverseIP(r.Name); ip != nil {
+			ptrs[ip.String()+" "+r.Data] = true
+		}
+	}
+
+	paired := map[string]bool{}
+	for _, r := range records {
+		if (r.Type == "A" || r.Type == "AAAA") && ptrs[r.Data+" "+r.Name] {
+			paired[r.Data+" "+r.Name] = true
+		}
+	}
+
+	fmt.Fprintln(w, "# generated by dnsctl export --format dnsmasq")
+	for _, r := range records {
+		switch r.Type {
+		case "A", "AAAA":
+			fmt.Fprintf(w, "host-record=%s,%s\n", hostName(r.Name), r.Data)
+		case "PTR":
+			if ip := reverseIP(r.Name); ip != nil && paired[ip.String()+" "+r.Data] {
+				continue
+			}
+			fmt.Fprintf(w, "ptr-record=%s,%s\n", hostName(r.Name), hostName(r.Data))
+		case "CNAME":
+			fmt.Fprintf(w, "cname=%s,%s\n", hostName(r.Name), hostName(r.Data))
+		case "MX":
+			var pref int
+			var target string
+			fmt.Sscanf(r.Data, "%d %s", &pref, &target)
+			fmt.Fprintf(w, "mx-host=%s,%s,%d\n", hostName(r.Name), hostName(target), pref)
+		case "TXT":
+			fmt.Fprintf(w, "txt-record=%s,%s\n", hostName(r.Name), r.Data)
+		case "SRV":
+			var prio, weight, port int
+			var target string
+			fmt.Sscanf(r.Data, "%d %d %d %s", &prio, &weight, &port, &target)
+			fmt.Fprintf(w, "srv-host=%s,%s,%d,%d,%d\n", hostName(r.Name), hostName(target), port, prio, weight)
+		default:
+			fmt.Fprintf(w, "# unsupported by dnsmasq: %s\n", r)
+		}
+	}
+}
+
+// renderUnbound writes an unbound server: block with local-data and local-data-ptr entries.
+func renderUnbound(w io.Writer, records []resourceRecord) {
+	fmt.Fprintln(w, "# generated by dnsctl export --format unbound")
+	fmt.Fprintln(w, "server:")
+	for _, r := range records {
+		if r.Type == "PTR" {
+			if ip := reverseIP(r.Name); ip != nil {
+				fmt.Fprintf(w, "    local-data-ptr: \"%s %s\"\n", ip, r.Data)
+				continue
+			}
+		}
+		// TXT rdata carries double quotes, so wrap those entries in single quotes.
+		if strings.Contains(r.Data, `"`) {
+			fmt.Fprintf(w, "    local-data: '%s'\n", r)
+			continue
+		}
+		fmt.Fprintf(w, "    local-data: \"%s\"\n", r)
+	}
+}
+
+// renderHosts writes an /etc/hosts file from A and AAAA records, one line per address.
+func renderHosts(w io.Writer, records []resourceRecord) {
+	var order []string
+	names := map[string][]string{}
+
+	for _, r := range records {
+		if r.Type != "A" && r.Type != "AAAA" {
+			continue
+		}
+		if _, ok := names[r.Data]; !ok {
+			order = append(order, r.Data)
+		}
+		names[r.Data] = append(names[r.Data], hostName(r.Name))
+	}
+
+	fmt.Fprintln(w, "# generated by dnsctl export --format hosts")
+	for _, ip := range order {
+		fmt.Fprintf(w, "%s\t%s\n", ip, strings.Join(names[ip], " "))
+	}
+}
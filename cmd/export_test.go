@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const exportFixture = `dns_records:
+  - host: web01
+    type: A
+    zone: example.lan.
+    record_value: 10.0.1.5
+  - host: web01
+    type: PTR
+    zone: 1.0.10.in-addr.arpa.
+    record_value: 5
+  - host: web02
+    type: A
+    zone: example.lan.
+    record_value: 10.0.1.5
+  - host: old
+    type: A
+    zone: example.lan.
+    record_value: 10.0.1.9 # DISABLED: unreachable
+  - host: www
+    type: CNAME
+    zone: example.lan.
+    record_value: web01
+  - host: "@"
+    type: A
+    zone: example.lan.
+    record_value: 10.0.1.1
+  - host: "@"
+    type: MX
+    zone: example.lan.
+    record_value: 10 mail.example.lan.
+  - host: "@"
+    type: TXT
+    zone: example.lan.
+    record_value: '"v=spf1 -all"'
+`
+
+func exportString(t *testing.T, format string) string {
+	t.Helper()
+
+	var out bytes.Buffer
+	if err := runExport(writeInventory(t, exportFixture), format, "", &out); err != nil {
+		t.Fatalf("runExport(%s) returned error: %v", format, err)
+	}
+	if strings.Contains(out.String(), "10.0.1.9") {
+		t.Fatalf("runExport(%s) included a DISABLED record:\n%s", format, out.String())
+	}
+	return out.String()
+}
+
+func assertLines(t *testing.T, out string, want ...string) {
+	t.Helper()
+
+	for _, w := range want {
+		if !strings.Contains(out, w+"\n") {
+			t.Fatalf("output missing line %q:\n%s", w, out)
+		}
+	}
+}
+
+func TestRunExport_Dnsmasq(t *testing.T) {
+	out := exportString(t, "dnsmasq")
+	assertLines(t, out,
+		"host-record=web01.example.lan,10.0.1.5",
+		"host-record=web02.example.lan,10.0.1.5",
+		"host-record=example.lan,10.0.1.1",
+		"cname=www.example.lan,web01.example.lan",
+		"mx-host=example.lan,mail.example.lan,10",
+		`txt-record=example.lan,"v=spf1 -all"`,
+	)
+	if strings.Contains(out, "ptr-record=") {
+		t.Fatalf("PTR paired with host-record should not be emitted separately:\n%s", out)
+	}
+	if strings.Contains(out, "address=") {
+		t.Fatalf("address= also matches subdomains and must not be used for records:\n%s", out)
+	}
+}
+
+func TestRunExport_Unbound(t *testing.T) {
+	out := exportString(t, "unbound")
+	assertLines(t, out,
+		"server:",
+		`    local-data: "web01.example.lan. 3600 IN A 10.0.1.5"`,
+		`    local-data-ptr: "10.0.1.5 web01.example.lan."`,
+		`    local-data: 'example.lan. 3600 IN TXT "v=spf1 -all"'`,
+	)
+}
+
+func TestRunExport_Hosts(t *testing.T) {
+	out := exportString(t, "hosts")
+	assertLines(t, out, "10.0.1.5\tweb01.example.lan web02.example.lan")
+	if strings.Contains(out, "www") || strings.Contains(out, "mail") {
+		t.Fatalf("hosts output contains non-address records:\n%s", out)
+	}
+}
+
+func TestRunExport_UnknownFormatAndOutputFile(t *testing.T) {
+	path := writeInventory(t, exportFixture)
+	if err := runExport(path, "bind", "", &bytes.Buffer{}); err == nil {
+		t.Fatalf("runExport(bind) returned nil, want error")
+	}
+
+	dest := filepath.Join(t.TempDir(), "hosts")
+	var out bytes.Buffer
+	if err := runExport(path, "hosts", dest, &out); err != nil {
+		t.Fatalf("runExport to file returned error: %v", err)
+	}
+	if out.Len() != 0 {
+		t.Fatalf("runExport wrote %q to stdout, want nothing", out.String())
+	}
+	data, err := os.ReadFile(dest)
+	if err != nil || !strings.Contains(string(data), "web01.example.lan") {
+		t.Fatalf("export file = %q, %v", data, err)
+	}
+}
+
+func TestReverseIP(t *testing.T) {
+	cases := map[string]string{
+		"5.1.0.10.in-addr.arpa.": "10.0.1.5",
+		"1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa.": "2001:db8::1",
+		"1.0.10.in-addr.arpa.": "",
+		"web01.example.lan.":   "",
+	}
+
+	for name, want := range cases {
+		got := reverseIP(name)
+		if (got == nil && want != "") || (got != nil && got.String() != want) {
+			t.Errorf("reverseIP(%q) = %v, want %q", name, got, want)
+		}
+	}
+}
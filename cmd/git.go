@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// gitOptions configures committing the inventory after clean-zones changed it. Only the
+// local repository is touched; nothing is pushed.
+type gitOptions struct {
+	// Commit writes the inventory back to its file and commits it.
+	Commit bool
+	// Branch is created from the current HEAD to hold the commit; empty commits to the
+	// current branch. Setting it implies Commit.
+	Branch string
+}
+
+// enabled reports whether the run ends with a commit.
+func (o gitOptions) enabled() bool {
+	return o.Commit || o.Branch != ""
+}
+
+// gitRepo runs git in the work tree that holds an inventory file.
+type gitRepo struct {
+	dir  string
+	file string
+}
+
+// openGitRepo returns the repository holding path, failing if there is none.
+func openGitRepo(path string) (*gitRepo, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &gitRepo{dir: filepath.Dir(abs), file: filepath.Base(abs)}
+	if _, err := r.run("rev-parse", "--is-inside-work-tree"); err != nil {
+		return nil, fmt.Errorf("%s is not in a git work tree: %w", path, err)
+	}
+	return r, nil
+}
+
+// run executes git with args in the file's directory and returns its standard output.
+func (r *gitRepo) run(args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("git", append([]string{"-C", r.dir}, args...)...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return stdout.String(), nil
+}
+
+// checkClean fails if the inventory is untracked or has uncommitted changes, which the
+// commit would otherwise sweep up together with the run's own changes.
+func (r *gitRepo) checkClean() error {
+	out, err := r.run("status", "--porcelain", "--", r.file)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(out) != "" {
+		return fmt.Errorf("%s is untracked or has uncommitted changes; commit or stash them before using --git-commit", r.file)
+	}
+	return nil
+}
+
+// checkNewBranch fails if branch already exists, which createBranch would otherwise only
+// find out after the whole run.
+func (r *gitRepo) checkNewBranch(branch string) error {
+	if _, err := r.run("rev-parse", "--verify", "--quiet", "refs/heads/"+branch); err == nil {
+		return fmt.Errorf("branch %s already exists; pick another --git-branch", branch)
+	}
+	return nil
+}
+
+// createBranch creates branch at HEAD and switches to it.
+func (r *gitRepo) createBranch(branch string) error {
+	_, err := r.run("checkout", "-q", "-b", branch)
+	return err
+}
+
+// commit records the inventory file alone, leaving anything else staged untouched.
+func (r *gitRepo) commit(message string) error {
+	_, err := r.run("commit", "-q", "-m", message, "--", r.file)
+	return err
+}
+
+// gitCommitMessage describes a clean-zones run: a subject line with the counts, then the
+// disabled, re-enabled, newly kept and added records.
+func gitCommitMessage(file string, changes, marked []stateChange, added []dnsRecord) string {
+	var disabled, enabled, kept []string
+	for _, c := range changes {
+		switch c.State {
+		case "disabled":
+			disabled = append(disabled, fmt.Sprintf("%s (%s)", c.describe(), c.Reason))
+		case "enabled":
+			enabled = append(enabled, fmt.Sprintf("%s (%s)", c.describe(), c.Reason))
+		}
+	}
+	for _, c := range marked {
+		kept = append(kept, c.describe())
+	}
+	var addedText []string
+	for _, rec := range added {
+		addedText = append(addedText, recordText(rec))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "dnsctl clean-zones: %d disabled, %d re-enabled, %d added in %s\n",
+		len(disabled), len(enabled), len(addedText), filepath.Base(file))
+	for _, section := range []struct {
+		title string
+		lines []string
+	}{
+		{"Disabled", disabled},
+		{"Re-enabled", enabled},
+		{"Marked " + keepKey, kept},
+		{"Added", addedText},
+	} {
+		if len(section.lines) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n%s:\n", section.title)
+		for _, l := range section.lines {
+			fmt.Fprintf(&b, "- %s\n", l)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,200 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// initGitRepo creates a repository holding content as zones.yaml in its first commit
+// and returns the path of the file.
+func initGitRepo(t *testing.T, content string) string {
+	t.Helper()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	for _, v := range []string{"GIT_AUTHOR", "GIT_COMMITTER"} {
+		t.Setenv(v+"_NAME", "dnsctl test")
+		t.Setenv(v+"_EMAIL", "dnsctl@example.lan")
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "zones.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to write inventory: %v", err)
+	}
+
+	repo := &gitRepo{dir: dir, file: "zones.yaml"}
+	for _, args := range [][]string{
+		{"init", "-q", "-b", "main"},
+		{"add", "zones.yaml"},
+		{"commit", "-q", "-m", "initial inventory"},
+	} {
+		if _, err := repo.run(args...); err != nil {
+			t.Fatalf("git %v: %v", args, err)
+		}
+	}
+	return path
+}
+
+func TestGitCommitMessage(t *testing.T) {
+	got := gitCommitMessage("/srv/dns/zones.yaml",
+		[]stateChange{
+			{Name: "web02", Type: "A", Zone: "example.lan.", IP: "10.0.1.6", State: "disabled", Reason: "unreachable"},
+			{Name: "ns1", IP: "10.0.0.53", State: "enabled", Reason: "reachable again"},
+		},
+		[]stateChange{{Name: "vip", Type: "A", Zone: "example.lan.", IP: "10.0.1.9"}},
+		[]dnsRecord{{Host: "web01", Type: "PTR", Zone: "1.0.10.in-addr.arpa.", Value: "5"}},
+	)
+
+	want := `dnsctl clean-zones: 1 disabled, 1 re-enabled, 1 added in zones.yaml
+
+Disabled:
+- web02 A example.lan. 10.0.1.6 (unreachable)
+
+Re-enabled:
+- ns1 10.0.0.53 (reachable again)
+
+Marked dnsctl_keep:
+- vip A example.lan. 10.0.1.9
+
+Added:
+- 5.1.0.10.in-addr.arpa. PTR web01
+`
+	if got != want {
+		t.Fatalf("gitCommitMessage =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestGitRepo_CheckClean(t *testing.T) {
+	path := initGitRepo(t, cleanFixture)
+
+	repo, err := openGitRepo(path)
+	if err != nil {
+		t.Fatalf("openGitRepo returned error: %v", err)
+	}
+	if err := repo.checkClean(); err != nil {
+		t.Fatalf("checkClean on a committed file returned error: %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte(cleanFixture+"# edited\n"), 0o644); err != nil {
+		t.Fatalf("failed to edit inventory: %v", err)
+	}
+	if err := repo.checkClean(); err == nil || !strings.Contains(err.Error(), "uncommitted changes") {
+		t.Fatalf("checkClean on an edited file = %v, want error", err)
+	}
+
+	if _, err := openGitRepo(filepath.Join(t.TempDir(), "zones.yaml")); err == nil {
+		t.Fatalf("openGitRepo outside a repository returned nil, want error")
+	}
+}
+
+func TestRunCleanZones_GitCommit(t *testing.T) {
+	stubProbe(t, func(ctx context.Context, ip string, timeout time.Duration) bool {
+		return ip != "10.0.1.6"
+	})
+
+	path := initGitRepo(t, cleanFixture)
+	repo := &gitRepo{dir: filepath.Dir(path), file: "zones.yaml"}
+
+	out, err := captureStdout(t, func() error {
+		return runCleanZones(cleanOptions{File: path, Timeout: time.Second, Workers: 1, Quiet: true, Git: gitOptions{Branch: "dnsctl/cleanup"}})
+	})
+	if err != nil {
+		t.Fatalf("runCleanZones returned error: %v", err)
+	}
+	if out != "" {
+		t.Fatalf("--git-commit printed the inventory:\n%s", out)
+	}
+
+	branch, _ := repo.run("rev-parse", "--abbrev-ref", "HEAD")
+	if strings.TrimSpace(branch) != "dnsctl/cleanup" {
+		t.Fatalf("current branch = %q, want dnsctl/cleanup", branch)
+	}
+	message, _ := repo.run("log", "-1", "--format=%B")
+	for _, want := range []string{
+		"dnsctl clean-zones: 1 disabled, 0 re-enabled, 2 added in zones.yaml",
+		"- web02 A example.lan. 10.0.1.6 (unreachable)",
+		"- 5.1.0.10.in-addr.arpa. PTR web01",
+	} {
+		if !strings.Contains(message, want) {
+			t.Errorf("commit message is missing %q:\n%s", want, message)
+		}
+	}
+	if err := repo.checkClean(); err != nil {
+		t.Fatalf("inventory was not fully committed: %v", err)
+	}
+	if !strings.Contains(readInventory(t, path), "host: web02 # DISABLED: unreachable") {
+		t.Fatalf("inventory was not written back:\n%s", readInventory(t, path))
+	}
+
+	// a second run changes nothing and therefore commits nothing
+	if _, err := captureStdout(t, func() error {
+		return runCleanZones(cleanOptions{File: path, Timeout: time.Second, Workers: 1, Quiet: true, Git: gitOptions{Commit: true}})
+	}); err != nil {
+		t.Fatalf("second runCleanZones returned error: %v", err)
+	}
+	count, _ := repo.run("rev-list", "--count", "HEAD")
+	if strings.TrimSpace(count) != "2" {
+		t.Fatalf("repository has %s commits, want 2", strings.TrimSpace(count))
+	}
+}
+
+func TestRunCleanZones_GitRefusesDirtyInventory(t *testing.T) {
+	path := initGitRepo(t, cleanFixture)
+	if err := os.WriteFile(path, []byte(cleanFixture+"# edited\n"), 0o644); err != nil {
+		t.Fatalf("failed to edit inventory: %v", err)
+	}
+
+	err := runCleanZones(cleanOptions{File: path, Timeout: time.Second, Git: gitOptions{Commit: true}})
+	if err == nil || !strings.Contains(err.Error(), "uncommitted changes") {
+		t.Fatalf("runCleanZones error = %v, want uncommitted changes", err)
+	}
+
+	err = runCleanZones(cleanOptions{File: path, DryRun: true, Git: gitOptions{Commit: true}})
+	if err == nil || !strings.Contains(err.Error(), "--dry-run") {
+		t.Fatalf("runCleanZones with --dry-run error = %v", err)
+	}
+}
+
+func TestRunCleanZones_GitRefusesExistingBranch(t *testing.T) {
+	stubProbe(t, func(ctx context.Context, ip string, timeout time.Duration) bool {
+		t.Errorf("probed %s although the branch exists", ip)
+		return true
+	})
+
+	path := initGitRepo(t, cleanFixture)
+	repo := &gitRepo{dir: filepath.Dir(path), file: filepath.Base(path)}
+	if _, err := repo.run("branch", "dns-cleanup"); err != nil {
+		t.Fatalf("git branch: %v", err)
+	}
+
+	err := runCleanZones(cleanOptions{File: path, Timeout: time.Second, Quiet: true, Git: gitOptions{Branch: "dns-cleanup"}})
+	if err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("runCleanZones error = %v, want existing branch error", err)
+	}
+}
+
+func TestRunCleanZones_GitCommitFailure(t *testing.T) {
+	stubProbe(t, func(ctx context.Context, ip string, timeout time.Duration) bool {
+		return ip != "10.0.1.6"
+	})
+
+	path := initGitRepo(t, cleanFixture)
+	hook := filepath.Join(filepath.Dir(path), ".git", "hooks", "pre-commit")
+	if err := os.WriteFile(hook, []byte("#!/bin/sh\nexit 1\n"), 0o755); err != nil {
+		t.Fatalf("failed to write hook: %v", err)
+	}
+
+	err := runCleanZones(cleanOptions{File: path, Timeout: time.Second, Quiet: true, Git: gitOptions{Branch: "dns-cleanup"}})
+	if err == nil || !strings.Contains(err.Error(), "was written on branch dns-cleanup but is not committed") {
+		t.Fatalf("runCleanZones error = %v, want an error describing the uncommitted file", err)
+	}
+}
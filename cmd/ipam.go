@@ -0,0 +1,586 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/goccy/go-yaml/ast"
+	"github.com/miekg/dns"
+)
+
+// ipamOptions configures free address allocation in one subnet.
+type ipamOptions struct {
+	File string
+	CIDR string
+	// Count is the number of free addresses to list.
+	Count int
+	// Reserve lists addresses, a-b ranges and prefixes that are never handed out.
+	Reserve []string
+	// ReuseDisabled treats the addresses of disabled entries as free.
+	ReuseDisabled bool
+	// Record, when it names a host, is added for the first free address, with its type
+	// chosen by the address family.
+	Record recordOptions
+}
+
+// addressUse is an inventory entry that claims an IP address.
+type addressUse struct {
+	Addr netip.Addr
+	// Name is the host or nameserver name the address belongs to.
+	Name string
+	// FQDN is the fully qualified host name without the trailing dot; for PTR records
+	// it is the record's target.
+	FQDN     string
+	Type     string
+	Zone     string
+	Section  string
+	Disabled bool
+}
+
+// addressUses returns every address claimed by A, AAAA and PTR records and by
+// nameservers, in inventory order. Entries whose address does not parse are skipped.
+func addressUses(records []dnsRecord, nameservers []nameserver) []addressUse {
+	var out []addressUse
+
+	for _, r := range records {
+		var ip net.IP
+		fqdn := hostName(r.Name())
+		switch r.Type {
+		case "A", "AAAA":
+			ip = net.ParseIP(r.Value)
+		case "PTR":
+			ip = reverseIP(r.Name())
+			fqdn = hostName(r.Host)
+		}
+		addr, ok := netipAddr(ip)
+		if !ok {
+			continue
+		}
+		out = append(out, addressUse{Addr: addr, Name: r.Host, FQDN: fqdn, Type: r.Type, Zone: r.Zone, Section: r.Section, Disabled: r.Disabled})
+	}
+
+	for _, ns := range nameservers {
+		addr, ok := netipAddr(net.ParseIP(ns.IP))
+		if !ok {
+			continue
+		}
+		name := ns.Name
+		if name == "" {
+			name = ns.IP
+		}
+		out = append(out, addressUse{Addr: addr, Name: name, FQDN: hostName(name), Type: "nameserver", Section: ns.Section, Disabled: ns.Disabled})
+	}
+
+	return out
+}
+
+// netipAddr converts ip to a netip.Addr, unmapping IPv4-in-IPv6 addresses.
+func netipAddr(ip net.IP) (netip.Addr, bool) {
+	if ip == nil {
+		return netip.Addr{}, false
+	}
+	addr, ok := netip.AddrFromSlice(ip)
+	return addr.Unmap(), ok
+}
+
+// addrRange is an inclusive range of addresses.
+type addrRange struct {
+	from, to netip.Addr
+}
+
+// parseAddrRange parses a single address, a from-to range or a prefix.
+func parseAddrRange(s string) (addrRange, error) {
+	s = strings.TrimSpace(s)
+
+	if strings.Contains(s, "/") {
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return addrRange{}, err
+		}
+		p = p.Masked()
+		return addrRange{from: p.Addr(), to: lastAddr(p)}, nil
+	}
+
+	if from, to, ok := strings.Cut(s, "-"); ok {
+		a, err := netip.ParseAddr(strings.TrimSpace(from))
+		if err != nil {
+			return addrRange{}, err
+		}
+		b, err := netip.ParseAddr(strings.TrimSpace(to))
+		if err != nil {
+			return addrRange{}, err
+		}
+		if a.BitLen() != b.BitLen() || b.Less(a) {
+			return addrRange{}, fmt.Errorf("invalid range %q", s)
+		}
+		return addrRange{from: a, to: b}, nil
+	}
+
+	a, err := netip.ParseAddr(s)
+	if err != nil {
+		return addrRange{}, err
+	}
+	return addrRange{from: a, to: a}, nil
+}
+
+// contains reports whether a lies inside the range.
+func (r addrRange) contains(a netip.Addr) bool {
+	return a.BitLen() == r.from.BitLen() && !a.Less(r.from) && !r.to.Less(a)
+}
+
+// lastAddr returns the highest address of p.
+func lastAddr(p netip.Prefix) netip.Addr {
+	b := p.Masked().Addr().AsSlice()
+	for i := p.Bits(); i < len(b)*8; i++ {
+		b[i/8] |= 0x80 >> (i % 8)
+	}
+	addr, _ := netip.AddrFromSlice(b)
+	return addr
+}
+
+// usableRange returns the addresses of p that can be assigned to hosts. The IPv4 network
+// and broadcast addresses and the IPv6 subnet-router anycast address are excluded.
+func usableRange(p netip.Prefix) addrRange {
+	p = p.Masked()
+	r := addrRange{from: p.Addr(), to: lastAddr(p)}
+
+	switch {
+	case p.Addr().Is4() && p.Bits() <= 30:
+		r.from, r.to = r.from.Next(), r.to.Prev()
+	case p.Addr().Is6() && p.Bits() <= 126:
+		r.from = r.from.Next()
+	}
+	return r
+}
+
+// freeAddresses returns up to count addresses of p that are neither used nor reserved,
+// lowest first.
+func freeAddresses(p netip.Prefix, used map[netip.Addr]bool, reserved []addrRange, count int) []netip.Addr {
+	r := usableRange(p)
+
+	var out []netip.Addr
+	for a := r.from; a.IsValid() && r.contains(a) && len(out) < count; a = a.Next() {
+		if skip, ok := reservedEnd(reserved, a); ok {
+			a = skip
+			continue
+		}
+		if !used[a] {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// reservedEnd returns the end of the reserved range containing a, if any, so that
+// callers can skip over large ranges at once.
+func reservedEnd(reserved []addrRange, a netip.Addr) (netip.Addr, bool) {
+	for _, r := range reserved {
+		if r.contains(a) {
+			return r.to, true
+		}
+	}
+	return netip.Addr{}, false
+}
+
+// usedAddresses returns the set of addresses claimed by the inventory. Disabled entries
+// count unless reuseDisabled is set.
+func usedAddresses(root *ast.MappingNode, reuseDisabled bool) map[netip.Addr]bool {
+	used := map[netip.Addr]bool{}
+	for _, u := range addressUses(collectRecords(root), collectNameservers(root)) {
+		if u.Disabled && reuseDisabled {
+			continue
+		}
+		used[u.Addr] = true
+	}
+	return used
+}
+
+// runIPAMNext prints the next free addresses of opts.CIDR or, when a host is given,
+// adds a record for the first one in the same read-modify-write of the inventory.
+func runIPAMNext(opts ipamOptions, out io.Writer) error {
+	p, err := netip.ParsePrefix(opts.CIDR)
+	if err != nil {
+		return fmt.Errorf("invalid --cidr: %w", err)
+	}
+
+	reserved := make([]addrRange, 0, len(opts.Reserve))
+	for _, s := range opts.Reserve {
+		r, err := parseAddrRange(s)
+		if err != nil {
+			return fmt.Errorf("invalid reserved range %q: %w", s, err)
+		}
+		reserved = append(reserved, r)
+	}
+
+	if opts.Count < 1 {
+		opts.Count = 1
+	}
+
+	if opts.Record.Host == "" {
+		_, root, err := loadInventory(opts.File)
+		if err != nil {
+			return err
+		}
+
+		free := freeAddresses(p, usedAddresses(root, opts.ReuseDisabled), reserved, opts.Count)
+		if len(free) == 0 {
+			return fmt.Errorf("no free address in %s", p.Masked())
+		}
+		for _, a := range free {
+			fmt.Fprintln(out, a)
+		}
+		return nil
+	}
+
+	if opts.Count > 1 {
+		return fmt.Errorf("--count cannot be combined with --host")
+	}
+	if opts.Record.Zone == "" {
+		return fmt.Errorf("--zone is required with --host")
+	}
+
+	return editInventory(opts.File, out, func(root *ast.MappingNode, report io.Writer) error {
+		free := freeAddresses(p, usedAddresses(root, opts.ReuseDisabled), reserved, 1)
+		if len(free) == 0 {
+			return fmt.Errorf("no free address in %s", p.Masked())
+		}
+
+		rec := opts.Record
+		rec.Value = free[0].String()
+		rec.Type = "A"
+		if free[0].Is6() {
+			rec.Type = "AAAA"
+		}
+		if err := rec.validate(); err != nil {
+			return err
+		}
+		return addRecord(root, rec, report)
+	})
+}
+
+// ipamReportFormats lists the supported ipam report output formats.
+var ipamReportFormats = []string{"text", "json"}
+
+// subnet is an address range declared in the inventory or on the command line.
+type subnet struct {
+	Prefix netip.Prefix
+	Name   string
+}
+
+// collectSubnets returns the entries of the top-level subnets section. An entry is a
+// CIDR string or a mapping with a cidr and an optional name.
+func collectSubnets(root *ast.MappingNode) ([]subnet, error) {
+	seq, ok := mappingValue(root, "subnets").(*ast.SequenceNode)
+	if !ok {
+		return nil, nil
+	}
+
+	var out []subnet
+	for _, item := range seq.Values {
+		var cidr, name string
+		if m, ok := item.(*ast.MappingNode); ok {
+			cidr, name = stringValue(m, "cidr"), stringValue(m, "name")
+		} else if s, ok := item.(ast.ScalarNode); ok {
+			cidr = fmt.Sprint(s.GetValue())
+		}
+
+		p, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("subnets: %w", err)
+		}
+		out = append(out, subnet{Prefix: p.Masked(), Name: name})
+	}
+	return out, nil
+}
+
+// subnetUsage is the utilization of one subnet.
+type subnetUsage struct {
+	Subnet string `json:"subnet"`
+	Name   string `json:"name,omitempty"`
+	// Declared is false for the /24 and /64 groups used when no subnets are declared.
+	Declared bool `json:"declared"`
+	Used     int  `json:"used"`
+	// Disabled counts addresses claimed only by disabled entries.
+	Disabled int `json:"disabled"`
+	// Capacity is the number of assignable addresses, 0 when it does not fit in 64 bits.
+	Capacity uint64 `json:"capacity,omitempty"`
+
+	prefix netip.Prefix
+}
+
+// addressEntry is an address with the hosts that claim it.
+type addressEntry struct {
+	IP    string   `json:"ip"`
+	Hosts []string `json:"hosts"`
+	// Zone is the reverse zone the address needs, for missing reverse zone findings.
+	Zone string `json:"zone,omitempty"`
+}
+
+// ipamReport summarizes address usage across the inventory.
+type ipamReport struct {
+	Subnets        []subnetUsage  `json:"subnets"`
+	Conflicts      []addressEntry `json:"conflicts"`
+	Outside        []addressEntry `json:"outside_subnets"`
+	MissingReverse []addressEntry `json:"missing_reverse_zones"`
+}
+
+// usableCount returns the number of assignable addresses of p, or false if it does not
+// fit in 64 bits.
+func usableCount(p netip.Prefix) (uint64, bool) {
+	host := p.Addr().BitLen() - p.Bits()
+	if host >= 64 {
+		return 0, false
+	}
+
+	n := uint64(1) << host
+	switch {
+	case p.Addr().Is4() && p.Bits() <= 30:
+		n -= 2
+	case p.Addr().Is6() && p.Bits() <= 126:
+		n--
+	}
+	return n, true
+}
+
+// defaultSubnet is the group an address falls into when no subnets are declared: its
+// /24 for IPv4 and its /64 for IPv6, the same grouping the probe limits use.
+func defaultSubnet(a netip.Addr) netip.Prefix {
+	bits := 64
+	if a.Is4() {
+		bits = 24
+	}
+	p, _ := a.Prefix(bits)
+	return p
+}
+
+// expectedReverseZone returns the reverse zone createMissingPTRs-style layouts put the
+// PTR of a in: the /24 zone for IPv4 and the /64 nibble zone for IPv6.
+func expectedReverseZone(arpa string, a netip.Addr) string {
+	labels := strings.Split(arpa, ".")
+	if a.Is4() {
+		return strings.Join(labels[1:], ".")
+	}
+	return strings.Join(labels[16:], ".")
+}
+
+// buildIPAMReport groups the addresses of the inventory by subnet and collects IP
+// conflicts between A and AAAA records, addresses outside every declared subnet and
+// records whose reverse zone is not in the inventory.
+func buildIPAMReport(records []dnsRecord, nameservers []nameserver, declared []subnet) ipamReport {
+	type addrState struct {
+		active bool
+		hosts  []string
+	}
+
+	uses := addressUses(records, nameservers)
+	addrs := map[netip.Addr]*addrState{}
+	forward := map[netip.Addr][]string{}
+	var order []netip.Addr
+
+	for _, u := range uses {
+		st, ok := addrs[u.Addr]
+		if !ok {
+			st = &addrState{}
+			addrs[u.Addr] = st
+			order = append(order, u.Addr)
+		}
+		st.active = st.active || !u.Disabled
+		host := strings.ToLower(u.FQDN)
+		if !slices.Contains(st.hosts, host) {
+			st.hosts = append(st.hosts, host)
+		}
+		if !u.Disabled && (u.Type == "A" || u.Type == "AAAA") && !slices.Contains(forward[u.Addr], host) {
+			forward[u.Addr] = append(forward[u.Addr], host)
+		}
+	}
+	slices.SortFunc(order, func(a, b netip.Addr) int { return a.Compare(b) })
+
+	// empty rather than nil, so that JSON output has [] for sections without findings
+	report := ipamReport{
+		Subnets:        []subnetUsage{},
+		Conflicts:      []addressEntry{},
+		Outside:        []addressEntry{},
+		MissingReverse: []addressEntry{},
+	}
+	usage := map[netip.Prefix]*subnetUsage{}
+	for _, s := range declared {
+		if _, ok := usage[s.Prefix]; ok {
+			continue
+		}
+		usage[s.Prefix] = &subnetUsage{Subnet: s.Prefix.String(), Name: s.Name, Declared: true, prefix: s.Prefix}
+	}
+
+	for _, a := range order {
+		st := addrs[a]
+
+		var group *subnetUsage
+		if len(declared) == 0 {
+			p := defaultSubnet(a)
+			if usage[p] == nil {
+				usage[p] = &subnetUsage{Subnet: p.String(), prefix: p}
+			}
+			group = usage[p]
+		} else {
+			for _, s := range declared {
+				if s.Prefix.Contains(a) && (group == nil || s.Prefix.Bits() > group.prefix.Bits()) {
+					group = usage[s.Prefix]
+				}
+			}
+		}
+
+		switch {
+		case group == nil:
+			report.Outside = append(report.Outside, addressEntry{IP: a.String(), Hosts: st.hosts})
+		case st.active:
+			group.Used++
+		default:
+			group.Disabled++
+		}
+
+		if hosts := forward[a]; len(hosts) > 1 {
+			report.Conflicts = append(report.Conflicts, addressEntry{IP: a.String(), Hosts: hosts})
+		}
+	}
+
+	for _, u := range usage {
+		u.Capacity, _ = usableCount(u.prefix)
+		report.Subnets = append(report.Subnets, *u)
+	}
+	slices.SortFunc(report.Subnets, func(a, b subnetUsage) int {
+		if c := a.prefix.Addr().Compare(b.prefix.Addr()); c != 0 {
+			return c
+		}
+		return a.prefix.Bits() - b.prefix.Bits()
+	})
+
+	zones := recordZones(records)
+	for _, r := range records {
+		if r.Disabled || (r.Type != "A" && r.Type != "AAAA") {
+			continue
+		}
+		a, err := netip.ParseAddr(r.Value)
+		if err != nil {
+			continue
+		}
+		arpa, err := dns.ReverseAddr(a.String())
+		if err != nil {
+			continue
+		}
+		if _, ok := coveringZone(zones, arpa); ok {
+			continue
+		}
+		report.MissingReverse = append(report.MissingReverse, addressEntry{
+			IP:    a.String(),
+			Hosts: []string{hostName(r.Name())},
+			Zone:  expectedReverseZone(arpa, a),
+		})
+	}
+
+	return report
+}
+
+// ipamReportOptions configures ipam report.
+type ipamReportOptions struct {
+	File string
+	// Subnets are declared in addition to the inventory's subnets section.
+	Subnets []string
+	// Format is text or json.
+	Format string
+}
+
+// runIPAMReport prints subnet utilization and address findings for the inventory.
+func runIPAMReport(opts ipamReportOptions, out io.Writer) error {
+	switch opts.Format {
+	case "", "text", "json":
+	default:
+		return fmt.Errorf("unknown report format %q (want one of %s)", opts.Format, strings.Join(ipamReportFormats, ", "))
+	}
+
+	_, root, err := loadInventory(opts.File)
+	if err != nil {
+		return err
+	}
+
+	declared, err := collectSubnets(root)
+	if err != nil {
+		return err
+	}
+	for _, s := range opts.Subnets {
+		p, err := netip.ParsePrefix(strings.TrimSpace(s))
+		if err != nil {
+			return fmt.Errorf("invalid --subnet: %w", err)
+		}
+		declared = append(declared, subnet{Prefix: p.Masked()})
+	}
+
+	report := buildIPAMReport(collectRecords(root), collectNameservers(root), declared)
+
+	if opts.Format == "json" {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+
+	printIPAMReport(out, report, len(declared) > 0)
+	return nil
+}
+
+// printIPAMReport writes the report as text.
+func printIPAMReport(w io.Writer, r ipamReport, declared bool) {
+	if declared {
+		fmt.Fprintln(w, "subnets:")
+	} else {
+		fmt.Fprintln(w, "subnets (none declared, grouped by /24 and /64):")
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "  SUBNET\tNAME\tUSED\tDISABLED\tFREE\tUTILIZATION")
+	for _, s := range r.Subnets {
+		name := s.Name
+		if name == "" {
+			name = "-"
+		}
+		free, util := "-", "-"
+		if s.Capacity > 0 {
+			taken := uint64(s.Used + s.Disabled)
+			if taken < s.Capacity {
+				free = strconv.FormatUint(s.Capacity-taken, 10)
+			} else {
+				free = "0"
+			}
+			util = fmt.Sprintf("%.1f%%", float64(taken)*100/float64(s.Capacity))
+		}
+		fmt.Fprintf(tw, "  %s\t%s\t%d\t%d\t%s\t%s\n", s.Subnet, name, s.Used, s.Disabled, free, util)
+	}
+	tw.Flush()
+
+	section := func(title string, entries []addressEntry, line func(addressEntry) string) {
+		fmt.Fprintf(w, "%s:\n", title)
+		if len(entries) == 0 {
+			fmt.Fprintln(w, "  none")
+			return
+		}
+		for _, e := range entries {
+			fmt.Fprintf(w, "  %s\n", line(e))
+		}
+	}
+
+	section("conflicts", r.Conflicts, func(e addressEntry) string {
+		return e.IP + ": " + strings.Join(e.Hosts, ", ")
+	})
+	if declared {
+		section("outside declared subnets", r.Outside, func(e addressEntry) string {
+			return e.IP + ": " + strings.Join(e.Hosts, ", ")
+		})
+	}
+	section("missing reverse zones", r.MissingReverse, func(e addressEntry) string {
+		return e.Zone + ": " + strings.Join(e.Hosts, ", ") + " (" + e.IP + ")"
+	})
+}
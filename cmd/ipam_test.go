@@ -0,0 +1,320 @@
+package cmd
+
+import (
+	"bytes"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+const ipamFixture = `dns_records:
+  - host: gw
+    type: A
+    zone: example.lan.
+    record_value: 10.0.1.1
+  - host: web01
+    type: A
+    zone: example.lan.
+    record_value: 10.0.1.2
+  - host: printer
+    type: PTR
+    zone: 1.0.10.in-addr.arpa.
+    record_value: 3
+  - host: old01 # DISABLED: unreachable
+    type: A
+    zone: example.lan.
+    record_value: 10.0.1.4
+  - host: db
+    type: AAAA
+    zone: example.lan.
+    record_value: fd00::1
+nameservers:
+  - name: ns1
+    ip_address: 10.0.1.5
+`
+
+func TestParseAddrRange(t *testing.T) {
+	tests := []struct {
+		in, from, to string
+	}{
+		{"10.0.1.7", "10.0.1.7", "10.0.1.7"},
+		{"10.0.1.10 - 10.0.1.20", "10.0.1.10", "10.0.1.20"},
+		{"10.0.1.0/28", "10.0.1.0", "10.0.1.15"},
+		{"10.0.1.9/29", "10.0.1.8", "10.0.1.15"},
+		{"fd00::/126", "fd00::", "fd00::3"},
+	}
+
+	for _, tt := range tests {
+		r, err := parseAddrRange(tt.in)
+		if err != nil || r.from.String() != tt.from || r.to.String() != tt.to {
+			t.Errorf("parseAddrRange(%q) = %v-%v, %v; want %s-%s", tt.in, r.from, r.to, err, tt.from, tt.to)
+		}
+	}
+
+	for _, bad := range []string{"10.0.1", "10.0.1.20-10.0.1.10", "10.0.1.1-fd00::1", "10.0.1.0/33"} {
+		if _, err := parseAddrRange(bad); err == nil {
+			t.Errorf("parseAddrRange(%q) returned nil, want error", bad)
+		}
+	}
+}
+
+func TestFreeAddresses(t *testing.T) {
+	used := map[netip.Addr]bool{
+		netip.MustParseAddr("10.0.1.1"): true,
+		netip.MustParseAddr("10.0.1.3"): true,
+	}
+	reserved := []addrRange{{netip.MustParseAddr("10.0.1.4"), netip.MustParseAddr("10.0.1.5")}}
+
+	got := freeAddresses(netip.MustParsePrefix("10.0.1.0/29"), used, reserved, 10)
+	if want := "10.0.1.2 10.0.1.6"; strings.Join(addrStrings(got), " ") != want {
+		t.Fatalf("freeAddresses = %v, want %s (no network or broadcast address)", got, want)
+	}
+
+	if got := freeAddresses(netip.MustParsePrefix("10.0.1.8/31"), nil, nil, 10); len(got) != 2 {
+		t.Fatalf("freeAddresses(/31) = %v, want both addresses", got)
+	}
+	if got := freeAddresses(netip.MustParsePrefix("fd00::/64"), nil, nil, 1); len(got) != 1 || got[0].String() != "fd00::1" {
+		t.Fatalf("freeAddresses(/64) = %v, want fd00::1", got)
+	}
+
+	all := []addrRange{{netip.MustParseAddr("10.0.0.0"), netip.MustParseAddr("10.255.255.255")}}
+	if got := freeAddresses(netip.MustParsePrefix("10.0.0.0/8"), nil, all, 1); len(got) != 0 {
+		t.Fatalf("freeAddresses with everything reserved = %v, want none", got)
+	}
+}
+
+func addrStrings(addrs []netip.Addr) []string {
+	out := make([]string, len(addrs))
+	for i, a := range addrs {
+		out[i] = a.String()
+	}
+	return out
+}
+
+func TestRunIPAMNext(t *testing.T) {
+	path := writeInventory(t, ipamFixture)
+
+	tests := []struct {
+		name string
+		opts ipamOptions
+		want string
+	}{
+		{"skips records, ptrs, disabled and nameservers", ipamOptions{CIDR: "10.0.1.0/24"}, "10.0.1.6"},
+		{"reuse disabled", ipamOptions{CIDR: "10.0.1.0/24", ReuseDisabled: true}, "10.0.1.4"},
+		{"reserved", ipamOptions{CIDR: "10.0.1.0/24", Reserve: []string{"10.0.1.6-10.0.1.9", "10.0.1.10"}}, "10.0.1.11"},
+		{"count", ipamOptions{CIDR: "10.0.1.0/24", Count: 3}, "10.0.1.6 10.0.1.7 10.0.1.8"},
+		{"ipv6", ipamOptions{CIDR: "fd00::/64"}, "fd00::2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.File = path
+
+			var out bytes.Buffer
+			if err := runIPAMNext(tt.opts, &out); err != nil {
+				t.Fatalf("runIPAMNext returned error: %v", err)
+			}
+			if got := strings.Join(strings.Fields(out.String()), " "); got != tt.want {
+				t.Fatalf("runIPAMNext printed %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if got := readInventory(t, path); got != ipamFixture {
+		t.Fatalf("listing free addresses modified the inventory:\n%s", got)
+	}
+
+	full := ipamOptions{File: path, CIDR: "10.0.1.0/29", Reserve: []string{"10.0.1.6"}}
+	if err := runIPAMNext(full, &bytes.Buffer{}); err == nil || !strings.Contains(err.Error(), "no free address in 10.0.1.0/29") {
+		t.Fatalf("runIPAMNext on a full subnet error = %v", err)
+	}
+}
+
+func TestRunIPAMNext_Allocate(t *testing.T) {
+	path := writeInventory(t, ipamFixture)
+
+	opts := ipamOptions{
+		File:   path,
+		CIDR:   "10.0.1.0/24",
+		Record: recordOptions{File: path, Host: "web02", Zone: "example.lan.", PTR: true},
+	}
+
+	var out bytes.Buffer
+	if err := runIPAMNext(opts, &out); err != nil {
+		t.Fatalf("runIPAMNext returned error: %v", err)
+	}
+	if err := runIPAMNext(opts, &out); err != nil {
+		t.Fatalf("second allocation returned error: %v", err)
+	}
+
+	_, root, err := loadInventory(path)
+	if err != nil {
+		t.Fatalf("loadInventory returned error: %v", err)
+	}
+
+	var added []string
+	for _, r := range collectRecords(root) {
+		if r.Host == "web02" {
+			added = append(added, r.Type+" "+r.Zone+" "+r.Value)
+		}
+	}
+	want := []string{
+		"A example.lan. 10.0.1.6",
+		"PTR 1.0.10.in-addr.arpa. 6",
+		"A example.lan. 10.0.1.7",
+		"PTR 1.0.10.in-addr.arpa. 7",
+	}
+	if strings.Join(added, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("allocated records:\n%s\nwant\n%s", strings.Join(added, "\n"), strings.Join(want, "\n"))
+	}
+	if !strings.Contains(out.String(), "added web02.example.lan. A 10.0.1.6") {
+		t.Fatalf("unexpected report:\n%s", out.String())
+	}
+
+	opts.Count = 2
+	if err := runIPAMNext(opts, &out); err == nil {
+		t.Fatalf("runIPAMNext with --count and --host returned nil, want error")
+	}
+	opts.Count, opts.Record.Zone = 1, ""
+	if err := runIPAMNext(opts, &out); err == nil {
+		t.Fatalf("runIPAMNext with --host and no --zone returned nil, want error")
+	}
+}
+
+const ipamReportFixture = `subnets:
+  - cidr: 10.0.1.0/24
+    name: servers
+  - 10.0.0.0/24
+dns_records:
+  - host: web01
+    type: A
+    zone: example.lan.
+    record_value: 10.0.1.5
+  - host: web02
+    type: A
+    zone: example.lan.
+    record_value: 10.0.1.5
+  - host: web01
+    type: PTR
+    zone: 1.0.10.in-addr.arpa.
+    record_value: 5
+  - host: db
+    type: A
+    zone: example.lan.
+    record_value: 10.0.2.9
+  - host: old # DISABLED: unreachable
+    type: A
+    zone: example.lan.
+    record_value: 10.0.1.9
+  - host: spare # DISABLED: unreachable
+    type: A
+    zone: example.lan.
+    record_value: 10.0.1.5
+nameservers:
+  - name: ns1
+    ip_address: 10.0.0.53
+`
+
+func TestBuildIPAMReport(t *testing.T) {
+	path := writeInventory(t, ipamReportFixture)
+	_, root, err := loadInventory(path)
+	if err != nil {
+		t.Fatalf("loadInventory returned error: %v", err)
+	}
+
+	declared, err := collectSubnets(root)
+	if err != nil {
+		t.Fatalf("collectSubnets returned error: %v", err)
+	}
+	if len(declared) != 2 || declared[0].Name != "servers" || declared[1].Prefix.String() != "10.0.0.0/24" {
+		t.Fatalf("collectSubnets = %+v", declared)
+	}
+
+	report := buildIPAMReport(collectRecords(root), collectNameservers(root), declared)
+
+	if len(report.Subnets) != 2 {
+		t.Fatalf("report has %d subnets, want 2: %+v", len(report.Subnets), report.Subnets)
+	}
+	if s := report.Subnets[1]; s.Subnet != "10.0.1.0/24" || s.Used != 1 || s.Disabled != 1 || s.Capacity != 254 {
+		t.Fatalf("servers usage = %+v, want 1 used, 1 disabled of 254", s)
+	}
+	if s := report.Subnets[0]; s.Used != 1 {
+		t.Fatalf("nameserver address not counted: %+v", s)
+	}
+
+	// the disabled record on the same address is not a conflict
+	if len(report.Conflicts) != 1 || report.Conflicts[0].IP != "10.0.1.5" || strings.Join(report.Conflicts[0].Hosts, ",") != "web01.example.lan,web02.example.lan" {
+		t.Fatalf("conflicts = %+v", report.Conflicts)
+	}
+	if len(report.Outside) != 1 || report.Outside[0].IP != "10.0.2.9" {
+		t.Fatalf("outside = %+v", report.Outside)
+	}
+	if len(report.MissingReverse) != 1 || report.MissingReverse[0].Zone != "2.0.10.in-addr.arpa." {
+		t.Fatalf("missing reverse zones = %+v", report.MissingReverse)
+	}
+}
+
+func TestBuildIPAMReport_Undeclared(t *testing.T) {
+	records := []dnsRecord{
+		{Host: "a", Type: "A", Zone: "example.lan.", Value: "10.0.1.5"},
+		{Host: "b", Type: "A", Zone: "example.lan.", Value: "10.0.2.5"},
+		{Host: "c", Type: "AAAA", Zone: "example.lan.", Value: "fd00::5"},
+		{Host: "c", Type: "PTR", Zone: "0.0.0.0.0.0.0.0.0.0.0.0.0.0.d.f.ip6.arpa.", Value: "5.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0"},
+	}
+
+	report := buildIPAMReport(records, nil, nil)
+
+	var subnets []string
+	for _, s := range report.Subnets {
+		if s.Declared {
+			t.Errorf("default group %s marked as declared", s.Subnet)
+		}
+		subnets = append(subnets, s.Subnet)
+	}
+	if got := strings.Join(subnets, " "); got != "10.0.1.0/24 10.0.2.0/24 fd00::/64" {
+		t.Fatalf("default groups = %s", got)
+	}
+	if len(report.Outside) != 0 {
+		t.Fatalf("without declared subnets nothing is outside: %+v", report.Outside)
+	}
+	if len(report.MissingReverse) != 2 {
+		t.Fatalf("missing reverse zones = %+v, want the two IPv4 records", report.MissingReverse)
+	}
+}
+
+func TestRunIPAMReport(t *testing.T) {
+	path := writeInventory(t, ipamReportFixture)
+
+	var out bytes.Buffer
+	if err := runIPAMReport(ipamReportOptions{File: path, Subnets: []string{"10.0.2.0/24"}}, &out); err != nil {
+		t.Fatalf("runIPAMReport returned error: %v", err)
+	}
+
+	text := out.String()
+	for _, want := range []string{
+		"10.0.1.0/24  servers  1     1         252   0.8%",
+		"10.0.2.0/24  -        1     0         253   0.4%",
+		"  10.0.1.5: web01.example.lan, web02.example.lan",
+		"outside declared subnets:\n  none",
+		"  2.0.10.in-addr.arpa.: db.example.lan (10.0.2.9)",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("report missing %q:\n%s", want, text)
+		}
+	}
+
+	var js bytes.Buffer
+	if err := runIPAMReport(ipamReportOptions{File: path, Format: "json"}, &js); err != nil {
+		t.Fatalf("runIPAMReport(json) returned error: %v", err)
+	}
+	if !strings.Contains(js.String(), `"outside_subnets": [`) || !strings.Contains(js.String(), `"capacity": 254`) {
+		t.Fatalf("unexpected json report:\n%s", js.String())
+	}
+
+	if err := runIPAMReport(ipamReportOptions{File: path, Subnets: []string{"10.0.2.0"}}, &out); err == nil {
+		t.Fatalf("runIPAMReport with an invalid --subnet returned nil, want error")
+	}
+	if err := runIPAMReport(ipamReportOptions{File: path, Format: "csv"}, &out); err == nil {
+		t.Fatalf("runIPAMReport with an unknown format returned nil, want error")
+	}
+}
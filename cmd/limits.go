@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+// probeLimits bounds how aggressively runPingWorkers probes the network.
+// Zero values mean no limit.
+type probeLimits struct {
+	// Rate caps the number of probes started per second across all workers.
+	Rate float64
+	// PerSubnet caps the number of concurrent probes into a single /24 (IPv4) or /64 (IPv6).
+	PerSubnet int
+}
+
+// probeGate enforces probeLimits for the workers of a single run.
+type probeGate struct {
+	limiter   *rate.Limiter
+	perSubnet int
+
+	mu      sync.Mutex
+	subnets map[string]chan struct{}
+}
+
+// newProbeGate returns a gate for limits. The token bucket holds a single token so that
+// probes are spread evenly instead of being sent in bursts.
+func newProbeGate(limits probeLimits) *probeGate {
+	g := &probeGate{
+		perSubnet: limits.PerSubnet,
+		subnets:   map[string]chan struct{}{},
+	}
+	if limits.Rate > 0 {
+		g.limiter = rate.NewLimiter(rate.Limit(limits.Rate), 1)
+	}
+	return g
+}
+
+// acquire blocks until a probe of ip may start, returning a function that releases its
+// subnet slot. It returns ctx's error if ctx is done first, holding nothing, and
+// context.DeadlineExceeded if the rate limit would only let the probe start after ctx's deadline.
+func (g *probeGate) acquire(ctx context.Context, ip string) (func(), error) {
+	release := func() {}
+
+	if g.perSubnet > 0 {
+		slots := g.subnet(subnetKey(ip))
+		select {
+		case slots <- struct{}{}:
+			release = func() { <-slots }
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	if g.limiter != nil {
+		if err := g.limiter.Wait(ctx); err != nil {
+			release()
+			// Wait gives up early, before ctx is done, when the next token is due after the deadline
+			if ctx.Err() == nil {
+				err = fmt.Errorf("%w: %v", context.DeadlineExceeded, err)
+			}
+			return nil, err
+		}
+	}
+
+	return release, nil
+}
+
+// subnet returns the semaphore for key, creating it on first use.
+func (g *probeGate) subnet(key string) chan struct{} {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	slots, ok := g.subnets[key]
+	if !ok {
+		slots = make(chan struct{}, g.perSubnet)
+		g.subnets[key] = slots
+	}
+	return slots
+}
+
+// subnetKey groups an address into its /24 (IPv4) or /64 (IPv6) network.
+// Values that do not parse as IPs are their own group.
+func subnetKey(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ip
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(24, 32)).String() + "/24"
+	}
+	return parsed.Mask(net.CIDRMask(64, 128)).String() + "/64"
+}
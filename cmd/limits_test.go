@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSubnetKey(t *testing.T) {
+	tests := map[string]string{
+		"10.0.1.5":          "10.0.1.0/24",
+		"10.0.1.250":        "10.0.1.0/24",
+		"10.0.2.5":          "10.0.2.0/24",
+		"2001:db8:0:1::10":  "2001:db8:0:1::/64",
+		"2001:db8:0:1:ff::": "2001:db8:0:1::/64",
+		"not-an-ip":         "not-an-ip",
+	}
+
+	for ip, want := range tests {
+		if got := subnetKey(ip); got != want {
+			t.Errorf("subnetKey(%q) = %q, want %q", ip, got, want)
+		}
+	}
+}
+
+// TestRunPingWorkers_PerSubnetLimit tests that no more than PerSubnet probes run at once in a subnet.
+func TestRunPingWorkers_PerSubnetLimit(t *testing.T) {
+	var mu sync.Mutex
+	inFlight := map[string]int{}
+	peak := map[string]int{}
+
+	stubProbe(t, func(ctx context.Context, ip string, timeout time.Duration) bool {
+		key := subnetKey(ip)
+
+		mu.Lock()
+		inFlight[key]++
+		if inFlight[key] > peak[key] {
+			peak[key] = inFlight[key]
+		}
+		mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		inFlight[key]--
+		mu.Unlock()
+		return true
+	})
+
+	var jobs []pingJob
+	for _, ip := range []string{"10.0.1.1", "10.0.1.2", "10.0.1.3", "10.0.1.4", "10.0.2.1", "10.0.2.2", "10.0.2.3", "10.0.2.4"} {
+		jobs = append(jobs, pingJob{IP: ip})
+	}
+
+	results := runPingWorkers(context.Background(), jobs, time.Second, 8, probeLimits{PerSubnet: 2}, nil, nil)
+
+	if n := len(unprobed(results)); n != 0 {
+		t.Fatalf("%d jobs were not probed", n)
+	}
+	for key, p := range peak {
+		if p > 2 {
+			t.Fatalf("subnet %s had %d concurrent probes, want at most 2", key, p)
+		}
+	}
+}
+
+// TestRunPingWorkers_Rate tests that the token bucket spaces out probe starts.
+func TestRunPingWorkers_Rate(t *testing.T) {
+	var count atomic.Int32
+	stubProbe(t, func(ctx context.Context, ip string, timeout time.Duration) bool {
+		count.Add(1)
+		return true
+	})
+
+	jobs := []pingJob{{IP: "10.0.0.1"}, {IP: "10.0.0.2"}, {IP: "10.0.0.3"}, {IP: "10.0.0.4"}, {IP: "10.0.0.5"}}
+
+	start := time.Now()
+	runPingWorkers(context.Background(), jobs, time.Second, 5, probeLimits{Rate: 50}, nil, nil)
+	elapsed := time.Since(start)
+
+	// one token is available immediately, the other four arrive 20ms apart: 80ms, less some slack
+	if elapsed < 70*time.Millisecond {
+		t.Fatalf("5 probes at 50/s finished in %s, want at least 70ms", elapsed)
+	}
+	if count.Load() != 5 {
+		t.Fatalf("probe ran %d times, want 5", count.Load())
+	}
+}
+
+// TestRunPingWorkers_RateRespectsCancel tests that workers waiting for a token stop on cancellation.
+func TestRunPingWorkers_RateRespectsCancel(t *testing.T) {
+	stubProbe(t, func(ctx context.Context, ip string, timeout time.Duration) bool {
+		return true
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	jobs := []pingJob{{IP: "10.0.0.1"}, {IP: "10.0.0.2"}, {IP: "10.0.0.3"}}
+
+	start := time.Now()
+	// the first token is free, the next one would take ten seconds
+	results := runPingWorkers(ctx, jobs, time.Second, 3, probeLimits{Rate: 0.1}, nil, nil)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("runPingWorkers took %s after cancellation", elapsed)
+	}
+
+	if n := len(unprobed(results)); n != 2 {
+		t.Fatalf("%d jobs were not probed, want 2", n)
+	}
+}
+
+// TestRunPingWorkers_RatePastDeadline tests that a single worker keeps draining jobs the
+// rate limiter gives up on ahead of the deadline, and that they carry the deadline error.
+func TestRunPingWorkers_RatePastDeadline(t *testing.T) {
+	stubProbe(t, func(ctx context.Context, ip string, timeout time.Duration) bool {
+		return true
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	jobs := []pingJob{{IP: "10.0.0.1"}, {IP: "10.0.0.2"}, {IP: "10.0.0.3"}}
+	results := runPingWorkers(ctx, jobs, time.Second, 1, probeLimits{Rate: 0.01}, nil, nil)
+	if ctx.Err() != nil {
+		t.Fatalf("runPingWorkers waited for the deadline")
+	}
+
+	if results[0].status != probeUp {
+		t.Fatalf("first job status = %s, want up", results[0].status)
+	}
+	for _, r := range results[1:] {
+		if r.status != probeUnknown || !errors.Is(r.err, context.DeadlineExceeded) {
+			t.Fatalf("job %s = %s (%v), want unknown with a deadline error", r.job.IP, r.status, r.err)
+		}
+	}
+}
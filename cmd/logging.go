@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// logger receives diagnostics. It writes to stderr so that stdout stays reserved for
+// command output such as the rewritten YAML.
+var logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+
+// newLogger builds a logger writing to w at level in the given format (text or json).
+func newLogger(w io.Writer, level, format string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid --log-level %q (want debug, info, warn or error)", level)
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl}
+	switch strings.ToLower(format) {
+	case "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("invalid --log-format %q (want text or json)", format)
+	}
+}
+
+// setupLogging replaces the package logger according to the --log-level and --log-format flags.
+func setupLogging(level, format string) error {
+	l, err := newLogger(os.Stderr, level, format)
+	if err != nil {
+		return err
+	}
+	logger = l
+	return nil
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// captureLogs routes the package logger into a buffer at debug level for the duration of the test.
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+	orig := logger
+	logger = slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	t.Cleanup(func() { logger = orig })
+	return &buf
+}
+
+// logEntries decodes JSON log lines.
+func logEntries(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+
+	var out []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var m map[string]any
+		if err := json.Unmarshal([]byte(line), &m); err != nil {
+			t.Fatalf("log line is not JSON: %q", line)
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+func TestNewLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l, err := newLogger(&buf, "warn", "json")
+	if err != nil {
+		t.Fatalf("newLogger returned error: %v", err)
+	}
+
+	l.Info("hidden")
+	l.Warn("shown", "ip", "10.0.0.1")
+
+	entries := logEntries(t, &buf)
+	if len(entries) != 1 || entries[0]["msg"] != "shown" || entries[0]["ip"] != "10.0.0.1" {
+		t.Fatalf("entries = %v", entries)
+	}
+
+	buf.Reset()
+	if l, err = newLogger(&buf, "DEBUG", "text"); err != nil {
+		t.Fatalf("newLogger(DEBUG, text) returned error: %v", err)
+	}
+	l.Debug("probe", "ip", "10.0.0.1")
+	if !strings.Contains(buf.String(), "level=DEBUG msg=probe ip=10.0.0.1") {
+		t.Fatalf("text output = %q", buf.String())
+	}
+}
+
+func TestNewLogger_Invalid(t *testing.T) {
+	if _, err := newLogger(&bytes.Buffer{}, "loud", "text"); err == nil || !strings.Contains(err.Error(), "--log-level") {
+		t.Errorf("bad level error = %v", err)
+	}
+	if _, err := newLogger(&bytes.Buffer{}, "info", "xml"); err == nil || !strings.Contains(err.Error(), "--log-format") {
+		t.Errorf("bad format error = %v", err)
+	}
+}
@@ -0,0 +1,332 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/goccy/go-yaml/ast"
+)
+
+// monitorOptions configures the monitor daemon.
+type monitorOptions struct {
+	File     string
+	Interval time.Duration
+	Timeout  time.Duration
+	Workers  int
+	Limits   probeLimits
+	Liveness livenessOptions
+	// Listen is the address of the HTTP server exposing /metrics.
+	Listen string
+}
+
+// monitorJob is a pingJob with the labels it is exported under.
+type monitorJob struct {
+	job  pingJob
+	name string
+	kind string
+}
+
+// hostState is the most recent probe outcome of one host.
+type hostState struct {
+	Name    string
+	Kind    string
+	Up      bool
+	RTT     time.Duration
+	Checked time.Time
+}
+
+// monitor probes the inventory on an interval and serves the results as Prometheus metrics.
+type monitor struct {
+	opts    monitorOptions
+	watcher *fileWatcher
+	check   probeFunc
+
+	mu           sync.RWMutex
+	jobs         []monitorJob
+	hosts        map[string]hostState
+	cycles       uint64
+	reloads      uint64
+	reloadErrors uint64
+	lastCycle    time.Duration
+}
+
+// newMonitor loads the inventory and starts watching it for changes picked up by reload.
+func newMonitor(opts monitorOptions) (*monitor, error) {
+	if opts.File == stdinPath {
+		return nil, fmt.Errorf("monitor cannot read --file from stdin: it reloads the file when it changes")
+	}
+
+	check, err := newLivenessCheck(opts.Liveness)
+	if err != nil {
+		return nil, err
+	}
+
+	jobs, err := loadMonitorJobs(opts.File)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &monitor{
+		opts:    opts,
+		watcher: &fileWatcher{path: opts.File},
+		check:   check,
+		jobs:    jobs,
+		hosts:   map[string]hostState{},
+	}
+	if _, err := m.watcher.changed(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// loadMonitorJobs reads the inventory and returns the nameserver and record jobs with their labels.
+func loadMonitorJobs(path string) ([]monitorJob, error) {
+	_, root, err := loadInventory(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var jobs []monitorJob
+	for _, j := range collectNameserverJobs(root) {
+		jobs = append(jobs, monitorJob{job: j, name: jobName(j, "name"), kind: "nameserver"})
+	}
+	for _, j := range collectDNSRecordJobs(root) {
+		jobs = append(jobs, monitorJob{job: j, name: jobName(j, "host"), kind: "record"})
+	}
+	return jobs, nil
+}
+
+// jobName returns the value of key in the job's mapping, falling back to its IP.
+func jobName(j pingJob, key string) string {
+	if m, ok := j.Node.(*ast.MappingNode); ok {
+		if v := stringValue(m, key); v != "" {
+			return v
+		}
+	}
+	return j.IP
+}
+
+// reload re-reads the inventory if the file changed since the last load. A broken file
+// keeps the previous jobs so that a bad edit does not blank the dashboards.
+func (m *monitor) reload() {
+	changed, err := m.watcher.changed()
+	if err == nil && !changed {
+		return
+	}
+
+	var jobs []monitorJob
+	if err == nil {
+		jobs, err = loadMonitorJobs(m.opts.File)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err != nil {
+		m.reloadErrors++
+		logger.Warn("reload failed, keeping previous inventory", "file", m.opts.File, "error", err)
+		return
+	}
+
+	m.reloads++
+	m.jobs = jobs
+	logger.Info("reloaded inventory", "file", m.opts.File, "hosts", len(jobs))
+}
+
+// cycle probes every job once and replaces the host table. Hosts whose probe was cut
+// short keep their previous state; hosts no longer in the inventory are dropped.
+func (m *monitor) cycle(ctx context.Context) {
+	m.mu.RLock()
+	jobs := m.jobs
+	m.mu.RUnlock()
+
+	pingJobs := make([]pingJob, len(jobs))
+	for i, j := range jobs {
+		pingJobs[i] = j.job
+	}
+
+	started := time.Now()
+	results := runPingWorkers(ctx, pingJobs, m.opts.Timeout, m.opts.Workers, m.opts.Limits, m.check, nil)
+	elapsed := time.Since(started)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	hosts := make(map[string]hostState, len(results))
+	for i, r := range results {
+		if r.status == probeUnknown {
+			if prev, ok := m.hosts[r.job.IP]; ok {
+				hosts[r.job.IP] = prev
+			}
+			continue
+		}
+		hosts[r.job.IP] = hostState{
+			Name:    jobs[i].name,
+			Kind:    jobs[i].kind,
+			Up:      r.status == probeUp,
+			RTT:     r.rtt,
+			Checked: started,
+		}
+	}
+
+	m.hosts = hosts
+	m.cycles++
+	m.lastCycle = elapsed
+}
+
+// ServeHTTP writes the metrics in the Prometheus text exposition format.
+func (m *monitor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.writeMetrics(w)
+}
+
+// writeMetrics renders every metric, with hosts sorted by IP for stable output.
+func (m *monitor) writeMetrics(w io.Writer) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	ips := make([]string, 0, len(m.hosts))
+	for ip := range m.hosts {
+		ips = append(ips, ip)
+	}
+	sort.Strings(ips)
+
+	metric := func(name, typ, help string) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	}
+
+	metric("dnsctl_host_up", "gauge", "Whether the host answered its last probe (1) or not (0).")
+	for _, ip := range ips {
+		h := m.hosts[ip]
+		up := 0
+		if h.Up {
+			up = 1
+		}
+		fmt.Fprintf(w, "dnsctl_host_up{%s} %d\n", hostLabels(ip, h), up)
+	}
+
+	metric("dnsctl_probe_duration_seconds", "gauge", "Duration of the last probe of the host.")
+	for _, ip := range ips {
+		h := m.hosts[ip]
+		fmt.Fprintf(w, "dnsctl_probe_duration_seconds{%s} %g\n", hostLabels(ip, h), h.RTT.Seconds())
+	}
+
+	metric("dnsctl_probe_timestamp_seconds", "gauge", "Unix time of the last probe of the host.")
+	for _, ip := range ips {
+		h := m.hosts[ip]
+		fmt.Fprintf(w, "dnsctl_probe_timestamp_seconds{%s} %d\n", hostLabels(ip, h), h.Checked.Unix())
+	}
+
+	metric("dnsctl_monitored_hosts", "gauge", "Number of hosts in the loaded inventory.")
+	fmt.Fprintf(w, "dnsctl_monitored_hosts %d\n", len(m.jobs))
+
+	metric("dnsctl_probe_cycle_duration_seconds", "gauge", "Duration of the last full probe cycle.")
+	fmt.Fprintf(w, "dnsctl_probe_cycle_duration_seconds %g\n", m.lastCycle.Seconds())
+
+	metric("dnsctl_probe_cycles_total", "counter", "Completed probe cycles.")
+	fmt.Fprintf(w, "dnsctl_probe_cycles_total %d\n", m.cycles)
+
+	metric("dnsctl_inventory_reloads_total", "counter", "Successful inventory reloads.")
+	fmt.Fprintf(w, "dnsctl_inventory_reloads_total %d\n", m.reloads)
+
+	metric("dnsctl_inventory_reload_errors_total", "counter", "Failed inventory reloads.")
+	fmt.Fprintf(w, "dnsctl_inventory_reload_errors_total %d\n", m.reloadErrors)
+}
+
+// hostLabels renders the label set of a host.
+func hostLabels(ip string, h hostState) string {
+	return fmt.Sprintf(`ip="%s",name="%s",kind="%s"`, labelValue(ip), labelValue(h.Name), labelValue(h.Kind))
+}
+
+// labelValue escapes a Prometheus label value.
+func labelValue(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+// fileWatcher detects changes to a file by polling its modification time and size.
+type fileWatcher struct {
+	path    string
+	modTime time.Time
+	size    int64
+}
+
+// changed reports whether the file differs from the last call, remembering its new state.
+func (fw *fileWatcher) changed() (bool, error) {
+	info, err := os.Stat(fw.path)
+	if err != nil {
+		return false, err
+	}
+
+	if info.ModTime().Equal(fw.modTime) && info.Size() == fw.size {
+		return false, nil
+	}
+
+	fw.modTime = info.ModTime()
+	fw.size = info.Size()
+	return true, nil
+}
+
+// runMonitor serves metrics on opts.Listen and probes the inventory every interval until
+// ctx is cancelled or the process is interrupted. The inventory is reloaded before a cycle
+// whenever the file changed.
+func runMonitor(ctx context.Context, opts monitorOptions) error {
+	if opts.Interval <= 0 {
+		return fmt.Errorf("--interval must be positive")
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	m, err := newMonitor(opts)
+	if err != nil {
+		return err
+	}
+
+	ln, err := net.Listen("tcp", opts.Listen)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m)
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.Serve(ln)
+	}()
+
+	logger.Info("monitoring inventory", "file", opts.File, "hosts", len(m.jobs), "metrics", "http://"+ln.Addr().String()+"/metrics")
+
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		m.cycle(ctx)
+
+		select {
+		case <-ctx.Done():
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			return srv.Shutdown(shutdownCtx)
+		case err := <-serveErr:
+			if errors.Is(err, http.ErrServerClosed) {
+				return nil
+			}
+			return err
+		case <-ticker.C:
+			m.reload()
+		}
+	}
+}
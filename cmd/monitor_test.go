@@ -0,0 +1,171 @@
+package cmd
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+const monitorFixture = `nameservers:
+  - name: ns1
+    ip_address: 10.0.0.53
+dns_records:
+  - host: web01
+    type: A
+    zone: example.lan.
+    record_value: 10.0.1.5
+  - host: web02
+    type: A
+    zone: example.lan.
+    record_value: 10.0.1.6
+`
+
+func TestMonitor_CycleAndMetrics(t *testing.T) {
+	stubProbe(t, func(ctx context.Context, ip string, timeout time.Duration) bool {
+		return ip != "10.0.1.6"
+	})
+
+	m, err := newMonitor(monitorOptions{File: writeInventory(t, monitorFixture), Timeout: time.Second, Workers: 2})
+	if err != nil {
+		t.Fatalf("newMonitor returned error: %v", err)
+	}
+	m.cycle(context.Background())
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	body := rec.Body.String()
+	for _, want := range []string{
+		"# TYPE dnsctl_host_up gauge",
+		`dnsctl_host_up{ip="10.0.0.53",name="ns1",kind="nameserver"} 1`,
+		`dnsctl_host_up{ip="10.0.1.5",name="web01",kind="record"} 1`,
+		`dnsctl_host_up{ip="10.0.1.6",name="web02",kind="record"} 0`,
+		`dnsctl_probe_duration_seconds{ip="10.0.1.5",name="web01",kind="record"} `,
+		"dnsctl_monitored_hosts 3",
+		"dnsctl_probe_cycles_total 1",
+		"# TYPE dnsctl_probe_cycles_total counter",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics missing %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestMonitor_CancelledCycleKeepsState(t *testing.T) {
+	stubProbe(t, func(ctx context.Context, ip string, timeout time.Duration) bool {
+		return true
+	})
+
+	m, err := newMonitor(monitorOptions{File: writeInventory(t, monitorFixture), Timeout: time.Second, Workers: 1})
+	if err != nil {
+		t.Fatalf("newMonitor returned error: %v", err)
+	}
+	m.cycle(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	m.cycle(ctx)
+
+	if len(m.hosts) != 3 {
+		t.Fatalf("cancelled cycle left %d hosts, want 3", len(m.hosts))
+	}
+	for ip, h := range m.hosts {
+		if !h.Up {
+			t.Errorf("host %s lost its previous state", ip)
+		}
+	}
+}
+
+func TestMonitor_Reload(t *testing.T) {
+	stubProbe(t, func(ctx context.Context, ip string, timeout time.Duration) bool {
+		return true
+	})
+
+	path := writeInventory(t, monitorFixture)
+	m, err := newMonitor(monitorOptions{File: path, Timeout: time.Second, Workers: 1})
+	if err != nil {
+		t.Fatalf("newMonitor returned error: %v", err)
+	}
+	m.cycle(context.Background())
+
+	logs := captureLogs(t)
+	m.reload()
+	if m.reloads != 0 || logs.Len() != 0 {
+		t.Fatalf("unchanged file should not be reloaded")
+	}
+
+	// drop web02; the size change is detected even within the mtime granularity
+	trimmed := monitorFixture[:strings.Index(monitorFixture, "  - host: web02")]
+	if err := os.WriteFile(path, []byte(trimmed), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	m.reload()
+	m.cycle(context.Background())
+
+	if m.reloads != 1 || len(m.jobs) != 2 {
+		t.Fatalf("after reload: reloads = %d, jobs = %d; want 1, 2", m.reloads, len(m.jobs))
+	}
+	if _, ok := m.hosts["10.0.1.6"]; ok {
+		t.Fatalf("removed host is still exported")
+	}
+
+	if err := os.WriteFile(path, []byte("dns_records: [\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	m.reload()
+
+	if m.reloadErrors != 1 || len(m.jobs) != 2 {
+		t.Fatalf("broken file: reloadErrors = %d, jobs = %d; want 1, 2", m.reloadErrors, len(m.jobs))
+	}
+	var warned bool
+	for _, e := range logEntries(t, logs) {
+		warned = warned || (e["level"] == "WARN" && e["msg"] == "reload failed, keeping previous inventory")
+	}
+	if !warned {
+		t.Fatalf("reload failure was not logged:\n%s", logs.String())
+	}
+}
+
+func TestLabelValue(t *testing.T) {
+	if got := labelValue("a\"b\\c\nd"); got != `a\"b\\c\nd` {
+		t.Fatalf("labelValue = %q", got)
+	}
+}
+
+func TestRunMonitor_StopsOnCancel(t *testing.T) {
+	stubProbe(t, func(ctx context.Context, ip string, timeout time.Duration) bool {
+		return true
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	opts := monitorOptions{
+		File:     writeInventory(t, monitorFixture),
+		Interval: time.Hour,
+		Timeout:  time.Second,
+		Workers:  1,
+		Listen:   "127.0.0.1:0",
+	}
+	if err := runMonitor(ctx, opts); err != nil {
+		t.Fatalf("runMonitor returned error: %v", err)
+	}
+
+	opts.Interval = 0
+	if err := runMonitor(context.Background(), opts); err == nil {
+		t.Fatalf("runMonitor should reject a zero interval")
+	}
+}
+
+func TestNewMonitor_RejectsStdin(t *testing.T) {
+	if _, err := newMonitor(monitorOptions{File: "-"}); err == nil {
+		t.Fatalf("newMonitor should reject --file -")
+	}
+}
@@ -0,0 +1,210 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/goccy/go-yaml/ast"
+)
+
+// Nameserver roles.
+const (
+	rolePrimary   = "primary"
+	roleSecondary = "secondary"
+)
+
+// nameserver is a single entry from one of the top-level nameservers* sections.
+type nameserver struct {
+	Name string
+	IP   string
+	// Role is primary or secondary, from the role key or else from the section: entries
+	// of a section whose name contains "secondary" are secondaries.
+	Role string
+	// Zones lists the zones the server is authoritative for; empty means every zone.
+	Zones []string
+	// TSIGKey names the TSIG key used to talk to the server, if any.
+	TSIGKey  string
+	Section  string
+	Disabled bool
+	// Keep marks an entry that must stay enabled even when it does not answer.
+	Keep bool
+	Node *ast.MappingNode
+}
+
+// nameserverRole returns the role of an entry of section.
+func nameserverRole(m *ast.MappingNode, section string) string {
+	if role := strings.ToLower(stringValue(m, "role")); role != "" {
+		return role
+	}
+	if strings.Contains(section, roleSecondary) {
+		return roleSecondary
+	}
+	return rolePrimary
+}
+
+// stringList returns the strings of a sequence value such as "zones: [a., b.]", or
+// a single scalar value as a one-element list.
+func stringList(m *ast.MappingNode, key string) []string {
+	seq, ok := mappingValue(m, key).(*ast.SequenceNode)
+	if !ok {
+		if v := stringValue(m, key); v != "" {
+			return []string{v}
+		}
+		return nil
+	}
+
+	var out []string
+	for _, v := range seq.Values {
+		if s, ok := v.(ast.ScalarNode); ok {
+			out = append(out, s.GetToken().Value)
+		}
+	}
+	return out
+}
+
+// collectNameservers returns every nameserver with an ip_address, de-duplicated by IP.
+func collectNameservers(root *ast.MappingNode) []nameserver {
+	var out []nameserver
+	seen := map[string]bool{}
+
+	for _, mv := range root.Values {
+		k, ok := mv.Key.(*ast.StringNode)
+		if !ok || !strings.HasPrefix(k.Value, "nameservers") {
+			continue
+		}
+		key := k.Value
+
+		seq, ok := mv.Value.(*ast.SequenceNode)
+		if !ok {
+			continue
+		}
+
+		for i, item := range seq.Values {
+			m, ok := item.(*ast.MappingNode)
+			if !ok {
+				continue
+			}
+
+			ip := stringValue(m, "ip_address")
+			if ip == "" || seen[ip] {
+				continue
+			}
+			seen[ip] = true
+
+			ns := nameserver{
+				Name:     stringValue(m, "name"),
+				IP:       ip,
+				Role:     nameserverRole(m, key),
+				TSIGKey:  stringValue(m, "tsig_key"),
+				Section:  key,
+				Disabled: isDisabled(m),
+				Keep:     isKeptItem(seq, i),
+				Node:     m,
+			}
+			for _, z := range stringList(m, "zones") {
+				ns.Zones = append(ns.Zones, canonicalZone(z))
+			}
+			out = append(out, ns)
+		}
+	}
+
+	return out
+}
+
+// label returns a human-readable identifier for the nameserver.
+func (ns nameserver) label() string {
+	if ns.Name == "" {
+		return ns.IP
+	}
+	return ns.Name + " (" + ns.IP + ")"
+}
+
+// serves reports whether the nameserver is authoritative for zone.
+func (ns nameserver) serves(zone string) bool {
+	return len(ns.Zones) == 0 || slices.Contains(ns.Zones, zone)
+}
+
+// tsigKeysSection is the top-level YAML key declaring the TSIG keys nameservers refer to.
+const tsigKeysSection = "tsig_keys"
+
+// collectTSIGKeyNames returns the names declared in tsig_keys, or nil if the section is missing.
+func collectTSIGKeyNames(root *ast.MappingNode) map[string]bool {
+	seq, ok := mappingValue(root, tsigKeysSection).(*ast.SequenceNode)
+	if !ok {
+		return nil
+	}
+
+	names := map[string]bool{}
+	for _, item := range seq.Values {
+		if m, ok := item.(*ast.MappingNode); ok {
+			names[canonicalZone(stringValue(m, "name"))] = true
+		}
+	}
+	return names
+}
+
+// nameserverIssue is a problem found by checkNameservers.
+type nameserverIssue struct {
+	// Fatal issues fail the check; the others are warnings.
+	Fatal   bool
+	Message string
+}
+
+// checkNameservers validates the nameserver model against the zones of the inventory:
+// roles and TSIG key references must be valid, and every zone served by one of the
+// nameservers needs a live primary. live reports whether a nameserver is up. Zones no
+// nameserver is configured for, and zones whose nameservers are all disabled, are only
+// warned about. Inventories without nameservers are not checked.
+func checkNameservers(nss []nameserver, zones []string, tsigKeys map[string]bool, live func(nameserver) bool) []nameserverIssue {
+	if len(nss) == 0 {
+		return nil
+	}
+
+	var issues []nameserverIssue
+	for _, ns := range nss {
+		if ns.Role != rolePrimary && ns.Role != roleSecondary {
+			issues = append(issues, nameserverIssue{true, fmt.Sprintf("nameserver %s: unknown role %q (want %s or %s)", ns.label(), ns.Role, rolePrimary, roleSecondary)})
+		}
+		if ns.TSIGKey != "" && tsigKeys != nil && !tsigKeys[canonicalZone(ns.TSIGKey)] {
+			issues = append(issues, nameserverIssue{true, fmt.Sprintf("nameserver %s: TSIG key %s is not declared in %s", ns.label(), ns.TSIGKey, tsigKeysSection)})
+		}
+	}
+
+	for _, zone := range zones {
+		var servers, disabled, primaries, livePrimaries int
+		for _, ns := range nss {
+			if !ns.serves(zone) {
+				continue
+			}
+			servers++
+			if ns.Disabled {
+				disabled++
+			}
+			if ns.Role == rolePrimary {
+				primaries++
+				if !ns.Disabled && live(ns) {
+					livePrimaries++
+				}
+			}
+		}
+
+		switch {
+		case servers == 0:
+			// e.g. a reverse zone clean-zones created itself; someone else may serve it
+			issues = append(issues, nameserverIssue{false, fmt.Sprintf("zone %s: no nameserver configured", zone)})
+			continue
+		case disabled == servers:
+			issues = append(issues, nameserverIssue{false, fmt.Sprintf("zone %s: all %d nameserver(s) are disabled", zone, servers)})
+		}
+
+		switch {
+		case primaries == 0:
+			issues = append(issues, nameserverIssue{true, fmt.Sprintf("zone %s: no primary nameserver configured", zone)})
+		case livePrimaries == 0:
+			issues = append(issues, nameserverIssue{true, fmt.Sprintf("zone %s: no live primary nameserver", zone)})
+		}
+	}
+
+	return issues
+}
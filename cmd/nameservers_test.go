@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCollectNameservers_AllSectionsDeduplicated(t *testing.T) {
+	path := writeInventory(t, `nameservers:
+  - name: ns1
+    ip_address: 10.0.0.53
+  - name: ns1-dup
+    ip_address: 10.0.0.53
+nameservers_secondary:
+  - name: ns2
+    ip_address: 10.0.0.54 # DISABLED: unreachable
+  - name: no-ip
+dns_records: []
+`)
+
+	_, root, err := loadInventory(path)
+	if err != nil {
+		t.Fatalf("loadInventory returned error: %v", err)
+	}
+
+	got := collectNameservers(root)
+	if len(got) != 2 {
+		t.Fatalf("collectNameservers returned %d entries, want 2: %+v", len(got), got)
+	}
+	if got[0].Name != "ns1" || got[0].Section != "nameservers" || got[0].Disabled {
+		t.Fatalf("unexpected first nameserver: %+v", got[0])
+	}
+	if got[1].Name != "ns2" || got[1].Section != "nameservers_secondary" || !got[1].Disabled {
+		t.Fatalf("unexpected second nameserver: %+v", got[1])
+	}
+}
+
+func TestNameserverLabel(t *testing.T) {
+	if got := (nameserver{IP: "10.0.0.53"}).label(); got != "10.0.0.53" {
+		t.Fatalf("label() = %q, want 10.0.0.53", got)
+	}
+	if got := (nameserver{Name: "ns1", IP: "10.0.0.53"}).label(); got != "ns1 (10.0.0.53)" {
+		t.Fatalf("label() = %q, want ns1 (10.0.0.53)", got)
+	}
+}
+
+func TestCollectNameservers_Model(t *testing.T) {
+	path := writeInventory(t, `nameservers:
+  - name: ns1
+    ip_address: 10.0.0.53
+    zones: [example.lan, 0.10.in-addr.arpa.]
+    tsig_key: dnsctl
+nameservers_secondary:
+  - name: ns2
+    ip_address: 10.0.0.54
+    zones: example.lan.
+  - name: ns3
+    ip_address: 10.0.0.55
+    role: Primary # dnsctl:keep
+`)
+
+	_, root, err := loadInventory(path)
+	if err != nil {
+		t.Fatalf("loadInventory returned error: %v", err)
+	}
+
+	got := collectNameservers(root)
+	if len(got) != 3 {
+		t.Fatalf("collectNameservers returned %d entries, want 3: %+v", len(got), got)
+	}
+
+	ns1, ns2, ns3 := got[0], got[1], got[2]
+	if ns1.Role != rolePrimary || ns1.TSIGKey != "dnsctl" || !slices.Equal(ns1.Zones, []string{"example.lan.", "0.10.in-addr.arpa."}) {
+		t.Errorf("unexpected ns1: %+v", ns1)
+	}
+	if ns2.Role != roleSecondary || !slices.Equal(ns2.Zones, []string{"example.lan."}) || ns2.Keep {
+		t.Errorf("unexpected ns2: %+v", ns2)
+	}
+	if ns3.Role != rolePrimary || ns3.Zones != nil || !ns3.Keep {
+		t.Errorf("unexpected ns3: %+v", ns3)
+	}
+	if !ns3.serves("other.lan.") || ns2.serves("other.lan.") {
+		t.Errorf("serves: ns3 must serve every zone, ns2 only example.lan.")
+	}
+}
+
+func TestCheckNameservers(t *testing.T) {
+	zones := []string{"example.lan.", "other.lan."}
+	alive := func(nameserver) bool { return true }
+
+	tests := []struct {
+		name     string
+		nss      []nameserver
+		tsigKeys map[string]bool
+		live     func(nameserver) bool
+		want     []nameserverIssue
+	}{
+		{
+			name: "no nameservers",
+			live: alive,
+		},
+		{
+			name: "primary for every zone",
+			nss: []nameserver{
+				{Name: "ns1", IP: "10.0.0.53", Role: rolePrimary},
+				{Name: "ns2", IP: "10.0.0.54", Role: roleSecondary, Zones: []string{"other.lan."}},
+			},
+			live: alive,
+		},
+		{
+			name: "zone without a primary",
+			nss: []nameserver{
+				{Name: "ns1", IP: "10.0.0.53", Role: rolePrimary, Zones: []string{"example.lan."}},
+				{Name: "ns2", IP: "10.0.0.54", Role: roleSecondary},
+			},
+			live: alive,
+			want: []nameserverIssue{{true, "zone other.lan.: no primary nameserver configured"}},
+		},
+		{
+			name: "zone without nameservers",
+			nss: []nameserver{
+				{Name: "ns1", IP: "10.0.0.53", Role: rolePrimary, Zones: []string{"example.lan."}},
+			},
+			live: alive,
+			want: []nameserverIssue{{false, "zone other.lan.: no nameserver configured"}},
+		},
+		{
+			name: "primary down",
+			nss: []nameserver{
+				{Name: "ns1", IP: "10.0.0.53", Role: rolePrimary},
+				{Name: "ns2", IP: "10.0.0.54", Role: roleSecondary},
+			},
+			live: func(ns nameserver) bool { return ns.Role != rolePrimary },
+			want: []nameserverIssue{
+				{true, "zone example.lan.: no live primary nameserver"},
+				{true, "zone other.lan.: no live primary nameserver"},
+			},
+		},
+		{
+			name: "all disabled",
+			nss: []nameserver{
+				{Name: "ns1", IP: "10.0.0.53", Role: rolePrimary, Zones: []string{"example.lan."}},
+				{Name: "ns2", IP: "10.0.0.54", Role: rolePrimary, Zones: []string{"example.lan."}, Disabled: true},
+				{Name: "ns3", IP: "10.0.0.55", Role: roleSecondary, Zones: []string{"other.lan."}, Disabled: true},
+			},
+			live: alive,
+			want: []nameserverIssue{
+				{false, "zone other.lan.: all 1 nameserver(s) are disabled"},
+				{true, "zone other.lan.: no primary nameserver configured"},
+			},
+		},
+		{
+			name: "bad role and tsig key",
+			nss: []nameserver{
+				{Name: "ns1", IP: "10.0.0.53", Role: rolePrimary, TSIGKey: "dnsctl."},
+				{Name: "ns2", IP: "10.0.0.54", Role: "hidden", TSIGKey: "missing"},
+			},
+			tsigKeys: map[string]bool{"dnsctl.": true},
+			live:     alive,
+			want: []nameserverIssue{
+				{true, `nameserver ns2 (10.0.0.54): unknown role "hidden" (want primary or secondary)`},
+				{true, "nameserver ns2 (10.0.0.54): TSIG key missing is not declared in tsig_keys"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := checkNameservers(tt.nss, zones, tt.tsigKeys, tt.live)
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("checkNameservers =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
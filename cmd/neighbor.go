@@ -0,0 +1,313 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Liveness checks selectable with --check. checkICMP is declared with the probe cache.
+const (
+	// checkARP decides on-link addresses from the neighbor table and pings the rest.
+	// On-link addresses without an entry are resolved with an ARP request (IPv4) or a
+	// ping (IPv6), since nothing else would fill the table for them.
+	checkARP = "arp"
+	// checkAuto pings every address and consults the neighbor table for on-link
+	// addresses that did not answer.
+	checkAuto = "auto"
+)
+
+// livenessChecks lists the supported --check values.
+var livenessChecks = []string{checkICMP, checkARP, checkAuto}
+
+// probeFunc reports whether the host at ip is alive, giving up after timeout or when ctx is done.
+type probeFunc func(ctx context.Context, ip string, timeout time.Duration) bool
+
+// livenessOptions selects the liveness check used by runPingWorkers.
+type livenessOptions struct {
+	// Check is icmp, arp or auto; empty means icmp.
+	Check string
+	// ARPRequest sends an ARP request for on-link IPv4 addresses the neighbor table
+	// cannot vouch for, instead of trusting entries that were not confirmed recently.
+	ARPRequest bool
+}
+
+// check returns the check name, which also keys the probe cache.
+func (o livenessOptions) check() string {
+	if o.Check == "" {
+		return checkICMP
+	}
+	return o.Check
+}
+
+// neighborState is how much the neighbor table knows about an address.
+type neighborState int
+
+const (
+	// neighborNone means there is no entry, or resolving the address failed.
+	neighborNone neighborState = iota
+	// neighborStale is an entry with a link-layer address that was not confirmed recently.
+	neighborStale
+	// neighborReachable is an entry confirmed recently, or a static one.
+	neighborReachable
+)
+
+// neighbor is one entry of the kernel's neighbor (ARP and NDP) table.
+type neighbor struct {
+	IP     net.IP
+	MAC    net.HardwareAddr
+	Device string
+	State  neighborState
+}
+
+// /proc/net/arp flags, from linux/if_arp.h.
+const (
+	atfComplete  = 0x02
+	atfPermanent = 0x04
+)
+
+// procARPPath is the IPv4 neighbor table exposed by procfs.
+const procARPPath = "/proc/net/arp"
+
+// parseProcARP parses the format of /proc/net/arp. It cannot tell fresh entries from
+// stale ones, so only permanent entries are reported as reachable.
+func parseProcARP(r io.Reader) ([]neighbor, error) {
+	var table []neighbor
+
+	sc := bufio.NewScanner(r)
+	// skip the header line
+	sc.Scan()
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 6 {
+			continue
+		}
+
+		ip := net.ParseIP(fields[0])
+		flags, err := strconv.ParseUint(strings.TrimPrefix(fields[2], "0x"), 16, 32)
+		if ip == nil || err != nil {
+			return nil, fmt.Errorf("malformed %s line %q", procARPPath, sc.Text())
+		}
+		mac, _ := net.ParseMAC(fields[3])
+
+		n := neighbor{IP: ip, MAC: mac, Device: fields[5]}
+		switch {
+		case flags&atfComplete == 0 || isZeroMAC(mac):
+			n.State = neighborNone
+		case flags&atfPermanent != 0:
+			n.State = neighborReachable
+		default:
+			n.State = neighborStale
+		}
+		table = append(table, n)
+	}
+
+	return table, sc.Err()
+}
+
+// isZeroMAC reports whether mac is missing or all zeros, as for unresolved entries.
+func isZeroMAC(mac net.HardwareAddr) bool {
+	for _, b := range mac {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// readProcARP reads the IPv4 neighbor table from procfs.
+func readProcARP() ([]neighbor, error) {
+	f, err := os.Open(procARPPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return parseProcARP(f)
+}
+
+// readNeighbors returns the kernel's neighbor table, from netlink where available and
+// from /proc/net/arp (IPv4 only) otherwise.
+func readNeighbors() ([]neighbor, error) {
+	table, err := netlinkNeighbors()
+	if err == nil {
+		return table, nil
+	}
+	logger.Debug("netlink neighbor dump failed, falling back to procfs", "error", err)
+
+	return readProcARP()
+}
+
+// neighbors is the neighbor table source; tests replace it with a stub.
+var neighbors = readNeighbors
+
+// lookupNeighbor returns the best state any entry for ip has.
+func lookupNeighbor(table []neighbor, ip net.IP) neighborState {
+	state := neighborNone
+	for _, n := range table {
+		if n.IP.Equal(ip) && n.State > state {
+			state = n.State
+		}
+	}
+	return state
+}
+
+// localNetwork is a network directly attached to one of the host's interfaces.
+type localNetwork struct {
+	Device string
+	Addr   net.IP
+	Net    *net.IPNet
+}
+
+// readLocalNetworks returns the networks of the interfaces that are up, except loopback.
+func readLocalNetworks() ([]localNetwork, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	var nets []localNetwork
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			return nil, fmt.Errorf("interface %s: %w", iface.Name, err)
+		}
+		for _, a := range addrs {
+			if ipn, ok := a.(*net.IPNet); ok {
+				nets = append(nets, localNetwork{Device: iface.Name, Addr: ipn.IP, Net: ipn})
+			}
+		}
+	}
+	return nets, nil
+}
+
+// localNetworks is the source of directly attached networks; tests replace it with a stub.
+var localNetworks = readLocalNetworks
+
+// onLink returns the interface through which ip is reachable without a router. The
+// host's own addresses are not on-link: they never appear in the neighbor table.
+func onLink(nets []localNetwork, ip net.IP) (string, bool) {
+	if ip == nil {
+		return "", false
+	}
+	for _, n := range nets {
+		if n.Addr.Equal(ip) {
+			return "", false
+		}
+	}
+	for _, n := range nets {
+		if n.Net.Contains(ip) {
+			return n.Device, true
+		}
+	}
+	return "", false
+}
+
+// arpingContext sends a single ARP request for ip out of device and returns true if it was answered.
+func arpingContext(ctx context.Context, ip, device string, timeout time.Duration) bool {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	secs := max(1, int(timeout.Round(time.Second)/time.Second))
+	cmd := exec.CommandContext(ctx, "arping", "-q", "-c", "1", "-w", strconv.Itoa(secs), "-I", device, ip)
+	return cmd.Run() == nil
+}
+
+// arping is the ARP request sender; tests replace it with a stub.
+var arping = arpingContext
+
+// lookPath finds the arping binary; tests replace it with a stub.
+var lookPath = exec.LookPath
+
+// neighborCheck is the arp and auto liveness check.
+type neighborCheck struct {
+	nets    []localNetwork
+	auto    bool
+	request bool
+}
+
+// newLivenessCheck returns the probe for opts. The neighbor-based checks snapshot the
+// local networks once and fail early when the neighbor table cannot be read, or when
+// they may send ARP requests and arping is not installed.
+func newLivenessCheck(opts livenessOptions) (probeFunc, error) {
+	switch opts.check() {
+	case checkICMP:
+		if opts.ARPRequest {
+			return nil, fmt.Errorf("--arp-request requires --check arp or auto")
+		}
+		return probe, nil
+	case checkARP, checkAuto:
+	default:
+		return nil, fmt.Errorf("unknown check %q (want one of %s)", opts.Check, strings.Join(livenessChecks, ", "))
+	}
+
+	nets, err := localNetworks()
+	if err != nil {
+		return nil, fmt.Errorf("listing local networks: %w", err)
+	}
+	if _, err := neighbors(); err != nil {
+		return nil, fmt.Errorf("reading the neighbor table: %w", err)
+	}
+	// arp mode resolves missing entries with arping; auto only does with --arp-request
+	if opts.Check == checkARP || opts.ARPRequest {
+		if _, err := lookPath("arping"); err != nil {
+			return nil, fmt.Errorf("sending ARP requests needs arping: %w", err)
+		}
+	}
+
+	c := &neighborCheck{nets: nets, auto: opts.Check == checkAuto, request: opts.ARPRequest}
+	return c.probe, nil
+}
+
+// probe pings addresses that are not on-link. On-link addresses are decided from the
+// neighbor table, after a ping in auto mode. In arp mode an address missing from the
+// table has never been resolved, so it is resolved now rather than reported down.
+// Stale entries count as up unless c.request asks for them to be confirmed: the host
+// answered within the kernel's garbage collection window, and re-probing every one of
+// them would make the arp check as slow as pinging.
+func (c *neighborCheck) probe(ctx context.Context, ip string, timeout time.Duration) bool {
+	addr := net.ParseIP(ip)
+	device, ok := onLink(c.nets, addr)
+	if !ok {
+		return probe(ctx, ip, timeout)
+	}
+	if c.auto && probe(ctx, ip, timeout) {
+		return true
+	}
+	if ctx.Err() != nil {
+		return false
+	}
+
+	table, err := neighbors()
+	if err != nil {
+		logger.Debug("could not read the neighbor table", "ip", ip, "error", err)
+		return false
+	}
+
+	state := lookupNeighbor(table, addr)
+	if state == neighborReachable {
+		return true
+	}
+	if state == neighborNone && !c.auto {
+		if addr.To4() == nil {
+			return probe(ctx, ip, timeout)
+		}
+		return arping(ctx, ip, device, timeout)
+	}
+	// ARP only exists for IPv4; IPv6 entries are taken as they are
+	if !c.request || addr.To4() == nil {
+		return state == neighborStale
+	}
+
+	return arping(ctx, ip, device, timeout)
+}
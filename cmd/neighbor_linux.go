@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// netlinkNeighbors dumps the IPv4 and IPv6 neighbor tables over rtnetlink.
+func netlinkNeighbors() ([]neighbor, error) {
+	rib, err := syscall.NetlinkRIB(unix.RTM_GETNEIGH, unix.AF_UNSPEC)
+	if err != nil {
+		return nil, fmt.Errorf("netlink: %w", err)
+	}
+	msgs, err := syscall.ParseNetlinkMessage(rib)
+	if err != nil {
+		return nil, fmt.Errorf("netlink: %w", err)
+	}
+
+	var table []neighbor
+	for _, m := range msgs {
+		if m.Header.Type != unix.RTM_NEWNEIGH {
+			continue
+		}
+		n, ok := parseNeighborMessage(m.Data)
+		if !ok {
+			continue
+		}
+		table = append(table, n)
+	}
+	return table, nil
+}
+
+// parseNeighborMessage decodes the body of an RTM_NEWNEIGH message: an ndmsg header
+// followed by route attributes. It reports false for entries without a destination.
+func parseNeighborMessage(b []byte) (neighbor, bool) {
+	if len(b) < unix.SizeofNdMsg {
+		return neighbor{}, false
+	}
+
+	var n neighbor
+	ifindex := int32(binary.NativeEndian.Uint32(b[4:8]))
+	state := binary.NativeEndian.Uint16(b[8:10])
+
+	for attrs := b[unix.SizeofNdMsg:]; len(attrs) >= unix.SizeofRtAttr; {
+		l := int(binary.NativeEndian.Uint16(attrs[0:2]))
+		if l < unix.SizeofRtAttr || l > len(attrs) {
+			break
+		}
+		value := attrs[unix.SizeofRtAttr:l]
+		switch binary.NativeEndian.Uint16(attrs[2:4]) {
+		case unix.NDA_DST:
+			n.IP = net.IP(append([]byte(nil), value...))
+		case unix.NDA_LLADDR:
+			n.MAC = net.HardwareAddr(append([]byte(nil), value...))
+		}
+		// attributes are padded to 4 bytes
+		next := (l + unix.RTA_ALIGNTO - 1) &^ (unix.RTA_ALIGNTO - 1)
+		if next > len(attrs) {
+			break
+		}
+		attrs = attrs[next:]
+	}
+	if n.IP == nil {
+		return neighbor{}, false
+	}
+
+	if iface, err := net.InterfaceByIndex(int(ifindex)); err == nil {
+		n.Device = iface.Name
+	}
+
+	switch {
+	case state&(unix.NUD_REACHABLE|unix.NUD_PERMANENT|unix.NUD_NOARP) != 0:
+		n.State = neighborReachable
+	case state&(unix.NUD_STALE|unix.NUD_DELAY|unix.NUD_PROBE) != 0 && !isZeroMAC(n.MAC):
+		n.State = neighborStale
+	default:
+		n.State = neighborNone
+	}
+	return n, true
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// neighborMessage builds an RTM_NEWNEIGH body with the given state and attributes.
+func neighborMessage(state uint16, attrs map[uint16][]byte) []byte {
+	b := make([]byte, unix.SizeofNdMsg)
+	b[0] = unix.AF_INET
+	binary.NativeEndian.PutUint16(b[8:10], state)
+
+	for _, typ := range []uint16{unix.NDA_DST, unix.NDA_LLADDR} {
+		value, ok := attrs[typ]
+		if !ok {
+			continue
+		}
+		attr := make([]byte, unix.SizeofRtAttr, unix.SizeofRtAttr+len(value)+3)
+		binary.NativeEndian.PutUint16(attr[0:2], uint16(unix.SizeofRtAttr+len(value)))
+		binary.NativeEndian.PutUint16(attr[2:4], typ)
+		attr = append(attr, value...)
+		for len(attr)%unix.RTA_ALIGNTO != 0 {
+			attr = append(attr, 0)
+		}
+		b = append(b, attr...)
+	}
+	return b
+}
+
+func TestParseNeighborMessage(t *testing.T) {
+	ip := net.ParseIP("10.0.1.5").To4()
+	mac, _ := net.ParseMAC("52:54:00:12:34:56")
+
+	tests := []struct {
+		name  string
+		state uint16
+		attrs map[uint16][]byte
+		want  neighborState
+		ok    bool
+	}{
+		{"reachable", unix.NUD_REACHABLE, map[uint16][]byte{unix.NDA_DST: ip, unix.NDA_LLADDR: mac}, neighborReachable, true},
+		{"permanent", unix.NUD_PERMANENT, map[uint16][]byte{unix.NDA_DST: ip, unix.NDA_LLADDR: mac}, neighborReachable, true},
+		{"stale", unix.NUD_STALE, map[uint16][]byte{unix.NDA_DST: ip, unix.NDA_LLADDR: mac}, neighborStale, true},
+		{"failed", unix.NUD_FAILED, map[uint16][]byte{unix.NDA_DST: ip}, neighborNone, true},
+		{"incomplete", unix.NUD_INCOMPLETE, map[uint16][]byte{unix.NDA_DST: ip}, neighborNone, true},
+		{"no destination", unix.NUD_REACHABLE, map[uint16][]byte{unix.NDA_LLADDR: mac}, neighborNone, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, ok := parseNeighborMessage(neighborMessage(tt.state, tt.attrs))
+			if ok != tt.ok {
+				t.Fatalf("parseNeighborMessage ok = %v, want %v", ok, tt.ok)
+			}
+			if !ok {
+				return
+			}
+			if n.State != tt.want || !n.IP.Equal(ip) {
+				t.Fatalf("parseNeighborMessage = %+v, want %s in state %d", n, ip, tt.want)
+			}
+		})
+	}
+
+	if _, ok := parseNeighborMessage([]byte{1, 2, 3}); ok {
+		t.Errorf("parseNeighborMessage accepted a truncated message")
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package cmd
+
+import "errors"
+
+// netlinkNeighbors is only implemented on Linux.
+func netlinkNeighbors() ([]neighbor, error) {
+	return nil, errors.New("netlink is only available on Linux")
+}
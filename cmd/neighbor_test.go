@@ -0,0 +1,270 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+const procARPFixture = `IP address       HW type     Flags       HW address            Mask     Device
+10.0.1.5         0x1         0x2         52:54:00:12:34:56     *        eth0
+10.0.1.6         0x1         0x0         00:00:00:00:00:00     *        eth0
+10.0.1.1         0x1         0x6         52:54:00:aa:bb:cc     *        eth0
+`
+
+func TestParseProcARP(t *testing.T) {
+	table, err := parseProcARP(strings.NewReader(procARPFixture))
+	if err != nil {
+		t.Fatalf("parseProcARP returned error: %v", err)
+	}
+	if len(table) != 3 {
+		t.Fatalf("parseProcARP returned %d entries, want 3", len(table))
+	}
+
+	want := []neighborState{neighborStale, neighborNone, neighborReachable}
+	for i, n := range table {
+		if n.State != want[i] || n.Device != "eth0" {
+			t.Errorf("entry %d = %+v, want state %d on eth0", i, n, want[i])
+		}
+	}
+	if table[0].MAC.String() != "52:54:00:12:34:56" {
+		t.Errorf("entry 0 MAC = %s", table[0].MAC)
+	}
+
+	if _, err := parseProcARP(strings.NewReader("header\nnot-an-ip 0x1 0x2 00:00:00:00:00:01 * eth0\n")); err == nil {
+		t.Errorf("parseProcARP accepted a malformed line")
+	}
+}
+
+func TestLookupNeighbor(t *testing.T) {
+	table := []neighbor{
+		{IP: net.ParseIP("10.0.1.5"), State: neighborNone, Device: "eth0"},
+		{IP: net.ParseIP("10.0.1.5"), State: neighborStale, Device: "eth1"},
+		{IP: net.ParseIP("fd00::5"), State: neighborReachable},
+	}
+
+	tests := []struct {
+		ip   string
+		want neighborState
+	}{
+		{"10.0.1.5", neighborStale},
+		{"fd00:0::5", neighborReachable},
+		{"10.0.1.9", neighborNone},
+	}
+	for _, tt := range tests {
+		if got := lookupNeighbor(table, net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("lookupNeighbor(%s) = %d, want %d", tt.ip, got, tt.want)
+		}
+	}
+}
+
+// testNetworks is a host with 10.0.1.2/24 on eth0 and fd00::2/64 on eth1.
+func testNetworks() []localNetwork {
+	_, v4, _ := net.ParseCIDR("10.0.1.0/24")
+	_, v6, _ := net.ParseCIDR("fd00::/64")
+	return []localNetwork{
+		{Device: "eth0", Addr: net.ParseIP("10.0.1.2"), Net: v4},
+		{Device: "eth1", Addr: net.ParseIP("fd00::2"), Net: v6},
+	}
+}
+
+func TestOnLink(t *testing.T) {
+	tests := []struct {
+		ip     string
+		device string
+		ok     bool
+	}{
+		{"10.0.1.5", "eth0", true},
+		{"fd00::5", "eth1", true},
+		{"10.0.1.2", "", false},
+		{"10.0.2.5", "", false},
+		{"not-an-ip", "", false},
+	}
+	for _, tt := range tests {
+		device, ok := onLink(testNetworks(), net.ParseIP(tt.ip))
+		if device != tt.device || ok != tt.ok {
+			t.Errorf("onLink(%s) = %q, %v, want %q, %v", tt.ip, device, ok, tt.device, tt.ok)
+		}
+	}
+}
+
+// stubNeighbors replaces the local networks with testNetworks and the neighbor table with table.
+func stubNeighbors(t *testing.T, table []neighbor) {
+	t.Helper()
+
+	origNets, origTable := localNetworks, neighbors
+	localNetworks = func() ([]localNetwork, error) { return testNetworks(), nil }
+	neighbors = func() ([]neighbor, error) { return table, nil }
+	t.Cleanup(func() { localNetworks, neighbors = origNets, origTable })
+}
+
+// stubArping replaces the ARP request sender, recording the addresses it was asked about.
+func stubArping(t *testing.T, answer bool) *[]string {
+	t.Helper()
+
+	var asked []string
+	orig, origLookPath := arping, lookPath
+	arping = func(ctx context.Context, ip, device string, timeout time.Duration) bool {
+		asked = append(asked, ip+"@"+device)
+		return answer
+	}
+	lookPath = func(file string) (string, error) { return "/usr/sbin/" + file, nil }
+	t.Cleanup(func() { arping, lookPath = orig, origLookPath })
+	return &asked
+}
+
+func TestNeighborCheck(t *testing.T) {
+	stubNeighbors(t, []neighbor{
+		{IP: net.ParseIP("10.0.1.5"), State: neighborReachable},
+		{IP: net.ParseIP("10.0.1.6"), State: neighborStale},
+		{IP: net.ParseIP("10.0.1.7"), State: neighborNone},
+		{IP: net.ParseIP("fd00::6"), State: neighborStale},
+	})
+
+	var pinged []string
+	stubProbe(t, func(ctx context.Context, ip string, timeout time.Duration) bool {
+		pinged = append(pinged, ip)
+		return ip == "10.0.2.5" || ip == "10.0.1.9"
+	})
+
+	tests := []struct {
+		name    string
+		opts    livenessOptions
+		answer  bool
+		up      []string
+		pinged  []string
+		arpings []string
+	}{
+		{
+			// the stale 10.0.1.6 and fd00::6 count as up without --arp-request
+			name:    "arp",
+			opts:    livenessOptions{Check: checkARP},
+			up:      []string{"10.0.1.5", "10.0.1.6", "fd00::6", "10.0.2.5"},
+			pinged:  []string{"10.0.2.5", "10.0.2.6"},
+			arpings: []string{"10.0.1.7@eth0", "10.0.1.9@eth0"},
+		},
+		{
+			name:    "arp with requests",
+			opts:    livenessOptions{Check: checkARP, ARPRequest: true},
+			answer:  false,
+			up:      []string{"10.0.1.5", "fd00::6", "10.0.2.5"},
+			pinged:  []string{"10.0.2.5", "10.0.2.6"},
+			arpings: []string{"10.0.1.6@eth0", "10.0.1.7@eth0", "10.0.1.9@eth0"},
+		},
+		{
+			name:   "auto",
+			opts:   livenessOptions{Check: checkAuto},
+			up:     []string{"10.0.1.5", "10.0.1.6", "10.0.1.9", "fd00::6", "10.0.2.5"},
+			pinged: []string{"10.0.1.5", "10.0.1.6", "10.0.1.7", "10.0.1.9", "fd00::6", "10.0.2.5", "10.0.2.6"},
+		},
+	}
+
+	hosts := []string{"10.0.1.5", "10.0.1.6", "10.0.1.7", "10.0.1.9", "fd00::6", "10.0.2.5", "10.0.2.6"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pinged = nil
+			asked := stubArping(t, tt.answer)
+
+			check, err := newLivenessCheck(tt.opts)
+			if err != nil {
+				t.Fatalf("newLivenessCheck returned error: %v", err)
+			}
+
+			var up []string
+			for _, ip := range hosts {
+				if check(context.Background(), ip, time.Second) {
+					up = append(up, ip)
+				}
+			}
+
+			if strings.Join(up, ",") != strings.Join(tt.up, ",") {
+				t.Errorf("up = %v, want %v", up, tt.up)
+			}
+			if strings.Join(pinged, ",") != strings.Join(tt.pinged, ",") {
+				t.Errorf("pinged = %v, want %v", pinged, tt.pinged)
+			}
+			if strings.Join(*asked, ",") != strings.Join(tt.arpings, ",") {
+				t.Errorf("arp requests = %v, want %v", *asked, tt.arpings)
+			}
+		})
+	}
+}
+
+// TestNeighborCheck_EmptyTable tests that arp mode resolves on-link hosts the neighbor
+// table knows nothing about instead of reporting them down.
+func TestNeighborCheck_EmptyTable(t *testing.T) {
+	stubNeighbors(t, nil)
+
+	var pinged []string
+	stubProbe(t, func(ctx context.Context, ip string, timeout time.Duration) bool {
+		pinged = append(pinged, ip)
+		return true
+	})
+	asked := stubArping(t, true)
+
+	check, err := newLivenessCheck(livenessOptions{Check: checkARP})
+	if err != nil {
+		t.Fatalf("newLivenessCheck returned error: %v", err)
+	}
+
+	for _, ip := range []string{"10.0.1.5", "fd00::5"} {
+		if !check(context.Background(), ip, time.Second) {
+			t.Errorf("%s reported down with an empty neighbor table", ip)
+		}
+	}
+	if strings.Join(*asked, ",") != "10.0.1.5@eth0" || strings.Join(pinged, ",") != "fd00::5" {
+		t.Errorf("arp requests = %v, pings = %v, want an ARP request for 10.0.1.5 and a ping to fd00::5", *asked, pinged)
+	}
+}
+
+func TestNewLivenessCheck_Errors(t *testing.T) {
+	stubNeighbors(t, nil)
+
+	for _, opts := range []livenessOptions{
+		{Check: "tcp"},
+		{ARPRequest: true},
+	} {
+		if _, err := newLivenessCheck(opts); err == nil {
+			t.Errorf("newLivenessCheck(%+v) returned nil, want error", opts)
+		}
+	}
+
+	stubArping(t, true)
+	lookPath = func(file string) (string, error) { return "", exec.ErrNotFound }
+	for _, opts := range []livenessOptions{
+		{Check: checkARP},
+		{Check: checkAuto, ARPRequest: true},
+	} {
+		if _, err := newLivenessCheck(opts); err == nil || !strings.Contains(err.Error(), "arping") {
+			t.Errorf("newLivenessCheck(%+v) without arping = %v, want error", opts, err)
+		}
+	}
+	if _, err := newLivenessCheck(livenessOptions{Check: checkAuto}); err != nil {
+		t.Errorf("newLivenessCheck(auto) without arping returned error: %v", err)
+	}
+
+	neighbors = func() ([]neighbor, error) { return nil, errors.New("permission denied") }
+	_, err := newLivenessCheck(livenessOptions{Check: checkARP})
+	if err == nil || !strings.Contains(err.Error(), "neighbor table") {
+		t.Errorf("newLivenessCheck with an unreadable neighbor table = %v, want error", err)
+	}
+}
+
+// TestRunPingWorkers_Check tests that runPingWorkers uses the given check instead of probe.
+func TestRunPingWorkers_Check(t *testing.T) {
+	stubProbe(t, func(ctx context.Context, ip string, timeout time.Duration) bool {
+		t.Errorf("probe called for %s", ip)
+		return false
+	})
+
+	check := func(ctx context.Context, ip string, timeout time.Duration) bool { return ip == "10.0.1.5" }
+	results := runPingWorkers(context.Background(), []pingJob{{IP: "10.0.1.5"}, {IP: "10.0.1.6"}}, time.Second, 2, probeLimits{}, check, nil)
+
+	if results[0].status != probeUp || results[1].status != probeDown {
+		t.Fatalf("statuses = %s, %s, want up, down", results[0].status, results[1].status)
+	}
+}
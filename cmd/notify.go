@@ -0,0 +1,235 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-yaml/ast"
+)
+
+// stateChange describes one inventory entry that was disabled or re-enabled.
+type stateChange struct {
+	Name string `json:"name"`
+	Type string `json:"type,omitempty"`
+	Zone string `json:"zone,omitempty"`
+	IP   string `json:"ip"`
+	// State is the new state, "disabled" or "enabled".
+	State  string `json:"state"`
+	Reason string `json:"reason"`
+}
+
+// notifyEvent is the payload sent to webhooks and hook commands.
+type notifyEvent struct {
+	Event   string        `json:"event"`
+	Source  string        `json:"source"`
+	File    string        `json:"file"`
+	Time    time.Time     `json:"time"`
+	Changes []stateChange `json:"changes"`
+}
+
+// notifyOptions lists the notification targets. Every target receives every event.
+type notifyOptions struct {
+	Webhooks []string
+	Slack    []string
+	Command  string
+	Timeout  time.Duration
+}
+
+// enabled reports whether any target is configured.
+func (o notifyOptions) enabled() bool {
+	return len(o.Webhooks) > 0 || len(o.Slack) > 0 || o.Command != ""
+}
+
+// notifier delivers an event to a single target.
+type notifier interface {
+	notify(ctx context.Context, ev notifyEvent) error
+}
+
+// newNotifiers builds a notifier for every target in opts.
+func newNotifiers(opts notifyOptions) []notifier {
+	client := &http.Client{Timeout: opts.Timeout}
+
+	var out []notifier
+	for _, u := range opts.Webhooks {
+		out = append(out, &webhookNotifier{URL: u, HTTP: client})
+	}
+	for _, u := range opts.Slack {
+		out = append(out, &webhookNotifier{URL: u, HTTP: client, Slack: true})
+	}
+	if opts.Command != "" {
+		out = append(out, &commandNotifier{Command: opts.Command})
+	}
+	return out
+}
+
+// sendNotifications delivers ev to every configured target. Delivery problems are logged
+// rather than returned, so that a broken webhook never fails a run that already produced
+// its output.
+func sendNotifications(ctx context.Context, opts notifyOptions, ev notifyEvent, log *slog.Logger) {
+	if !opts.enabled() || len(ev.Changes) == 0 {
+		return
+	}
+
+	for _, n := range newNotifiers(opts) {
+		nctx, cancel := ctx, context.CancelFunc(func() {})
+		if opts.Timeout > 0 {
+			// also bounds hook commands, which the HTTP client timeout does not cover
+			nctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		}
+		if err := n.notify(nctx, ev); err != nil {
+			log.Warn("notification failed", "error", err)
+		}
+		cancel()
+	}
+	log.Debug("notifications sent", "changes", len(ev.Changes))
+}
+
+// changeFromJob describes the inventory entry behind a probe job.
+func changeFromJob(j pingJob, state, reason string) stateChange {
+	c := stateChange{IP: j.IP, State: state, Reason: reason}
+
+	if m, ok := j.Node.(*ast.MappingNode); ok {
+		c.Name = stringValue(m, "host")
+		if c.Name == "" {
+			c.Name = stringValue(m, "name")
+		}
+		c.Type = stringValue(m, "type")
+		c.Zone = stringValue(m, "zone")
+	}
+	if c.Name == "" {
+		c.Name = j.IP
+	}
+	return c
+}
+
+// describe renders the entry on one line, e.g. "web01 A example.lan. 10.0.1.5".
+func (c stateChange) describe() string {
+	fields := []string{c.Name}
+	for _, f := range []string{c.Type, c.Zone} {
+		if f != "" {
+			fields = append(fields, f)
+		}
+	}
+	if c.IP != c.Name {
+		fields = append(fields, c.IP)
+	}
+	return strings.Join(fields, " ")
+}
+
+// summary renders ev as short human-readable lines.
+func (ev notifyEvent) summary() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "dnsctl %s: %d record(s) changed state in %s", ev.Source, len(ev.Changes), ev.File)
+	for _, c := range ev.Changes {
+		fmt.Fprintf(&b, "\n• %s", c.Name)
+		if c.Type != "" {
+			fmt.Fprintf(&b, " %s", c.Type)
+		}
+		fmt.Fprintf(&b, " (%s) %s: %s", c.IP, c.State, c.Reason)
+	}
+	return b.String()
+}
+
+// webhookNotifier posts events as JSON. Slack targets receive an incoming-webhook
+// message with a text summary; generic targets receive the event itself.
+type webhookNotifier struct {
+	URL   string
+	HTTP  *http.Client
+	Slack bool
+}
+
+func (n *webhookNotifier) notify(ctx context.Context, ev notifyEvent) error {
+	var body any = ev
+	if n.Slack {
+		body = map[string]string{"text": ev.summary()}
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.HTTP.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook %s: %w", redactURL(n.URL), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook %s: %s", redactURL(n.URL), resp.Status)
+	}
+	return nil
+}
+
+// redactURL strips the path and query of a webhook URL, which usually embed its secret.
+func redactURL(u string) string {
+	if i := strings.Index(u, "://"); i >= 0 {
+		if j := strings.IndexByte(u[i+3:], '/'); j >= 0 {
+			return u[:i+3+j] + "/…"
+		}
+	}
+	return u
+}
+
+// commandNotifier runs a shell command for each event. The event is passed as JSON on
+// stdin and summarized in DNSCTL_* environment variables.
+type commandNotifier struct {
+	Command string
+}
+
+func (n *commandNotifier) notify(ctx context.Context, ev notifyEvent) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", n.Command)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), hookEnv(ev)...)
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("hook command %q: %w", n.Command, err)
+	}
+	return nil
+}
+
+// hookEnv returns the environment variables describing ev. The event's inventory is in
+// DNSCTL_EVENT_FILE rather than DNSCTL_FILE, which overrides --file (see envName).
+func hookEnv(ev notifyEvent) []string {
+	var disabled, enabled []string
+	for _, c := range ev.Changes {
+		entry := c.Name + "=" + c.IP
+		if c.State == "disabled" {
+			disabled = append(disabled, entry)
+		} else {
+			enabled = append(enabled, entry)
+		}
+	}
+
+	return []string{
+		"DNSCTL_EVENT=" + ev.Event,
+		"DNSCTL_SOURCE=" + ev.Source,
+		"DNSCTL_EVENT_FILE=" + ev.File,
+		"DNSCTL_CHANGES=" + strconv.Itoa(len(ev.Changes)),
+		"DNSCTL_DISABLED=" + strings.Join(disabled, " "),
+		"DNSCTL_ENABLED=" + strings.Join(enabled, " "),
+		"DNSCTL_SUMMARY=" + ev.summary(),
+	}
+}
@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// webhookRecorder collects the request bodies posted to it.
+type webhookRecorder struct {
+	mu     sync.Mutex
+	bodies [][]byte
+	status int
+}
+
+func (w *webhookRecorder) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	w.mu.Lock()
+	w.bodies = append(w.bodies, body)
+	w.mu.Unlock()
+	if w.status != 0 {
+		rw.WriteHeader(w.status)
+	}
+}
+
+func testEvent() notifyEvent {
+	return notifyEvent{
+		Event:  "state-change",
+		Source: "clean-zones",
+		File:   "zones.yaml",
+		Time:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Changes: []stateChange{
+			{Name: "web01", Type: "A", Zone: "example.lan.", IP: "10.0.1.5", State: "disabled", Reason: "unreachable"},
+			{Name: "web02", Type: "A", Zone: "example.lan.", IP: "10.0.1.6", State: "enabled", Reason: "reachable again"},
+		},
+	}
+}
+
+func TestWebhookNotifier_Generic(t *testing.T) {
+	rec := &webhookRecorder{}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	var log bytes.Buffer
+	sendNotifications(context.Background(), notifyOptions{Webhooks: []string{srv.URL}, Timeout: time.Second}, testEvent(), slog.New(slog.NewTextHandler(&log, nil)))
+
+	if log.Len() != 0 {
+		t.Fatalf("unexpected warnings: %s", log.String())
+	}
+	if len(rec.bodies) != 1 {
+		t.Fatalf("webhook received %d requests, want 1", len(rec.bodies))
+	}
+
+	var got notifyEvent
+	if err := json.Unmarshal(rec.bodies[0], &got); err != nil {
+		t.Fatalf("webhook body is not an event: %v", err)
+	}
+	if len(got.Changes) != 2 || got.Changes[0].State != "disabled" || got.Changes[1].IP != "10.0.1.6" {
+		t.Fatalf("webhook event = %+v", got)
+	}
+}
+
+func TestWebhookNotifier_Slack(t *testing.T) {
+	rec := &webhookRecorder{}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	sendNotifications(context.Background(), notifyOptions{Slack: []string{srv.URL}, Timeout: time.Second}, testEvent(), slog.New(slog.DiscardHandler))
+
+	var msg map[string]string
+	if err := json.Unmarshal(rec.bodies[0], &msg); err != nil {
+		t.Fatalf("slack body: %v", err)
+	}
+	for _, want := range []string{"2 record(s) changed state", "web01 A (10.0.1.5) disabled: unreachable", "web02 A (10.0.1.6) enabled"} {
+		if !strings.Contains(msg["text"], want) {
+			t.Errorf("slack text missing %q:\n%s", want, msg["text"])
+		}
+	}
+}
+
+func TestSendNotifications_FailureIsAWarning(t *testing.T) {
+	srv := httptest.NewServer(&webhookRecorder{status: http.StatusInternalServerError})
+	defer srv.Close()
+
+	var log bytes.Buffer
+	sendNotifications(context.Background(), notifyOptions{Webhooks: []string{srv.URL + "/hooks/secret"}, Timeout: time.Second}, testEvent(), slog.New(slog.NewTextHandler(&log, nil)))
+
+	if !strings.Contains(log.String(), "500") {
+		t.Fatalf("failure was not reported: %q", log.String())
+	}
+	if strings.Contains(log.String(), "secret") {
+		t.Fatalf("warning leaks the webhook path: %q", log.String())
+	}
+}
+
+func TestSendNotifications_NoChanges(t *testing.T) {
+	rec := &webhookRecorder{}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	ev := testEvent()
+	ev.Changes = nil
+	sendNotifications(context.Background(), notifyOptions{Webhooks: []string{srv.URL}}, ev, slog.New(slog.DiscardHandler))
+
+	if len(rec.bodies) != 0 {
+		t.Fatalf("webhook called without changes")
+	}
+}
+
+func TestCommandNotifier(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "hook.out")
+	command := `printf '%s|%s|%s|%s\n' "$DNSCTL_EVENT_FILE" "$DNSCTL_CHANGES" "$DNSCTL_DISABLED" "$DNSCTL_ENABLED" > ` + out + ` && cat >> ` + out
+
+	var log bytes.Buffer
+	sendNotifications(context.Background(), notifyOptions{Command: command, Timeout: 5 * time.Second}, testEvent(), slog.New(slog.NewTextHandler(&log, nil)))
+	if log.Len() != 0 {
+		t.Fatalf("unexpected warnings: %s", log.String())
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("hook did not run: %v", err)
+	}
+
+	env, payload, _ := strings.Cut(string(data), "\n")
+	if env != "zones.yaml|2|web01=10.0.1.5|web02=10.0.1.6" {
+		t.Errorf("hook environment = %q", env)
+	}
+	if !strings.Contains(payload, `"event":"state-change"`) {
+		t.Errorf("hook stdin = %q, want the JSON event", payload)
+	}
+}
+
+// TestHookEnv_NoFlagOverrides tests that the hook environment does not set a DNSCTL_*
+// variable that would override a flag of a dnsctl run started by the hook.
+func TestHookEnv_NoFlagOverrides(t *testing.T) {
+	overrides := map[string]bool{}
+	for _, c := range append(configCommands(rootCmd), rootCmd) {
+		c.Flags().VisitAll(func(f *pflag.Flag) { overrides[envName(f.Name)] = true })
+	}
+
+	for _, kv := range hookEnv(testEvent()) {
+		name, _, _ := strings.Cut(kv, "=")
+		if overrides[name] {
+			t.Errorf("hook variable %s overrides a flag", name)
+		}
+	}
+}
+
+func TestRedactURL(t *testing.T) {
+	if got := redactURL("https://hooks.slack.com/services/T0/B0/XYZ"); got != "https://hooks.slack.com/…" {
+		t.Fatalf("redactURL = %q", got)
+	}
+}
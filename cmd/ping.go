@@ -2,11 +2,8 @@ package cmd
 
 import (
 	"context"
-	"fmt"
-	"os"
 	"os/exec"
-	"strings"
-	"sync/atomic"
+	"sync"
 	"time"
 
 	"github.com/goccy/go-yaml/ast"
@@ -14,59 +11,143 @@ import (
 
 // ping sends a single ICMP echo request to the given IP and returns true if successful.
 func ping(ip string, timeout time.Duration) bool {
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	return pingContext(context.Background(), ip, timeout)
+}
+
+// pingContext is ping bounded by ctx as well as timeout.
+func pingContext(ctx context.Context, ip string, timeout time.Duration) bool {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
 	cmd := exec.CommandContext(ctx, "ping", "-c", "1", "-W", "1", ip)
 	return cmd.Run() == nil
 }
 
+// probe is the default liveness check of runPingWorkers; tests replace it with a stub.
+var probe = pingContext
+
 type pingJob struct {
 	IP   string
 	Node ast.Node
+	// Keep marks an entry that must stay enabled even when it does not answer.
+	Keep bool
+}
+
+// probeStatus is the outcome of probing a single job.
+type probeStatus int
+
+const (
+	// probeUnknown marks a job that was never probed, or whose probe was cut short by cancellation.
+	probeUnknown probeStatus = iota
+	probeUp
+	probeDown
+)
+
+// String returns the label used in summaries and warnings.
+func (s probeStatus) String() string {
+	switch s {
+	case probeUp:
+		return "up"
+	case probeDown:
+		return "down"
+	default:
+		return "unknown"
+	}
 }
 
 type pingResult struct {
+	job    pingJob
+	status probeStatus
+	// rtt is the wall-clock duration of the probe, zero if it never ran.
+	rtt time.Duration
+	// err says why a job handed to a worker was not probed.
+	err error
+}
+
+// indexedJob carries a job's position so results can be stored in job order.
+type indexedJob struct {
+	idx int
 	job pingJob
-	ok  bool
 }
 
-// runPingWorkers concurrently pings multiple hosts and returns the results.
-// It spawns the specified number of worker goroutines to process jobs in parallel.
+// indexedResult is a probe outcome tagged with the position of its job.
+type indexedResult struct {
+	idx    int
+	status probeStatus
+	rtt    time.Duration
+	err    error
+}
+
+// runPingWorkers concurrently pings multiple hosts and returns one result per job, in job order.
+// It spawns the specified number of worker goroutines to process jobs in parallel. When ctx is
+// cancelled, workers stop picking up new jobs, in-flight probes are aborted, and every job
+// without a definitive answer is reported as probeUnknown, with the reason in err when a
+// worker gave up on it. All goroutines have exited by the
+// time it returns. Probes are additionally paced and spread across subnets according to limits,
+// and their progress is sent to report, which may be nil. Each host is checked with check,
+// or with probe when check is nil.
 func runPingWorkers(
 	ctx context.Context,
 	jobs []pingJob,
 	timeout time.Duration,
 	workers int,
+	limits probeLimits,
+	check probeFunc,
+	report progressReporter,
 ) []pingResult {
 
-	jobCh := make(chan pingJob)
-	resCh := make(chan pingResult)
+	if workers < 1 {
+		workers = 1
+	}
+	if check == nil {
+		check = probe
+	}
+	if report == nil {
+		report = nopProgress{}
+	}
+
+	results := make([]pingResult, len(jobs))
+	for i, j := range jobs {
+		results[i] = pingResult{job: j, status: probeUnknown}
+	}
 
-	var completed int64
-	total := int64(len(jobs))
+	gate := newProbeGate(limits)
+	report.start(len(jobs))
 
-	// workers
+	jobCh := make(chan indexedJob)
+	// buffered so that workers never block on send, even if nobody is collecting
+	resCh := make(chan indexedResult, len(jobs))
+
+	var wg sync.WaitGroup
 	for i := 0; i < workers; i++ {
+		wg.Add(1)
 		go func() {
-			for {
-				select {
-				case <-ctx.Done():
-					return
-				case job, ok := <-jobCh:
-					if !ok {
-						return
-					}
-
-					okPing := ping(job.IP, timeout)
-
-					// send result
-					resCh <- pingResult{job: job, ok: okPing}
-
-					// progress update (stderr only)
-					n := atomic.AddInt64(&completed, 1)
-					fmt.Fprintf(os.Stderr, "\rPinging: %d/%d", n, total)
+			defer wg.Done()
+			for ij := range jobCh {
+				release, err := gate.acquire(ctx, ij.job.IP)
+				if err != nil {
+					// keep draining jobCh so that the feeder is never left blocked
+					logger.Debug("probe skipped", "ip", ij.job.IP, "error", err)
+					resCh <- indexedResult{idx: ij.idx, status: probeUnknown, err: err}
+					continue
+				}
+
+				report.probing(ij.job.IP)
+
+				started := time.Now()
+				status := probeDown
+				if check(ctx, ij.job.IP, timeout) {
+					status = probeUp
+				} else if ctx.Err() != nil {
+					// the probe was killed by cancellation, not by the host
+					status = probeUnknown
 				}
+				rtt := time.Since(started)
+				release()
+
+				logger.Debug("probe", "ip", ij.job.IP, "status", status.String(), "rtt", rtt)
+
+				resCh <- indexedResult{idx: ij.idx, status: status, rtt: rtt}
 			}
 		}()
 	}
@@ -74,72 +155,60 @@ func runPingWorkers(
 	// feed jobs
 	go func() {
 		defer close(jobCh)
-		for _, j := range jobs {
+		for i, j := range jobs {
 			select {
 			case <-ctx.Done():
 				return
-			case jobCh <- j:
+			case jobCh <- indexedJob{idx: i, job: j}:
 			}
 		}
 	}()
 
-	results := make([]pingResult, 0, len(jobs))
-
-	for i := 0; i < len(jobs); i++ {
-		select {
-		case <-ctx.Done():
-			fmt.Fprintln(os.Stderr) // newline before exit
-			return results
+	go func() {
+		wg.Wait()
+		close(resCh)
+	}()
 
-		case res := <-resCh:
-			results = append(results, res)
+	for res := range resCh {
+		results[res.idx].status = res.status
+		results[res.idx].rtt = res.rtt
+		results[res.idx].err = res.err
+		if res.err == nil {
+			report.done(jobs[res.idx].IP, res.status)
 		}
 	}
-
-	// finish progress line cleanly
-	fmt.Fprintln(os.Stderr)
+	report.finish()
 
 	return results
 }
 
-// collectNameserverJobs extracts all unique nameserver IP addresses from the YAML root node.
-func collectNameserverJobs(root *ast.MappingNode) []pingJob {
-	var jobs []pingJob
-
-	seenIPs := make(map[string]struct{})
-
-	for _, mv := range root.Values {
-		key := mv.Key.(*ast.StringNode).Value
-		if !strings.HasPrefix(key, "nameservers") {
-			continue
-		}
-
-		seq := mv.Value.(*ast.SequenceNode)
-		for _, item := range seq.Values {
-			m := item.(*ast.MappingNode)
-
-			ip := stringValue(m, "ip_address")
-			if ip == "" {
-				continue
-			}
-
-			if _, alreadySeen := seenIPs[ip]; alreadySeen {
-				continue
-			}
-
-			seenIPs[ip] = struct{}{}
-
-			jobs = append(jobs, pingJob{
-				IP:   ip,
-				Node: item,
-			})
+// unprobed returns the results whose status is unknown.
+func unprobed(results []pingResult) []pingResult {
+	var out []pingResult
+	for _, r := range results {
+		if r.status == probeUnknown {
+			out = append(out, r)
 		}
 	}
+	return out
+}
 
+// collectNameserverJobs returns a job for every nameserver found by collectNameservers,
+// flagging entries marked with keepKey or keepComment.
+func collectNameserverJobs(root *ast.MappingNode) []pingJob {
+	var jobs []pingJob
+	for _, ns := range collectNameservers(root) {
+		jobs = append(jobs, pingJob{
+			IP:   ns.IP,
+			Node: ns.Node,
+			Keep: ns.Keep,
+		})
+	}
 	return jobs
 }
 
-// collectDNSRecordJobs extracts all unique A and AAAA record IPs from DNS records and sub-zones.
+// collectDNSRecordJobs extracts all unique A and AAAA record IPs from DNS records and sub-zones,
+// flagging entries marked with keepKey or keepComment.
 func collectDNSRecordJobs(root *ast.MappingNode) []pingJob {
 	var jobs []pingJob
 
@@ -152,7 +221,7 @@ func collectDNSRecordJobs(root *ast.MappingNode) []pingJob {
 		}
 
 		seq := n.(*ast.SequenceNode)
-		for _, item := range seq.Values {
+		for i, item := range seq.Values {
 			m := item.(*ast.MappingNode)
 
 			recordType := stringValue(m, "type")
@@ -174,6 +243,7 @@ func collectDNSRecordJobs(root *ast.MappingNode) []pingJob {
 			jobs = append(jobs, pingJob{
 				IP:   ip,
 				Node: item,
+				Keep: isKeptItem(seq, i),
 			})
 		}
 	}
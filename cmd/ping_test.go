@@ -50,7 +50,7 @@ func TestRunPingWorkers_EmptyJobs(t *testing.T) {
 	ctx := context.Background()
 	jobs := []pingJob{}
 
-	results := runPingWorkers(ctx, jobs, 1*time.Second, 4)
+	results := runPingWorkers(ctx, jobs, 1*time.Second, 4, probeLimits{}, nil, nil)
 
 	if len(results) != 0 {
 		t.Fatalf("runPingWorkers with empty jobs returned %d results, want 0", len(results))
@@ -67,14 +67,14 @@ func TestRunPingWorkers_SingleHost(t *testing.T) {
 		Node: &ast.StringNode{Value: "localhost"},
 	}}
 
-	results := runPingWorkers(ctx, jobs, 2*time.Second, 1)
+	results := runPingWorkers(ctx, jobs, 2*time.Second, 1, probeLimits{}, nil, nil)
 
 	if len(results) != 1 {
 		t.Fatalf("runPingWorkers returned %d results, want 1", len(results))
 	}
 
-	if !results[0].ok {
-		t.Fatalf("runPingWorkers(127.0.0.1).ok = false, want true")
+	if results[0].status != probeUp {
+		t.Fatalf("runPingWorkers(127.0.0.1).status = %s, want up", results[0].status)
 	}
 
 	if results[0].job.IP != "127.0.0.1" {
@@ -93,7 +93,7 @@ func TestRunPingWorkers_MultipleHosts(t *testing.T) {
 		{IP: "127.0.0.1", Node: &ast.StringNode{Value: "localhost2"}},
 	}
 
-	results := runPingWorkers(ctx, jobs, 1*time.Second, 2)
+	results := runPingWorkers(ctx, jobs, 1*time.Second, 2, probeLimits{}, nil, nil)
 
 	if len(results) != 3 {
 		t.Fatalf("runPingWorkers returned %d results, want 3", len(results))
@@ -101,7 +101,7 @@ func TestRunPingWorkers_MultipleHosts(t *testing.T) {
 
 	successCount := 0
 	for _, r := range results {
-		if r.ok {
+		if r.status == probeUp {
 			successCount++
 		}
 	}
@@ -123,7 +123,7 @@ func TestRunPingWorkers_ContextCancellation(t *testing.T) {
 
 	cancel()
 
-	results := runPingWorkers(ctx, jobs, 2*time.Second, 2)
+	results := runPingWorkers(ctx, jobs, 2*time.Second, 2, probeLimits{}, nil, nil)
 	if results == nil {
 		t.Fatalf("runPingWorkers returned nil, want []pingResult")
 	}
@@ -145,10 +145,79 @@ func TestRunPingWorkers_WithMultipleWorkers(t *testing.T) {
 				{IP: "127.0.0.1", Node: &ast.StringNode{Value: "host3"}},
 			}
 
-			results := runPingWorkers(ctx, jobs, 2*time.Second, workers)
+			results := runPingWorkers(ctx, jobs, 2*time.Second, workers, probeLimits{}, nil, nil)
 			if len(results) != len(jobs) {
 				t.Fatalf("runPingWorkers(%d workers) returned %d results, want %d", workers, len(results), len(jobs))
 			}
 		})
 	}
 }
+
+// stubProbe replaces the liveness check for the duration of the test.
+func stubProbe(t *testing.T, fn func(ctx context.Context, ip string, timeout time.Duration) bool) {
+	t.Helper()
+
+	orig := probe
+	probe = fn
+	t.Cleanup(func() { probe = orig })
+}
+
+// TestRunPingWorkers_ResultsInJobOrder tests that results line up with their jobs.
+func TestRunPingWorkers_ResultsInJobOrder(t *testing.T) {
+	stubProbe(t, func(ctx context.Context, ip string, timeout time.Duration) bool {
+		return ip != "10.0.0.2"
+	})
+
+	jobs := []pingJob{{IP: "10.0.0.1"}, {IP: "10.0.0.2"}, {IP: "10.0.0.3"}}
+	results := runPingWorkers(context.Background(), jobs, time.Second, 0, probeLimits{}, nil, nil)
+
+	want := []probeStatus{probeUp, probeDown, probeUp}
+	for i, r := range results {
+		if r.job.IP != jobs[i].IP || r.status != want[i] {
+			t.Fatalf("result %d = %s/%s, want %s/%s", i, r.job.IP, r.status, jobs[i].IP, want[i])
+		}
+	}
+}
+
+// TestRunPingWorkers_CancelMarksUnknown tests that cancellation labels unprobed and aborted jobs as unknown.
+func TestRunPingWorkers_CancelMarksUnknown(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stubProbe(t, func(ctx context.Context, ip string, timeout time.Duration) bool {
+		if ip == "10.0.0.1" {
+			return true
+		}
+		// the second host hangs until the run is cancelled
+		cancel()
+		<-ctx.Done()
+		return false
+	})
+
+	jobs := []pingJob{{IP: "10.0.0.1"}, {IP: "10.0.0.2"}, {IP: "10.0.0.3"}, {IP: "10.0.0.4"}}
+	results := runPingWorkers(ctx, jobs, time.Second, 1, probeLimits{}, nil, nil)
+
+	if len(results) != len(jobs) {
+		t.Fatalf("runPingWorkers returned %d results, want %d", len(results), len(jobs))
+	}
+	if results[0].status != probeUp {
+		t.Fatalf("result 0 status = %s, want up", results[0].status)
+	}
+	for _, r := range results[1:] {
+		if r.status != probeUnknown {
+			t.Fatalf("result %s status = %s, want unknown", r.job.IP, r.status)
+		}
+	}
+	if got := len(unprobed(results)); got != 3 {
+		t.Fatalf("unprobed returned %d results, want 3", got)
+	}
+}
+
+// TestProbeStatus_String tests the status labels.
+func TestProbeStatus_String(t *testing.T) {
+	for s, want := range map[probeStatus]string{probeUp: "up", probeDown: "down", probeUnknown: "unknown"} {
+		if s.String() != want {
+			t.Errorf("probeStatus(%d).String() = %q, want %q", s, s.String(), want)
+		}
+	}
+}
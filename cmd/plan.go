@@ -0,0 +1,238 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// resourceRecord is the normalized, provider-independent form of a single DNS RR.
+// Data holds the presentation-format rdata as produced by miekg/dns. ID is an optional
+// provider-specific identifier and takes no part in comparisons. Proxied is the
+// Cloudflare proxy flag, nil for providers that have none; it is carried over on updates.
+type resourceRecord struct {
+	Name    string
+	Type    string
+	TTL     uint32
+	Data    string
+	ID      string
+	Proxied *bool
+}
+
+// autoTTL is the TTL of a served record whose TTL the provider picks itself. It
+// matches any inventory TTL.
+const autoTTL = 0
+
+// key identifies a record independently of its TTL.
+func (r resourceRecord) key() string {
+	return r.Name + " " + r.Type + " " + r.Data
+}
+
+// String renders the record in zone-file presentation format.
+func (r resourceRecord) String() string {
+	return fmt.Sprintf("%s %d IN %s %s", r.Name, r.TTL, r.Type, r.Data)
+}
+
+// managedTypes lists the record types dnsctl reconciles; everything else on the server is left alone.
+var managedTypes = map[string]bool{
+	"A":     true,
+	"AAAA":  true,
+	"CNAME": true,
+	"MX":    true,
+	"TXT":   true,
+	"PTR":   true,
+	"SRV":   true,
+	"NS":    true,
+	"CAA":   true,
+}
+
+type changeAction string
+
+const (
+	changeCreate changeAction = "create"
+	changeUpdate changeAction = "update"
+	changeDelete changeAction = "delete"
+)
+
+// recordChange is a single planned modification. Old is empty for creates, New for deletes.
+type recordChange struct {
+	Action changeAction
+	Old    resourceRecord
+	New    resourceRecord
+}
+
+// resourceFromRR converts a miekg/dns RR into its normalized form.
+func resourceFromRR(rr dns.RR) resourceRecord {
+	h := rr.Header()
+	data := strings.TrimPrefix(rr.String(), h.String())
+
+	return resourceRecord{
+		Name: strings.ToLower(h.Name),
+		Type: dns.TypeToString[h.Rrtype],
+		TTL:  h.Ttl,
+		Data: data,
+	}
+}
+
+// hostZones maps each forward host name to the zone of its first A or AAAA record.
+// It is used to qualify the relative PTR targets stored in the inventory.
+func hostZones(records []dnsRecord) map[string]string {
+	zones := map[string]string{}
+	for _, r := range records {
+		if r.Type != "A" && r.Type != "AAAA" {
+			continue
+		}
+		if _, ok := zones[r.Host]; !ok {
+			zones[r.Host] = r.Zone
+		}
+	}
+	return zones
+}
+
+// rrFromRecord parses an inventory record into a miekg/dns RR.
+// Relative names in the rdata are qualified against the record's zone, except PTR
+// targets, which are qualified against the forward zone of the matching host.
+func rrFromRecord(rec dnsRecord, zones map[string]string) (dns.RR, error) {
+	if rec.Zone == "" {
+		return nil, fmt.Errorf("record %q (%s) has no zone", rec.Host, rec.Type)
+	}
+
+	value := rec.Value
+	if rec.Type == "PTR" {
+		value = rec.Host
+		if !strings.HasSuffix(value, ".") {
+			if z, ok := zones[value]; ok {
+				value += "." + z
+			} else {
+				value += "."
+			}
+		}
+	}
+
+	text := fmt.Sprintf("%s %d IN %s %s", rec.Name(), rec.TTL, rec.Type, value)
+
+	zp := dns.NewZoneParser(strings.NewReader(text), rec.Zone, "")
+	rr, ok := zp.Next()
+	if err := zp.Err(); err != nil {
+		return nil, fmt.Errorf("record %s %s: %w", rec.Name(), rec.Type, err)
+	}
+	if !ok || rr == nil {
+		return nil, fmt.Errorf("record %s %s: empty record", rec.Name(), rec.Type)
+	}
+
+	return rr, nil
+}
+
+// desiredRecords returns the normalized records the inventory declares for zone.
+// Disabled records are omitted so that applying the plan removes them from the server.
+func desiredRecords(records []dnsRecord, zone string) ([]resourceRecord, error) {
+	zones := hostZones(records)
+
+	var out []resourceRecord
+	for _, rec := range records {
+		if rec.Disabled || rec.Zone != zone || !managedTypes[rec.Type] {
+			continue
+		}
+
+		rr, err := rrFromRecord(rec, zones)
+		if err != nil {
+			return nil, err
+		}
+
+		out = append(out, resourceFromRR(rr))
+	}
+
+	return out, nil
+}
+
+// managedRecords filters records fetched from a server down to the ones dnsctl reconciles.
+// The zone apex NS set is owned by the server configuration and is never touched.
+func managedRecords(records []resourceRecord, zone string) []resourceRecord {
+	var out []resourceRecord
+	for _, r := range records {
+		if !managedTypes[r.Type] {
+			continue
+		}
+		if r.Type == "NS" && r.Name == zone {
+			continue
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+// diffRecords computes the changes needed to turn current into desired.
+// Records that differ only in TTL are reported as updates, unless the current TTL is autoTTL.
+func diffRecords(desired, current []resourceRecord) []recordChange {
+	want := map[string]resourceRecord{}
+	for _, r := range desired {
+		want[r.key()] = r
+	}
+
+	have := map[string]resourceRecord{}
+	for _, r := range current {
+		have[r.key()] = r
+	}
+
+	var changes []recordChange
+
+	for k, w := range want {
+		h, ok := have[k]
+		switch {
+		case !ok:
+			changes = append(changes, recordChange{Action: changeCreate, New: w})
+		case h.TTL != w.TTL && h.TTL != autoTTL:
+			changes = append(changes, recordChange{Action: changeUpdate, Old: h, New: w})
+		}
+	}
+
+	for k, h := range have {
+		if _, ok := want[k]; !ok {
+			changes = append(changes, recordChange{Action: changeDelete, Old: h})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changeSortKey(changes[i]) < changeSortKey(changes[j])
+	})
+
+	return changes
+}
+
+// changeSortKey orders changes by owner name, then type, then rdata.
+func changeSortKey(c recordChange) string {
+	if c.Action == changeDelete {
+		return c.Old.key()
+	}
+	return c.New.key()
+}
+
+// printPlan writes a Terraform-style summary of changes for zone.
+func printPlan(w io.Writer, zone string, changes []recordChange) {
+	fmt.Fprintf(w, "zone %s:\n", zone)
+
+	var add, change, destroy int
+	for _, c := range changes {
+		switch c.Action {
+		case changeCreate:
+			add++
+			fmt.Fprintf(w, "  + %s\n", c.New)
+		case changeUpdate:
+			change++
+			fmt.Fprintf(w, "  ~ %s (ttl %d -> %d)\n", c.New, c.Old.TTL, c.New.TTL)
+		case changeDelete:
+			destroy++
+			fmt.Fprintf(w, "  - %s\n", c.Old)
+		}
+	}
+
+	if len(changes) == 0 {
+		fmt.Fprintln(w, "  no changes")
+		return
+	}
+
+	fmt.Fprintf(w, "  Plan: %d to add, %d to change, %d to destroy.\n", add, change, destroy)
+}
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDesiredRecords_QualifiesAndSkipsDisabled(t *testing.T) {
+	records := []dnsRecord{
+		{Host: "web01", Type: "A", Zone: "example.lan.", Value: "10.0.1.5", TTL: 3600},
+		{Host: "www", Type: "CNAME", Zone: "example.lan.", Value: "web01", TTL: 3600},
+		{Host: "old", Type: "A", Zone: "example.lan.", Value: "10.0.1.9", TTL: 3600, Disabled: true},
+		{Host: "web01", Type: "PTR", Zone: "1.0.10.in-addr.arpa.", Value: "5", TTL: 3600},
+	}
+
+	fwd, err := desiredRecords(records, "example.lan.")
+	if err != nil {
+		t.Fatalf("desiredRecords returned error: %v", err)
+	}
+	if len(fwd) != 2 {
+		t.Fatalf("desiredRecords returned %d forward records, want 2: %v", len(fwd), fwd)
+	}
+	if fwd[1].Data != "web01.example.lan." {
+		t.Fatalf("CNAME target = %q, want web01.example.lan.", fwd[1].Data)
+	}
+
+	rev, err := desiredRecords(records, "1.0.10.in-addr.arpa.")
+	if err != nil {
+		t.Fatalf("desiredRecords returned error: %v", err)
+	}
+	if len(rev) != 1 || rev[0].Name != "5.1.0.10.in-addr.arpa." || rev[0].Data != "web01.example.lan." {
+		t.Fatalf("unexpected PTR records: %v", rev)
+	}
+}
+
+func TestDesiredRecords_InvalidValue(t *testing.T) {
+	records := []dnsRecord{
+		{Host: "web01", Type: "A", Zone: "example.lan.", Value: "not-an-ip", TTL: 3600},
+	}
+
+	if _, err := desiredRecords(records, "example.lan."); err == nil {
+		t.Fatalf("desiredRecords returned nil, want parse error")
+	}
+}
+
+func TestDiffRecords(t *testing.T) {
+	desired := []resourceRecord{
+		{Name: "a.lan.", Type: "A", TTL: 3600, Data: "10.0.0.1"},
+		{Name: "b.lan.", Type: "A", TTL: 3600, Data: "10.0.0.2"},
+		{Name: "c.lan.", Type: "A", TTL: 3600, Data: "10.0.0.3"},
+	}
+	current := []resourceRecord{
+		{Name: "b.lan.", Type: "A", TTL: 300, Data: "10.0.0.2"},
+		{Name: "c.lan.", Type: "A", TTL: 3600, Data: "10.0.0.3"},
+		{Name: "d.lan.", Type: "A", TTL: 3600, Data: "10.0.0.4"},
+	}
+
+	changes := diffRecords(desired, current)
+	if len(changes) != 3 {
+		t.Fatalf("diffRecords returned %d changes, want 3: %v", len(changes), changes)
+	}
+
+	want := []changeAction{changeCreate, changeUpdate, changeDelete}
+	for i, c := range changes {
+		if c.Action != want[i] {
+			t.Fatalf("change %d action = %s, want %s", i, c.Action, want[i])
+		}
+	}
+	if changes[1].Old.TTL != 300 || changes[1].New.TTL != 3600 {
+		t.Fatalf("update change TTLs = %d -> %d, want 300 -> 3600", changes[1].Old.TTL, changes[1].New.TTL)
+	}
+}
+
+func TestManagedRecords_SkipsApexNSAndSOA(t *testing.T) {
+	in := []resourceRecord{
+		{Name: "lan.", Type: "SOA"},
+		{Name: "lan.", Type: "NS", Data: "ns1.lan."},
+		{Name: "sub.lan.", Type: "NS", Data: "ns.sub.lan."},
+		{Name: "a.lan.", Type: "A", Data: "10.0.0.1"},
+	}
+
+	got := managedRecords(in, "lan.")
+	if len(got) != 2 {
+		t.Fatalf("managedRecords returned %v, want delegation NS and A", got)
+	}
+}
+
+func TestPrintPlan(t *testing.T) {
+	var buf bytes.Buffer
+	printPlan(&buf, "lan.", []recordChange{
+		{Action: changeCreate, New: resourceRecord{Name: "a.lan.", Type: "A", TTL: 3600, Data: "10.0.0.1"}},
+		{Action: changeDelete, Old: resourceRecord{Name: "d.lan.", Type: "A", TTL: 3600, Data: "10.0.0.4"}},
+	})
+
+	out := buf.String()
+	for _, want := range []string{
+		"+ a.lan. 3600 IN A 10.0.0.1",
+		"- d.lan. 3600 IN A 10.0.0.4",
+		"Plan: 1 to add, 0 to change, 1 to destroy.",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("printPlan output missing %q:\n%s", want, out)
+		}
+	}
+
+	buf.Reset()
+	printPlan(&buf, "lan.", nil)
+	if !strings.Contains(buf.String(), "no changes") {
+		t.Fatalf("printPlan with no changes = %q, want 'no changes'", buf.String())
+	}
+}
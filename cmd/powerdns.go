@@ -0,0 +1,295 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// powerdnsConfig holds the connection settings for the PowerDNS Authoritative HTTP API.
+type powerdnsConfig struct {
+	URL      string
+	APIKey   string
+	ServerID string
+}
+
+// powerdnsClient syncs rrsets through the PowerDNS Authoritative HTTP API.
+type powerdnsClient struct {
+	Config powerdnsConfig
+	HTTP   *http.Client
+}
+
+// errZoneNotFound is returned when the API reports that a zone does not exist.
+var errZoneNotFound = errors.New("zone not found")
+
+type pdnsZone struct {
+	Name        string      `json:"name"`
+	Kind        string      `json:"kind,omitempty"`
+	Nameservers []string    `json:"nameservers"`
+	RRSets      []pdnsRRSet `json:"rrsets,omitempty"`
+}
+
+type pdnsRRSet struct {
+	Name       string       `json:"name"`
+	Type       string       `json:"type"`
+	TTL        uint32       `json:"ttl,omitempty"`
+	ChangeType string       `json:"changetype,omitempty"`
+	Records    []pdnsRecord `json:"records"`
+}
+
+type pdnsRecord struct {
+	Content  string `json:"content"`
+	Disabled bool   `json:"disabled"`
+}
+
+type pdnsPatch struct {
+	RRSets []pdnsRRSet `json:"rrsets"`
+}
+
+// newPowerDNSClient returns a client for cfg, defaulting the server id to "localhost".
+func newPowerDNSClient(cfg powerdnsConfig, timeout time.Duration) *powerdnsClient {
+	if cfg.ServerID == "" {
+		cfg.ServerID = "localhost"
+	}
+	return &powerdnsClient{
+		Config: cfg,
+		HTTP:   &http.Client{Timeout: timeout},
+	}
+}
+
+// zonesURL returns the collection URL for zones on the configured server.
+func (c *powerdnsClient) zonesURL() string {
+	return strings.TrimSuffix(c.Config.URL, "/") + "/api/v1/servers/" + url.PathEscape(c.Config.ServerID) + "/zones"
+}
+
+// zoneURL returns the URL of a single zone.
+func (c *powerdnsClient) zoneURL(zone string) string {
+	return c.zonesURL() + "/" + url.PathEscape(canonicalZone(zone))
+}
+
+// pdnsError is an error status returned by the API.
+type pdnsError struct {
+	Method, Target, Status string
+	Code                   int
+	// Message is the error field of a JSON error body, or the body itself.
+	Message string
+}
+
+func (e *pdnsError) Error() string {
+	return fmt.Sprintf("powerdns %s %s: %s: %s", e.Method, e.Target, e.Status, e.Message)
+}
+
+// getZone fetches zone into out, which may be nil. It returns errZoneNotFound only when
+// the server says the zone does not exist; a 404 for a wrong server ID or API path is
+// passed on as it is, so that a misconfiguration does not plan the whole zone as new.
+func (c *powerdnsClient) getZone(zone string, out any) error {
+	err := c.do(http.MethodGet, c.zoneURL(zone), nil, out)
+
+	var e *pdnsError
+	if errors.As(err, &e) && e.Code == http.StatusNotFound && strings.HasPrefix(e.Message, "Could not find domain") {
+		return errZoneNotFound
+	}
+	return err
+}
+
+// do sends an API request with the API key and decodes a JSON response into out when non-nil.
+func (c *powerdnsClient) do(method, target string, body, out any) error {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, target, r)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-API-Key", c.Config.APIKey)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return fmt.Errorf("powerdns %s %s: %w", method, target, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		msg := strings.TrimSpace(string(body))
+		var e struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(body, &e) == nil && e.Error != "" {
+			msg = e.Error
+		}
+		return &pdnsError{Method: method, Target: target, Status: resp.Status, Code: resp.StatusCode, Message: msg}
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// ListRecords returns the managed, enabled records in zone. A missing zone yields no records.
+func (c *powerdnsClient) ListRecords(zone string) ([]resourceRecord, error) {
+	zone = canonicalZone(zone)
+
+	var z pdnsZone
+	err := c.getZone(zone, &z)
+	if errors.Is(err, errZoneNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var out []resourceRecord
+	for _, set := range z.RRSets {
+		for _, rec := range set.Records {
+			if rec.Disabled {
+				continue
+			}
+			out = append(out, resourceRecord{
+				Name: strings.ToLower(set.Name),
+				Type: set.Type,
+				TTL:  set.TTL,
+				Data: rec.Content,
+			})
+		}
+	}
+
+	return managedRecords(out, zone), nil
+}
+
+// ensureZone creates zone as a Native zone if the server does not have it yet.
+func (c *powerdnsClient) ensureZone(zone string) error {
+	err := c.getZone(zone, nil)
+	if !errors.Is(err, errZoneNotFound) {
+		return err
+	}
+
+	return c.do(http.MethodPost, c.zonesURL(), pdnsZone{
+		Name:        canonicalZone(zone),
+		Kind:        "Native",
+		Nameservers: []string{},
+	}, nil)
+}
+
+// ApplyChanges applies changes by replacing every affected rrset in a single PATCH.
+// Rrsets left without records are deleted.
+func (c *powerdnsClient) ApplyChanges(zone string, changes []recordChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	zone = canonicalZone(zone)
+	if err := c.ensureZone(zone); err != nil {
+		return err
+	}
+
+	current, err := c.ListRecords(zone)
+	if err != nil {
+		return err
+	}
+
+	patch := pdnsPatch{RRSets: buildRRSetPatch(current, changes)}
+	return c.do(http.MethodPatch, c.zoneURL(zone), patch, nil)
+}
+
+// buildRRSetPatch computes REPLACE/DELETE rrset changes for every (name, type)
+// touched by changes, starting from the current records.
+func buildRRSetPatch(current []resourceRecord, changes []recordChange) []pdnsRRSet {
+	type setKey struct{ name, typ string }
+
+	sets := map[setKey]map[string]resourceRecord{}
+	touched := map[setKey]bool{}
+	// PowerDNS keeps one TTL per rrset; the TTL of the inventory records wins.
+	ttls := map[setKey]uint32{}
+
+	add := func(r resourceRecord) {
+		k := setKey{r.Name, r.Type}
+		if sets[k] == nil {
+			sets[k] = map[string]resourceRecord{}
+		}
+		sets[k][r.Data] = r
+	}
+
+	for _, r := range current {
+		add(r)
+	}
+
+	for _, ch := range changes {
+		if ch.Action == changeDelete || ch.Action == changeUpdate {
+			k := setKey{ch.Old.Name, ch.Old.Type}
+			touched[k] = true
+			delete(sets[k], ch.Old.Data)
+		}
+		if ch.Action == changeCreate || ch.Action == changeUpdate {
+			k := setKey{ch.New.Name, ch.New.Type}
+			touched[k] = true
+			ttls[k] = ch.New.TTL
+			add(ch.New)
+		}
+	}
+
+	var out []pdnsRRSet
+	for k := range touched {
+		recs := sets[k]
+		if len(recs) == 0 {
+			out = append(out, pdnsRRSet{Name: k.name, Type: k.typ, ChangeType: "DELETE", Records: []pdnsRecord{}})
+			continue
+		}
+
+		set := pdnsRRSet{Name: k.name, Type: k.typ, TTL: ttls[k], ChangeType: "REPLACE"}
+		var contents []string
+		for content, r := range recs {
+			contents = append(contents, content)
+			if ttls[k] == 0 && (set.TTL == 0 || r.TTL < set.TTL) {
+				set.TTL = r.TTL
+			}
+		}
+		sort.Strings(contents)
+		for _, content := range contents {
+			set.Records = append(set.Records, pdnsRecord{Content: content})
+		}
+		out = append(out, set)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Name != out[j].Name {
+			return out[i].Name < out[j].Name
+		}
+		return out[i].Type < out[j].Type
+	})
+
+	return out
+}
+
+// CreateRecord adds rec to its rrset.
+func (c *powerdnsClient) CreateRecord(zone string, rec resourceRecord) error {
+	return singleChange(c, zone, recordChange{Action: changeCreate, New: rec})
+}
+
+// UpdateRecord replaces old with rec in their rrset.
+func (c *powerdnsClient) UpdateRecord(zone string, old, rec resourceRecord) error {
+	return singleChange(c, zone, recordChange{Action: changeUpdate, Old: old, New: rec})
+}
+
+// DeleteRecord removes rec from its rrset, deleting the rrset when it becomes empty.
+func (c *powerdnsClient) DeleteRecord(zone string, rec resourceRecord) error {
+	return singleChange(c, zone, recordChange{Action: changeDelete, Old: rec})
+}
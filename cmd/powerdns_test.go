@@ -0,0 +1,235 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testPowerDNSKey = "s3cret"
+
+// fakePowerDNS is an in-memory implementation of the PowerDNS zones API.
+type fakePowerDNS struct {
+	mu      sync.Mutex
+	zones   map[string]map[string]pdnsRRSet
+	patches []pdnsPatch
+	created []string
+}
+
+func startFakePowerDNS(t *testing.T) (*fakePowerDNS, *httptest.Server) {
+	t.Helper()
+
+	fp := &fakePowerDNS{zones: map[string]map[string]pdnsRRSet{}}
+	srv := httptest.NewServer(fp)
+	t.Cleanup(srv.Close)
+	return fp, srv
+}
+
+func (fp *fakePowerDNS) addRRSet(zone string, set pdnsRRSet) {
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+
+	if fp.zones[zone] == nil {
+		fp.zones[zone] = map[string]pdnsRRSet{}
+	}
+	fp.zones[zone][set.Name+"/"+set.Type] = set
+}
+
+func (fp *fakePowerDNS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+
+	if r.Header.Get("X-API-Key") != testPowerDNSKey {
+		http.Error(w, `{"error":"Unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	const prefix = "/api/v1/servers/localhost/zones"
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		http.NotFound(w, r)
+		return
+	}
+	zone := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, prefix), "/")
+
+	switch {
+	case r.Method == http.MethodPost && zone == "":
+		var z pdnsZone
+		json.NewDecoder(r.Body).Decode(&z)
+		fp.zones[z.Name] = map[string]pdnsRRSet{}
+		fp.created = append(fp.created, z.Name)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(z)
+
+	case r.Method == http.MethodGet:
+		sets, ok := fp.zones[zone]
+		if !ok {
+			http.Error(w, `{"error":"Could not find domain '`+zone+`'"}`, http.StatusNotFound)
+			return
+		}
+		z := pdnsZone{Name: zone}
+		for _, s := range sets {
+			z.RRSets = append(z.RRSets, s)
+		}
+		json.NewEncoder(w).Encode(z)
+
+	case r.Method == http.MethodPatch:
+		sets, ok := fp.zones[zone]
+		if !ok {
+			http.Error(w, `{"error":"Not Found"}`, http.StatusNotFound)
+			return
+		}
+		var p pdnsPatch
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fp.patches = append(fp.patches, p)
+		for _, s := range p.RRSets {
+			key := s.Name + "/" + s.Type
+			switch s.ChangeType {
+			case "REPLACE":
+				s.ChangeType = ""
+				sets[key] = s
+			case "DELETE":
+				delete(sets, key)
+			default:
+				http.Error(w, "bad changetype", http.StatusUnprocessableEntity)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func TestPowerDNSClient_ListRecords(t *testing.T) {
+	fp, srv := startFakePowerDNS(t)
+	fp.addRRSet("example.lan.", pdnsRRSet{Name: "example.lan.", Type: "SOA", TTL: 3600, Records: []pdnsRecord{{Content: "ns1. hostmaster. 1 2 3 4 5"}}})
+	fp.addRRSet("example.lan.", pdnsRRSet{Name: "web.example.lan.", Type: "A", TTL: 300, Records: []pdnsRecord{
+		{Content: "10.0.1.5"},
+		{Content: "10.0.1.6", Disabled: true},
+	}})
+
+	c := newPowerDNSClient(powerdnsConfig{URL: srv.URL, APIKey: testPowerDNSKey}, 2*time.Second)
+	got, err := c.ListRecords("example.lan")
+	if err != nil {
+		t.Fatalf("ListRecords returned error: %v", err)
+	}
+	if len(got) != 1 || got[0].Data != "10.0.1.5" || got[0].TTL != 300 {
+		t.Fatalf("ListRecords = %v, want only the enabled A record", got)
+	}
+
+	missing, err := c.ListRecords("missing.lan.")
+	if err != nil || len(missing) != 0 {
+		t.Fatalf("ListRecords(missing) = %v, %v; want no records and no error", missing, err)
+	}
+}
+
+func TestPowerDNSClient_BadAPIKey(t *testing.T) {
+	_, srv := startFakePowerDNS(t)
+
+	c := newPowerDNSClient(powerdnsConfig{URL: srv.URL, APIKey: "wrong"}, 2*time.Second)
+	_, err := c.ListRecords("example.lan.")
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("ListRecords with bad key error = %v, want 401", err)
+	}
+}
+
+func TestPowerDNSClient_WrongServerID(t *testing.T) {
+	_, srv := startFakePowerDNS(t)
+
+	c := newPowerDNSClient(powerdnsConfig{URL: srv.URL, APIKey: testPowerDNSKey, ServerID: "typo"}, 2*time.Second)
+	_, err := c.ListRecords("example.lan.")
+	if err == nil || errors.Is(err, errZoneNotFound) || !strings.Contains(err.Error(), "404") {
+		t.Fatalf("ListRecords with a wrong server ID error = %v, want a 404 that is not errZoneNotFound", err)
+	}
+}
+
+func TestBuildRRSetPatch(t *testing.T) {
+	current := []resourceRecord{
+		{Name: "web.lan.", Type: "A", TTL: 300, Data: "10.0.0.1"},
+		{Name: "web.lan.", Type: "A", TTL: 300, Data: "10.0.0.2"},
+		{Name: "old.lan.", Type: "A", TTL: 300, Data: "10.0.0.9"},
+	}
+	changes := []recordChange{
+		{Action: changeDelete, Old: resourceRecord{Name: "web.lan.", Type: "A", TTL: 300, Data: "10.0.0.2"}},
+		{Action: changeDelete, Old: resourceRecord{Name: "old.lan.", Type: "A", TTL: 300, Data: "10.0.0.9"}},
+		{Action: changeCreate, New: resourceRecord{Name: "new.lan.", Type: "A", TTL: 60, Data: "10.0.0.3"}},
+	}
+
+	got := buildRRSetPatch(current, changes)
+	if len(got) != 3 {
+		t.Fatalf("buildRRSetPatch returned %d rrsets, want 3: %+v", len(got), got)
+	}
+
+	if got[0].Name != "new.lan." || got[0].ChangeType != "REPLACE" || got[0].TTL != 60 {
+		t.Fatalf("unexpected rrset for new.lan.: %+v", got[0])
+	}
+	if got[1].Name != "old.lan." || got[1].ChangeType != "DELETE" {
+		t.Fatalf("unexpected rrset for old.lan.: %+v", got[1])
+	}
+	if got[2].Name != "web.lan." || got[2].ChangeType != "REPLACE" || len(got[2].Records) != 1 || got[2].Records[0].Content != "10.0.0.1" {
+		t.Fatalf("unexpected rrset for web.lan.: %+v", got[2])
+	}
+}
+
+func TestRunApply_PowerDNS(t *testing.T) {
+	fp, srv := startFakePowerDNS(t)
+	fp.addRRSet("example.lan.", pdnsRRSet{Name: "web02.example.lan.", Type: "A", TTL: 3600, Records: []pdnsRecord{{Content: "10.0.1.6"}}})
+
+	opts := applyOptions{
+		File:     writeInventory(t, applyFixture),
+		Provider: "powerdns",
+		API:      apiConfig{URL: srv.URL, Key: testPowerDNSKey},
+		Timeout:  2 * time.Second,
+		PlanOnly: true,
+	}
+
+	var out bytes.Buffer
+	if err := runApply(opts, &out); err != nil {
+		t.Fatalf("plan returned error: %v", err)
+	}
+	if len(fp.patches) != 0 {
+		t.Fatalf("plan sent %d patches, want 0", len(fp.patches))
+	}
+
+	opts.PlanOnly = false
+	if err := runApply(opts, &out); err != nil {
+		t.Fatalf("apply returned error: %v", err)
+	}
+
+	sets := fp.zones["example.lan."]
+	if _, ok := sets["web02.example.lan./A"]; ok {
+		t.Fatalf("disabled record web02 still present after apply: %+v", sets)
+	}
+	web01, ok := sets["web01.example.lan./A"]
+	if !ok || len(web01.Records) != 1 || web01.Records[0].Content != "10.0.1.5" {
+		t.Fatalf("web01 rrset after apply = %+v", web01)
+	}
+}
+
+func TestRunApply_PowerDNSCreatesZone(t *testing.T) {
+	fp, srv := startFakePowerDNS(t)
+
+	err := runApply(applyOptions{
+		File:     writeInventory(t, applyFixture),
+		Provider: "powerdns",
+		API:      apiConfig{URL: srv.URL, Key: testPowerDNSKey},
+		Timeout:  2 * time.Second,
+	}, &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("apply returned error: %v", err)
+	}
+
+	if len(fp.created) != 1 || fp.created[0] != "example.lan." {
+		t.Fatalf("created zones = %v, want [example.lan.]", fp.created)
+	}
+}
@@ -0,0 +1,213 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// progressReporter receives probe lifecycle events from runPingWorkers.
+// Implementations must be safe for concurrent use.
+type progressReporter interface {
+	start(total int)
+	probing(ip string)
+	done(ip string, status probeStatus)
+	finish()
+}
+
+// progressInterval is how often plain progress lines are printed in non-interactive output.
+const progressInterval = 10 * time.Second
+
+// barRedraw throttles redraws of the interactive bar.
+const barRedraw = 100 * time.Millisecond
+
+// newProgress picks a reporter for w: nothing when quiet, a live bar on terminals and
+// periodic plain lines everywhere else, e.g. CI logs or redirected stderr.
+func newProgress(w io.Writer, quiet bool) progressReporter {
+	switch {
+	case quiet:
+		return nopProgress{}
+	case isTerminal(w):
+		return &barProgress{w: w, now: time.Now, width: 30}
+	default:
+		return &lineProgress{w: w, now: time.Now, interval: progressInterval}
+	}
+}
+
+// isTerminal reports whether w is a character device on a terminal that can handle
+// carriage returns and escape sequences.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	if os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// nopProgress discards every event.
+type nopProgress struct{}
+
+func (nopProgress) start(int)                {}
+func (nopProgress) probing(string)           {}
+func (nopProgress) done(string, probeStatus) {}
+func (nopProgress) finish()                  {}
+
+// progressState holds the counters shared by the bar and line reporters.
+type progressState struct {
+	total     int
+	completed int
+	failed    int
+	current   string
+	started   time.Time
+}
+
+// eta estimates the remaining time from the average duration of completed probes.
+func (s progressState) eta(now time.Time) time.Duration {
+	if s.completed == 0 || s.completed >= s.total {
+		return 0
+	}
+	perJob := now.Sub(s.started) / time.Duration(s.completed)
+	return (perJob * time.Duration(s.total-s.completed)).Round(time.Second)
+}
+
+// percent returns the completed share as a whole percentage.
+func (s progressState) percent() int {
+	if s.total == 0 {
+		return 100
+	}
+	return s.completed * 100 / s.total
+}
+
+// barProgress redraws a single status line with a bar, ETA, failures and the current host.
+type barProgress struct {
+	w     io.Writer
+	now   func() time.Time
+	width int
+
+	mu    sync.Mutex
+	state progressState
+	drawn time.Time
+}
+
+func (p *barProgress) start(total int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.state = progressState{total: total, started: p.now()}
+	p.draw(true)
+}
+
+func (p *barProgress) probing(ip string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.state.current = ip
+	p.draw(false)
+}
+
+func (p *barProgress) done(ip string, status probeStatus) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.state.completed++
+	if status == probeDown {
+		p.state.failed++
+	}
+	p.draw(p.state.completed == p.state.total)
+}
+
+func (p *barProgress) finish() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.state.current = ""
+	p.draw(true)
+	fmt.Fprintln(p.w)
+}
+
+// draw renders the bar unless the last redraw was too recent and force is false.
+func (p *barProgress) draw(force bool) {
+	now := p.now()
+	if !force && now.Sub(p.drawn) < barRedraw {
+		return
+	}
+	p.drawn = now
+
+	s := p.state
+	filled := p.width
+	if s.total > 0 {
+		filled = s.completed * p.width / s.total
+	}
+	bar := strings.Repeat("#", filled) + strings.Repeat("-", p.width-filled)
+
+	line := fmt.Sprintf("[%s] %d/%d %3d%%  failed %d", bar, s.completed, s.total, s.percent(), s.failed)
+	if eta := s.eta(now); eta > 0 {
+		line += fmt.Sprintf("  ETA %s", eta)
+	}
+	if s.current != "" {
+		line += "  " + s.current
+	}
+
+	// \033[K clears whatever a longer previous line left behind
+	fmt.Fprintf(p.w, "\r%s\033[K", line)
+}
+
+// lineProgress prints a plain status line every interval, suitable for logs.
+type lineProgress struct {
+	w        io.Writer
+	now      func() time.Time
+	interval time.Duration
+
+	mu      sync.Mutex
+	state   progressState
+	printed time.Time
+}
+
+func (p *lineProgress) start(total int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.state = progressState{total: total, started: p.now()}
+	p.printed = p.state.started
+	fmt.Fprintf(p.w, "probing %d host(s)\n", total)
+}
+
+func (p *lineProgress) probing(ip string) {}
+
+func (p *lineProgress) done(ip string, status probeStatus) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.state.completed++
+	if status == probeDown {
+		p.state.failed++
+	}
+
+	now := p.now()
+	if now.Sub(p.printed) < p.interval {
+		return
+	}
+	p.printed = now
+
+	s := p.state
+	fmt.Fprintf(p.w, "progress: %d/%d (%d%%), %d failed, ETA %s\n", s.completed, s.total, s.percent(), s.failed, s.eta(now))
+}
+
+func (p *lineProgress) finish() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	s := p.state
+	fmt.Fprintf(p.w, "probed %d/%d host(s), %d failed in %s\n",
+		s.completed, s.total, s.failed, p.now().Sub(s.started).Round(time.Millisecond))
+}
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClock returns a now function that advances by step on every call.
+func fakeClock(step time.Duration) func() time.Time {
+	t := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	return func() time.Time {
+		t = t.Add(step)
+		return t
+	}
+}
+
+func TestNewProgress(t *testing.T) {
+	var buf bytes.Buffer
+
+	if _, ok := newProgress(&buf, true).(nopProgress); !ok {
+		t.Errorf("newProgress(quiet) should discard progress")
+	}
+	if _, ok := newProgress(&buf, false).(*lineProgress); !ok {
+		t.Errorf("newProgress(non-terminal) should print plain lines")
+	}
+}
+
+func TestLineProgress(t *testing.T) {
+	var buf bytes.Buffer
+	p := &lineProgress{w: &buf, now: fakeClock(4 * time.Second), interval: 10 * time.Second}
+
+	p.start(4)
+	p.probing("10.0.0.1")
+	p.done("10.0.0.1", probeUp)
+	p.done("10.0.0.2", probeDown)
+	p.done("10.0.0.3", probeUp)
+	p.done("10.0.0.4", probeUp)
+	p.finish()
+
+	out := buf.String()
+	if strings.Contains(out, "\r") {
+		t.Fatalf("plain progress must not contain carriage returns: %q", out)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	want := []string{
+		"probing 4 host(s)",
+		"progress: 3/4 (75%), 1 failed, ETA 4s",
+		"probed 4/4 host(s), 1 failed in 20s",
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("got %d lines, want %d:\n%s", len(lines), len(want), out)
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Errorf("line %d = %q, want %q", i, lines[i], want[i])
+		}
+	}
+}
+
+func TestBarProgress(t *testing.T) {
+	var buf bytes.Buffer
+	p := &barProgress{w: &buf, now: fakeClock(time.Second), width: 10}
+
+	p.start(4)
+	p.probing("10.0.0.1")
+	p.done("10.0.0.1", probeDown)
+
+	last := buf.String()[strings.LastIndex(buf.String(), "\r")+1:]
+	for _, want := range []string{"[##--------] 1/4  25%", "failed 1", "ETA 9s", "10.0.0.1"} {
+		if !strings.Contains(last, want) {
+			t.Fatalf("bar %q missing %q", last, want)
+		}
+	}
+
+	p.done("10.0.0.2", probeUp)
+	p.done("10.0.0.3", probeUp)
+	p.done("10.0.0.4", probeUp)
+	p.finish()
+
+	if !strings.HasSuffix(buf.String(), "\n") {
+		t.Fatalf("finish should end the bar line")
+	}
+	last = strings.TrimSuffix(buf.String()[strings.LastIndex(buf.String(), "\r")+1:], "\n")
+	if !strings.Contains(last, "[##########] 4/4 100%") || strings.Contains(last, "ETA") || strings.Contains(last, "10.0.0.1") {
+		t.Fatalf("final bar = %q", last)
+	}
+}
+
+func TestBarProgress_ThrottlesRedraws(t *testing.T) {
+	var buf bytes.Buffer
+	p := &barProgress{w: &buf, now: fakeClock(time.Millisecond), width: 10}
+
+	p.start(100)
+	for i := 0; i < 20; i++ {
+		p.probing("10.0.0.1")
+	}
+
+	if n := strings.Count(buf.String(), "\r"); n != 1 {
+		t.Fatalf("bar was drawn %d times, want 1", n)
+	}
+}
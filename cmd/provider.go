@@ -0,0 +1,105 @@
+package cmd
+
+import "fmt"
+
+// Provider is a DNS backend that apply reconciles zones against. Records passed to
+// UpdateRecord and DeleteRecord are the ones previously returned by ListRecords, so
+// providers may rely on any ID they stored there.
+type Provider interface {
+	ListRecords(zone string) ([]resourceRecord, error)
+	CreateRecord(zone string, rec resourceRecord) error
+	UpdateRecord(zone string, old, rec resourceRecord) error
+	DeleteRecord(zone string, rec resourceRecord) error
+}
+
+// batchProvider is implemented by providers that can apply a whole change set in one request.
+type batchProvider interface {
+	ApplyChanges(zone string, changes []recordChange) error
+}
+
+// typeLimitedProvider is implemented by providers that can only hold some record types.
+type typeLimitedProvider interface {
+	SupportsType(rtype string) bool
+}
+
+// supportedRecords splits desired into the records p can hold and the ones it cannot,
+// so that unsupported records are left out of the plan instead of failing half-way
+// through applying it.
+func supportedRecords(p Provider, desired []resourceRecord) (supported, skipped []resourceRecord) {
+	limited, ok := p.(typeLimitedProvider)
+	if !ok {
+		return desired, nil
+	}
+
+	for _, r := range desired {
+		if limited.SupportsType(r.Type) {
+			supported = append(supported, r)
+		} else {
+			skipped = append(skipped, r)
+		}
+	}
+	return supported, skipped
+}
+
+// newProvider returns the provider selected by opts.Provider.
+func newProvider(opts applyOptions) (Provider, error) {
+	switch opts.Provider {
+	case "", "rfc2136":
+		if opts.Server == "" {
+			return nil, fmt.Errorf("--server is required")
+		}
+		return &rfc2136Client{
+			Server:  opts.Server,
+			TSIG:    opts.TSIG,
+			Timeout: opts.Timeout,
+		}, nil
+	case "powerdns":
+		if opts.API.URL == "" {
+			return nil, fmt.Errorf("--api-url is required for the powerdns provider")
+		}
+		return newPowerDNSClient(powerdnsConfig{
+			URL:      opts.API.URL,
+			APIKey:   opts.API.Key,
+			ServerID: opts.API.ServerID,
+		}, opts.Timeout), nil
+	case "cloudflare":
+		if opts.API.Key == "" {
+			return nil, fmt.Errorf("--api-key is required for the cloudflare provider")
+		}
+		return newCloudflareClient(cloudflareConfig{
+			URL:   opts.API.URL,
+			Token: opts.API.Key,
+		}, opts.Timeout), nil
+	default:
+		return nil, fmt.Errorf("unknown provider %q", opts.Provider)
+	}
+}
+
+// applyChanges pushes changes to p, in one batch when the provider supports it.
+func applyChanges(p Provider, zone string, changes []recordChange) error {
+	if b, ok := p.(batchProvider); ok {
+		return b.ApplyChanges(zone, changes)
+	}
+
+	for _, c := range changes {
+		var err error
+		switch c.Action {
+		case changeCreate:
+			err = p.CreateRecord(zone, c.New)
+		case changeUpdate:
+			err = p.UpdateRecord(zone, c.Old, c.New)
+		case changeDelete:
+			err = p.DeleteRecord(zone, c.Old)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// singleChange adapts a per-record operation to a batch provider.
+func singleChange(b batchProvider, zone string, c recordChange) error {
+	return b.ApplyChanges(zone, []recordChange{c})
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"errors"
+	"testing"
+)
+
+// recordingProvider is a Provider without batch support that records every call.
+type recordingProvider struct {
+	calls []string
+	fail  string
+}
+
+func (p *recordingProvider) ListRecords(zone string) ([]resourceRecord, error) {
+	return nil, nil
+}
+
+func (p *recordingProvider) CreateRecord(zone string, rec resourceRecord) error {
+	return p.record("create " + rec.Name)
+}
+
+func (p *recordingProvider) UpdateRecord(zone string, old, rec resourceRecord) error {
+	return p.record("update " + old.Name)
+}
+
+func (p *recordingProvider) DeleteRecord(zone string, rec resourceRecord) error {
+	return p.record("delete " + rec.Name)
+}
+
+func (p *recordingProvider) record(call string) error {
+	p.calls = append(p.calls, call)
+	if call == p.fail {
+		return errors.New("boom")
+	}
+	return nil
+}
+
+func TestApplyChanges_PerRecord(t *testing.T) {
+	p := &recordingProvider{}
+	changes := []recordChange{
+		{Action: changeCreate, New: resourceRecord{Name: "a.lan."}},
+		{Action: changeUpdate, Old: resourceRecord{Name: "b.lan."}, New: resourceRecord{Name: "b.lan."}},
+		{Action: changeDelete, Old: resourceRecord{Name: "c.lan."}},
+	}
+
+	if err := applyChanges(p, "lan.", changes); err != nil {
+		t.Fatalf("applyChanges returned error: %v", err)
+	}
+
+	want := []string{"create a.lan.", "update b.lan.", "delete c.lan."}
+	if len(p.calls) != len(want) {
+		t.Fatalf("calls = %v, want %v", p.calls, want)
+	}
+	for i := range want {
+		if p.calls[i] != want[i] {
+			t.Fatalf("calls = %v, want %v", p.calls, want)
+		}
+	}
+}
+
+func TestApplyChanges_StopsOnError(t *testing.T) {
+	p := &recordingProvider{fail: "create a.lan."}
+	changes := []recordChange{
+		{Action: changeCreate, New: resourceRecord{Name: "a.lan."}},
+		{Action: changeDelete, Old: resourceRecord{Name: "c.lan."}},
+	}
+
+	if err := applyChanges(p, "lan.", changes); err == nil {
+		t.Fatalf("applyChanges returned nil, want error")
+	}
+	if len(p.calls) != 1 {
+		t.Fatalf("applyChanges made %d calls after failure, want 1", len(p.calls))
+	}
+}
+
+func TestNewProvider(t *testing.T) {
+	cases := []struct {
+		opts    applyOptions
+		wantErr bool
+	}{
+		{applyOptions{}, true},
+		{applyOptions{Server: "10.0.0.53"}, false},
+		{applyOptions{Provider: "powerdns"}, true},
+		{applyOptions{Provider: "powerdns", API: apiConfig{URL: "http://pdns:8081"}}, false},
+		{applyOptions{Provider: "cloudflare"}, true},
+		{applyOptions{Provider: "cloudflare", API: apiConfig{Key: "token"}}, false},
+		{applyOptions{Provider: "bogus"}, true},
+	}
+
+	for _, c := range cases {
+		_, err := newProvider(c.opts)
+		if (err != nil) != c.wantErr {
+			t.Errorf("newProvider(%+v) error = %v, wantErr %v", c.opts, err, c.wantErr)
+		}
+	}
+}
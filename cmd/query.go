@@ -0,0 +1,252 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"path"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// queryFormats lists the supported query output formats.
+var queryFormats = []string{"table", "json", "csv"}
+
+// queryOptions selects inventory entries. Empty filters match everything; all
+// non-empty filters must match.
+type queryOptions struct {
+	File string
+	// Host is a case-insensitive glob matched against host labels, fully qualified
+	// names and nameserver names.
+	Host  string
+	IP    string
+	CIDR  string
+	Zone  string
+	Type  string
+	State string
+	// Format is table, json or csv.
+	Format string
+}
+
+// queryRow is one inventory entry in query output.
+type queryRow struct {
+	Section string `json:"section"`
+	Name    string `json:"name"`
+	FQDN    string `json:"fqdn,omitempty"`
+	Type    string `json:"type,omitempty"`
+	Zone    string `json:"zone,omitempty"`
+	Value   string `json:"value"`
+	TTL     uint32 `json:"ttl,omitempty"`
+	State   string `json:"state"`
+
+	ip net.IP
+}
+
+// entryState names the state of an inventory entry.
+func entryState(disabled bool) string {
+	if disabled {
+		return "disabled"
+	}
+	return "active"
+}
+
+// queryRows flattens the records and nameservers of the inventory, in file order.
+func queryRows(records []dnsRecord, nameservers []nameserver) []queryRow {
+	var rows []queryRow
+
+	for _, r := range records {
+		row := queryRow{
+			Section: r.Section,
+			Name:    r.Host,
+			FQDN:    r.Name(),
+			Type:    r.Type,
+			Zone:    r.Zone,
+			Value:   r.Value,
+			TTL:     r.TTL,
+			State:   entryState(r.Disabled),
+		}
+		switch r.Type {
+		case "A", "AAAA":
+			row.ip = net.ParseIP(r.Value)
+		case "PTR":
+			row.ip = reverseIP(r.Name())
+		}
+		rows = append(rows, row)
+	}
+
+	for _, ns := range nameservers {
+		rows = append(rows, queryRow{
+			Section: ns.Section,
+			Name:    ns.Name,
+			Value:   ns.IP,
+			State:   entryState(ns.Disabled),
+			ip:      net.ParseIP(ns.IP),
+		})
+	}
+
+	return rows
+}
+
+// queryMatcher is a compiled set of query filters.
+type queryMatcher struct {
+	opts queryOptions
+	ip   net.IP
+	cidr *net.IPNet
+}
+
+// newQueryMatcher validates opts and compiles its filters.
+func newQueryMatcher(opts queryOptions) (*queryMatcher, error) {
+	q := &queryMatcher{opts: opts}
+
+	if opts.Host != "" {
+		if _, err := path.Match(opts.Host, ""); err != nil {
+			return nil, fmt.Errorf("invalid --host pattern %q: %w", opts.Host, err)
+		}
+	}
+	if opts.IP != "" {
+		if q.ip = net.ParseIP(opts.IP); q.ip == nil {
+			return nil, fmt.Errorf("invalid --ip %q", opts.IP)
+		}
+	}
+	if opts.CIDR != "" {
+		_, n, err := net.ParseCIDR(opts.CIDR)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --cidr: %w", err)
+		}
+		q.cidr = n
+	}
+	switch opts.State {
+	case "", "active", "disabled":
+	default:
+		return nil, fmt.Errorf("invalid --state %q (want active or disabled)", opts.State)
+	}
+
+	return q, nil
+}
+
+// match reports whether row passes every filter.
+func (q *queryMatcher) match(row queryRow) bool {
+	o := q.opts
+
+	if o.Host != "" && !q.matchHost(row) {
+		return false
+	}
+	if q.ip != nil && (row.ip == nil || !row.ip.Equal(q.ip)) {
+		return false
+	}
+	if q.cidr != nil && (row.ip == nil || !q.cidr.Contains(row.ip)) {
+		return false
+	}
+	if o.Zone != "" && row.Zone != canonicalZone(o.Zone) {
+		return false
+	}
+	if o.Type != "" && row.Type != strings.ToUpper(o.Type) {
+		return false
+	}
+	if o.State != "" && row.State != o.State {
+		return false
+	}
+	return true
+}
+
+// matchHost matches the host pattern against the entry's name and fully qualified name.
+func (q *queryMatcher) matchHost(row queryRow) bool {
+	pattern := strings.ToLower(strings.TrimSuffix(q.opts.Host, "."))
+	for _, name := range []string{row.Name, row.FQDN} {
+		name = strings.ToLower(strings.TrimSuffix(name, "."))
+		if name == "" {
+			continue
+		}
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// runQuery prints the inventory entries matching opts in the requested format.
+func runQuery(opts queryOptions, out io.Writer) error {
+	q, err := newQueryMatcher(opts)
+	if err != nil {
+		return err
+	}
+
+	var render func(io.Writer, []queryRow) error
+	switch opts.Format {
+	case "", "table":
+		render = renderQueryTable
+	case "json":
+		render = renderQueryJSON
+	case "csv":
+		render = renderQueryCSV
+	default:
+		return fmt.Errorf("unknown query format %q (want one of %s)", opts.Format, strings.Join(queryFormats, ", "))
+	}
+
+	_, root, err := loadInventory(opts.File)
+	if err != nil {
+		return err
+	}
+
+	rows := []queryRow{}
+	for _, row := range queryRows(collectRecords(root), collectNameservers(root)) {
+		if q.match(row) {
+			rows = append(rows, row)
+		}
+	}
+
+	return render(out, rows)
+}
+
+// queryColumns are the column headings of table and CSV output.
+var queryColumns = []string{"SECTION", "NAME", "TYPE", "ZONE", "VALUE", "TTL", "STATE"}
+
+// fields returns the row's values in queryColumns order.
+func (r queryRow) fields() []string {
+	ttl := ""
+	if r.TTL > 0 {
+		ttl = strconv.FormatUint(uint64(r.TTL), 10)
+	}
+	return []string{r.Section, r.Name, r.Type, r.Zone, r.Value, ttl, r.State}
+}
+
+// renderQueryTable writes an aligned table with - for empty cells.
+func renderQueryTable(w io.Writer, rows []queryRow) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(queryColumns, "\t"))
+	for _, r := range rows {
+		fields := r.fields()
+		for i, f := range fields {
+			if f == "" {
+				fields[i] = "-"
+			}
+		}
+		fmt.Fprintln(tw, strings.Join(fields, "\t"))
+	}
+	return tw.Flush()
+}
+
+// renderQueryJSON writes the rows as an indented JSON array, [] when nothing matched.
+func renderQueryJSON(w io.Writer, rows []queryRow) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rows)
+}
+
+// renderQueryCSV writes the rows as CSV with a lowercase header line.
+func renderQueryCSV(w io.Writer, rows []queryRow) error {
+	cw := csv.NewWriter(w)
+	header := make([]string, len(queryColumns))
+	for i, c := range queryColumns {
+		header[i] = strings.ToLower(c)
+	}
+	cw.Write(header)
+	for _, r := range rows {
+		cw.Write(r.fields())
+	}
+	cw.Flush()
+	return cw.Error()
+}
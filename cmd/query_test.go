@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const queryFixture = `dns_records:
+  - host: web01
+    type: A
+    zone: example.lan.
+    record_value: 10.0.1.5
+    ttl: 300
+  - host: web01
+    type: PTR
+    zone: 1.0.10.in-addr.arpa.
+    record_value: 5
+  - host: web02 # DISABLED: unreachable
+    type: A
+    zone: example.lan.
+    record_value: 10.0.1.6
+  - host: www
+    type: CNAME
+    zone: example.lan.
+    record_value: web01
+sub_zone_records:
+  - host: db
+    type: AAAA
+    zone: lab.example.lan.
+    record_value: fd00::5
+nameservers:
+  - name: ns1
+    ip_address: 10.0.0.53
+nameservers_secondary:
+  - name: ns2
+    ip_address: 10.0.9.53 # DISABLED: unreachable
+`
+
+func TestRunQuery_Filters(t *testing.T) {
+	path := writeInventory(t, queryFixture)
+
+	tests := []struct {
+		name string
+		opts queryOptions
+		want []string
+	}{
+		{"all", queryOptions{}, []string{"web01", "web01", "web02", "www", "db", "ns1", "ns2"}},
+		{"host glob", queryOptions{Host: "WEB*"}, []string{"web01", "web01", "web02"}},
+		{"qualified host", queryOptions{Host: "db.lab.example.lan."}, []string{"db"}},
+		{"nameserver name", queryOptions{Host: "ns?"}, []string{"ns1", "ns2"}},
+		{"ip includes ptr", queryOptions{IP: "10.0.1.5"}, []string{"web01", "web01"}},
+		{"ipv6", queryOptions{IP: "fd00:0::5"}, []string{"db"}},
+		{"cidr", queryOptions{CIDR: "10.0.0.0/16"}, []string{"web01", "web01", "web02", "ns1", "ns2"}},
+		{"zone", queryOptions{Zone: "Example.LAN"}, []string{"web01", "web02", "www"}},
+		{"type", queryOptions{Type: "cname"}, []string{"www"}},
+		{"disabled", queryOptions{State: "disabled"}, []string{"web02", "ns2"}},
+		{"combined", queryOptions{CIDR: "10.0.1.0/24", Type: "A", State: "active"}, []string{"web01"}},
+		{"no match", queryOptions{Host: "mail*"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.File = path
+			tt.opts.Format = "json"
+
+			var out bytes.Buffer
+			if err := runQuery(tt.opts, &out); err != nil {
+				t.Fatalf("runQuery returned error: %v", err)
+			}
+
+			var rows []queryRow
+			if err := json.Unmarshal(out.Bytes(), &rows); err != nil {
+				t.Fatalf("output is not JSON: %v\n%s", err, out.String())
+			}
+
+			var names []string
+			for _, r := range rows {
+				names = append(names, r.Name)
+			}
+			if strings.Join(names, ",") != strings.Join(tt.want, ",") {
+				t.Fatalf("matched %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunQuery_Formats(t *testing.T) {
+	path := writeInventory(t, queryFixture)
+
+	var table bytes.Buffer
+	if err := runQuery(queryOptions{File: path, IP: "10.0.1.5"}, &table); err != nil {
+		t.Fatalf("runQuery(table) returned error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(table.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "SECTION") {
+		t.Fatalf("unexpected table:\n%s", table.String())
+	}
+	if fields := strings.Fields(lines[1]); strings.Join(fields, " ") != "dns_records web01 A example.lan. 10.0.1.5 300 active" {
+		t.Fatalf("unexpected table row %q", lines[1])
+	}
+
+	var csvOut bytes.Buffer
+	if err := runQuery(queryOptions{File: path, Host: "ns1", Format: "csv"}, &csvOut); err != nil {
+		t.Fatalf("runQuery(csv) returned error: %v", err)
+	}
+	want := "section,name,type,zone,value,ttl,state\nnameservers,ns1,,,10.0.0.53,,active\n"
+	if csvOut.String() != want {
+		t.Fatalf("csv output =\n%s\nwant\n%s", csvOut.String(), want)
+	}
+
+	var empty bytes.Buffer
+	if err := runQuery(queryOptions{File: path, Host: "none", Format: "json"}, &empty); err != nil {
+		t.Fatalf("runQuery(json) returned error: %v", err)
+	}
+	if strings.TrimSpace(empty.String()) != "[]" {
+		t.Fatalf("empty json output = %q, want []", empty.String())
+	}
+}
+
+func TestRunQuery_InvalidOptions(t *testing.T) {
+	path := writeInventory(t, queryFixture)
+
+	for _, opts := range []queryOptions{
+		{IP: "10.0.1"},
+		{CIDR: "10.0.1.0"},
+		{State: "down"},
+		{Host: "[web"},
+		{Format: "yaml"},
+	} {
+		opts.File = path
+		if err := runQuery(opts, &bytes.Buffer{}); err == nil {
+			t.Errorf("runQuery(%+v) returned nil, want error", opts)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/parser"
+)
+
+// recordSections lists the top-level YAML keys that hold DNS record sequences.
+var recordSections = []string{"dns_records", "sub_zone_records"}
+
+// dnsRecord is a flattened view of a single entry from dns_records or sub_zone_records.
+type dnsRecord struct {
+	Host     string
+	Type     string
+	Zone     string
+	Value    string
+	TTL      uint32
+	Disabled bool
+	Section  string
+	Node     *ast.MappingNode
+}
+
+// defaultTTL is used for records that do not declare a ttl key.
+const defaultTTL = 3600
+
+// stdinPath is the --file value that reads the inventory from standard input.
+const stdinPath = "-"
+
+// stdin is where an inventory given as "--file -" is read from; tests replace it.
+var stdin io.Reader = os.Stdin
+
+// loadInventory reads and parses a YAML inventory file, returning the parsed file and its root mapping.
+// A filePath of "-" reads the inventory from stdin.
+func loadInventory(filePath string) (*ast.File, *ast.MappingNode, error) {
+	if filePath == "" {
+		return nil, nil, fmt.Errorf("--file is required")
+	}
+
+	var data []byte
+	var err error
+	if filePath == stdinPath {
+		data, err = io.ReadAll(stdin)
+		filePath = "stdin"
+	} else {
+		data, err = os.ReadFile(filePath)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file, err := parser.ParseBytes(data, parser.ParseComments)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(file.Docs) == 0 || file.Docs[0].Body == nil {
+		return nil, nil, fmt.Errorf("%s: empty YAML document", filePath)
+	}
+
+	root, ok := file.Docs[0].Body.(*ast.MappingNode)
+	if !ok {
+		return nil, nil, fmt.Errorf("%s: top-level YAML node must be a mapping", filePath)
+	}
+
+	return file, root, nil
+}
+
+// saveInventory renders the parsed file back to YAML, preserving comments, and writes it to filePath.
+// An inventory read from stdin is written to stdout.
+func saveInventory(filePath string, file *ast.File) error {
+	out := file.String()
+	if !strings.HasSuffix(out, "\n") {
+		out += "\n"
+	}
+	if filePath == stdinPath {
+		_, err := io.WriteString(os.Stdout, out)
+		return err
+	}
+	return os.WriteFile(filePath, []byte(out), 0o644)
+}
+
+// collectRecords extracts every record from the dns_records and sub_zone_records sections.
+// Disabled records are included and flagged so callers can decide how to treat them.
+func collectRecords(root *ast.MappingNode) []dnsRecord {
+	var records []dnsRecord
+
+	for _, section := range recordSections {
+		n := mappingValue(root, section)
+		if n == nil {
+			continue
+		}
+
+		seq, ok := n.(*ast.SequenceNode)
+		if !ok {
+			continue
+		}
+
+		for _, item := range seq.Values {
+			m, ok := item.(*ast.MappingNode)
+			if !ok {
+				continue
+			}
+
+			records = append(records, recordFromNode(m, section))
+		}
+	}
+
+	return records
+}
+
+// recordFromNode converts a YAML mapping into a dnsRecord.
+func recordFromNode(m *ast.MappingNode, section string) dnsRecord {
+	rec := dnsRecord{
+		Host:     stringValue(m, "host"),
+		Type:     strings.ToUpper(stringValue(m, "type")),
+		Zone:     canonicalZone(stringValue(m, "zone")),
+		Value:    stringValue(m, "record_value"),
+		TTL:      defaultTTL,
+		Disabled: isDisabled(m),
+		Section:  section,
+		Node:     m,
+	}
+
+	if ttl, err := strconv.ParseUint(stringValue(m, "ttl"), 10, 32); err == nil {
+		rec.TTL = uint32(ttl)
+	}
+
+	return rec
+}
+
+// Name returns the fully qualified owner name of the record.
+// PTR records are keyed by record_value inside the reverse zone; everything else by host.
+func (r dnsRecord) Name() string {
+	label := r.Host
+	if r.Type == "PTR" {
+		label = r.Value
+	}
+
+	switch {
+	case label == "" || label == "@":
+		return r.Zone
+	case strings.HasSuffix(label, "."):
+		return strings.ToLower(label)
+	default:
+		return strings.ToLower(label + "." + r.Zone)
+	}
+}
+
+// canonicalZone lowercases a zone name and ensures it ends with a trailing dot.
+func canonicalZone(zone string) string {
+	if zone == "" {
+		return ""
+	}
+	zone = strings.ToLower(zone)
+	if !strings.HasSuffix(zone, ".") {
+		zone += "."
+	}
+	return zone
+}
+
+// recordZones returns the distinct zones referenced by the given records, in first-seen order.
+func recordZones(records []dnsRecord) []string {
+	var zones []string
+	seen := map[string]bool{}
+
+	for _, r := range records {
+		if r.Zone == "" || seen[r.Zone] {
+			continue
+		}
+		seen[r.Zone] = true
+		zones = append(zones, r.Zone)
+	}
+
+	return zones
+}
@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/goccy/go-yaml/ast"
+)
+
+func writeInventory(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "zones.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to write YAML fixture: %v", err)
+	}
+	return path
+}
+
+func TestLoadInventory_RequiresFile(t *testing.T) {
+	if _, _, err := loadInventory(""); err == nil || err.Error() != "--file is required" {
+		t.Fatalf("loadInventory(\"\") error = %v, want --file is required", err)
+	}
+}
+
+func TestLoadInventory_RejectsNonMapping(t *testing.T) {
+	path := writeInventory(t, "- a\n- b\n")
+	if _, _, err := loadInventory(path); err == nil {
+		t.Fatalf("loadInventory returned nil, want error for sequence root")
+	}
+}
+
+func TestCollectRecords_ParsesFieldsAndSections(t *testing.T) {
+	path := writeInventory(t, `dns_records:
+  - host: web01
+    type: a
+    zone: Example.LAN
+    record_value: 10.0.1.5
+    ttl: 300
+  - host: web01
+    type: PTR
+    zone: 1.0.10.in-addr.arpa.
+    record_value: 5
+sub_zone_records:
+  - host: db
+    type: A
+    zone: sub.example.lan.
+    record_value: 10.0.2.5 # DISABLED: unreachable
+`)
+
+	_, root, err := loadInventory(path)
+	if err != nil {
+		t.Fatalf("loadInventory returned error: %v", err)
+	}
+
+	records := collectRecords(root)
+	if len(records) != 3 {
+		t.Fatalf("collectRecords returned %d records, want 3", len(records))
+	}
+
+	a := records[0]
+	if a.Type != "A" || a.Zone != "example.lan." || a.TTL != 300 || a.Name() != "web01.example.lan." {
+		t.Fatalf("unexpected A record: %+v name=%s", a, a.Name())
+	}
+
+	ptr := records[1]
+	if ptr.Value != "5" || ptr.TTL != defaultTTL || ptr.Name() != "5.1.0.10.in-addr.arpa." {
+		t.Fatalf("unexpected PTR record: %+v name=%s", ptr, ptr.Name())
+	}
+
+	sub := records[2]
+	if !sub.Disabled || sub.Section != "sub_zone_records" {
+		t.Fatalf("unexpected sub-zone record: %+v", sub)
+	}
+}
+
+func TestDNSRecordName_Apex(t *testing.T) {
+	for _, host := range []string{"", "@"} {
+		r := dnsRecord{Host: host, Type: "MX", Zone: "example.lan."}
+		if r.Name() != "example.lan." {
+			t.Fatalf("Name() for host %q = %q, want example.lan.", host, r.Name())
+		}
+	}
+}
+
+func TestRecordZones_Distinct(t *testing.T) {
+	records := []dnsRecord{
+		{Zone: "a.lan."}, {Zone: "b.lan."}, {Zone: "a.lan."}, {Zone: ""},
+	}
+
+	got := recordZones(records)
+	if len(got) != 2 || got[0] != "a.lan." || got[1] != "b.lan." {
+		t.Fatalf("recordZones = %v, want [a.lan. b.lan.]", got)
+	}
+}
+
+func TestCollectRecords_SkipsNonSequenceSection(t *testing.T) {
+	root := &ast.MappingNode{Values: []*ast.MappingValueNode{{
+		Key:   &ast.StringNode{Value: "dns_records"},
+		Value: &ast.StringNode{Value: "oops"},
+	}}}
+
+	if got := collectRecords(root); len(got) != 0 {
+		t.Fatalf("collectRecords returned %d records, want 0", len(got))
+	}
+}
+
+// stubStdin makes "--file -" read content for the duration of the test.
+func stubStdin(t *testing.T, content string) {
+	t.Helper()
+
+	orig := stdin
+	stdin = strings.NewReader(content)
+	t.Cleanup(func() { stdin = orig })
+}
+
+func TestLoadInventory_Stdin(t *testing.T) {
+	stubStdin(t, "# piped\ndns_records:\n  - host: web01\n    type: A\n    zone: example.lan.\n    record_value: 10.0.1.5\n")
+
+	file, root, err := loadInventory("-")
+	if err != nil {
+		t.Fatalf("loadInventory(-) returned error: %v", err)
+	}
+	if got := len(collectRecords(root)); got != 1 {
+		t.Fatalf("loadInventory(-) found %d records, want 1", got)
+	}
+
+	out, err := captureStdout(t, func() error { return saveInventory("-", file) })
+	if err != nil {
+		t.Fatalf("saveInventory(-) returned error: %v", err)
+	}
+	if !strings.HasPrefix(out, "# piped\ndns_records:") {
+		t.Fatalf("saveInventory(-) wrote %q", out)
+	}
+}
+
+func TestLoadInventory_StdinErrorsNameStdin(t *testing.T) {
+	stubStdin(t, "- not a mapping\n")
+
+	if _, _, err := loadInventory("-"); err == nil || !strings.HasPrefix(err.Error(), "stdin:") {
+		t.Fatalf("loadInventory(-) error = %v, want stdin: prefix", err)
+	}
+}
@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// reviewDecision is the operator's answer for one unreachable entry.
+type reviewDecision int
+
+const (
+	// reviewAccept disables the entry.
+	reviewAccept reviewDecision = iota
+	// reviewSkip leaves the entry enabled for this run.
+	reviewSkip
+	// reviewKeep leaves the entry enabled and marks it with keepKey, so that later runs
+	// never disable it.
+	reviewKeep
+)
+
+// errReviewAborted is returned when the operator quits the review.
+var errReviewAborted = errors.New("review aborted, no output written")
+
+// reviewItem is an unreachable entry awaiting a decision.
+type reviewItem struct {
+	// idx is the position of the entry's probe result.
+	idx   int
+	entry stateChange
+	// history holds the latest probes of the entry, newest first.
+	history []probeSample
+}
+
+// reviewItems returns the entries among results that a run would disable, with their
+// probe history from cache, which may be nil.
+func reviewItems(results []pingResult, cache *probeCache, check string) []reviewItem {
+	var items []reviewItem
+
+	for i, r := range results {
+		if r.status != probeDown || isDisabled(r.job.Node) || r.job.Keep {
+			continue
+		}
+
+		var history []probeSample
+		if cache != nil {
+			history = cache.history(check, r.job.IP)
+		}
+		if len(history) == 0 {
+			history = []probeSample{{Status: r.status.String(), RTT: r.rtt, Checked: time.Now()}}
+		}
+
+		items = append(items, reviewItem{
+			idx:     i,
+			entry:   changeFromJob(r.job, "disabled", "unreachable"),
+			history: history,
+		})
+	}
+
+	return items
+}
+
+// formatHistory renders probe samples as "down 2026-10-19 09:12, up 3ms 2026-10-18 17:40".
+// The duration of a failed probe is only its timeout, so it is left out.
+func formatHistory(samples []probeSample) string {
+	parts := make([]string, len(samples))
+	for i, p := range samples {
+		when := p.Checked.Local().Format("2006-01-02 15:04")
+		if p.Status == probeUp.String() && p.RTT > 0 {
+			parts[i] = fmt.Sprintf("%s %s %s", p.Status, p.RTT.Round(100*time.Microsecond), when)
+			continue
+		}
+		parts[i] = fmt.Sprintf("%s %s", p.Status, when)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// reviewUnreachable asks the operator about every item on out, reading one answer per
+// line from in, and returns the decisions keyed by result index. An empty answer skips
+// the entry. It returns errReviewAborted when the operator quits or in runs out before
+// every item was answered.
+func reviewUnreachable(in io.Reader, out io.Writer, items []reviewItem) (map[int]reviewDecision, error) {
+	decisions := make(map[int]reviewDecision, len(items))
+	answers := bufio.NewScanner(in)
+
+	for n, it := range items {
+		fmt.Fprintf(out, "[%d/%d] %s is unreachable\n", n+1, len(items), it.entry.describe())
+		fmt.Fprintf(out, "      history: %s\n", formatHistory(it.history))
+
+		d, err := askDecision(answers, out)
+		if err != nil {
+			return nil, err
+		}
+		decisions[it.idx] = d
+	}
+
+	var accepted, skipped, kept int
+	for _, d := range decisions {
+		switch d {
+		case reviewAccept:
+			accepted++
+		case reviewSkip:
+			skipped++
+		case reviewKeep:
+			kept++
+		}
+	}
+	fmt.Fprintf(out, "review: %d to disable, %d skipped, %d kept always\n", accepted, skipped, kept)
+
+	return decisions, nil
+}
+
+// askDecision prompts until it reads a valid answer.
+func askDecision(answers *bufio.Scanner, out io.Writer) (reviewDecision, error) {
+	for {
+		fmt.Fprint(out, "disable it? [a]ccept, [s]kip, [k]eep always, [q]uit (default s): ")
+		if !answers.Scan() {
+			fmt.Fprintln(out)
+			return 0, errReviewAborted
+		}
+
+		switch strings.ToLower(strings.TrimSpace(answers.Text())) {
+		case "a", "accept":
+			return reviewAccept, nil
+		case "", "s", "skip":
+			return reviewSkip, nil
+		case "k", "keep":
+			return reviewKeep, nil
+		case "q", "quit":
+			return 0, errReviewAborted
+		}
+		fmt.Fprintln(out, "please answer a, s, k or q")
+	}
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatHistory(t *testing.T) {
+	at := time.Date(2026, 10, 18, 17, 40, 0, 0, time.Local)
+	got := formatHistory([]probeSample{
+		{Status: "down", RTT: 2 * time.Second, Checked: at.Add(time.Hour)},
+		{Status: "up", RTT: 3210 * time.Microsecond, Checked: at},
+	})
+	want := "down 2026-10-18 18:40, up 3.2ms 2026-10-18 17:40"
+	if got != want {
+		t.Fatalf("formatHistory = %q, want %q", got, want)
+	}
+}
+
+func reviewFixture() []reviewItem {
+	return []reviewItem{
+		{idx: 1, entry: stateChange{Name: "web01", Type: "A", Zone: "example.lan.", IP: "10.0.1.5"}, history: []probeSample{{Status: "down"}}},
+		{idx: 3, entry: stateChange{Name: "ns1", IP: "10.0.0.53"}, history: []probeSample{{Status: "down"}}},
+		{idx: 4, entry: stateChange{Name: "10.0.1.7", IP: "10.0.1.7"}, history: []probeSample{{Status: "down"}}},
+	}
+}
+
+func TestReviewUnreachable(t *testing.T) {
+	var out bytes.Buffer
+	decisions, err := reviewUnreachable(strings.NewReader("maybe\nA\n\nkeep\n"), &out, reviewFixture())
+	if err != nil {
+		t.Fatalf("reviewUnreachable returned error: %v", err)
+	}
+
+	want := map[int]reviewDecision{1: reviewAccept, 3: reviewSkip, 4: reviewKeep}
+	for idx, d := range want {
+		if decisions[idx] != d {
+			t.Errorf("decision for result %d = %d, want %d", idx, decisions[idx], d)
+		}
+	}
+
+	for _, line := range []string{
+		"[1/3] web01 A example.lan. 10.0.1.5 is unreachable",
+		"[2/3] ns1 10.0.0.53 is unreachable",
+		"[3/3] 10.0.1.7 is unreachable",
+		"please answer a, s, k or q",
+		"review: 1 to disable, 1 skipped, 1 kept always",
+	} {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("review output is missing %q:\n%s", line, out.String())
+		}
+	}
+}
+
+func TestReviewUnreachable_Abort(t *testing.T) {
+	for _, answers := range []string{"a\nq\n", "a\n"} {
+		_, err := reviewUnreachable(strings.NewReader(answers), &bytes.Buffer{}, reviewFixture())
+		if !errors.Is(err, errReviewAborted) {
+			t.Errorf("reviewUnreachable(%q) error = %v, want errReviewAborted", answers, err)
+		}
+	}
+}
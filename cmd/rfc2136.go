@@ -0,0 +1,204 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// tsigConfig holds the TSIG key used to authenticate zone transfers and updates.
+type tsigConfig struct {
+	Name      string
+	Secret    string
+	Algorithm string
+}
+
+// enabled reports whether a TSIG key has been configured.
+func (t tsigConfig) enabled() bool {
+	return t.Name != "" && t.Secret != ""
+}
+
+// secrets returns the key map in the form expected by miekg/dns clients and servers.
+func (t tsigConfig) secrets() map[string]string {
+	if !t.enabled() {
+		return nil
+	}
+	return map[string]string{dns.Fqdn(t.Name): t.Secret}
+}
+
+// sign attaches a TSIG record to m when a key is configured.
+func (t tsigConfig) sign(m *dns.Msg) {
+	if !t.enabled() {
+		return
+	}
+	algo := t.Algorithm
+	if algo == "" {
+		algo = dns.HmacSHA256
+	}
+	m.SetTsig(dns.Fqdn(t.Name), dns.Fqdn(algo), 300, time.Now().Unix())
+}
+
+// rfc2136Client talks to an authoritative server using AXFR (or IXFR) for reads and
+// RFC 2136 dynamic updates for writes.
+type rfc2136Client struct {
+	Server  string
+	TSIG    tsigConfig
+	Timeout time.Duration
+	// IXFR requests the zone with IXFR first and falls back to AXFR when the
+	// server refuses it or answers with an incremental transfer.
+	IXFR bool
+}
+
+// errIncrementalIXFR is returned when an IXFR response contains deltas rather than the full zone.
+var errIncrementalIXFR = errors.New("server answered with an incremental IXFR")
+
+// serverAddr returns the server address with the default DNS port added if missing.
+func (c *rfc2136Client) serverAddr() string {
+	if _, _, err := net.SplitHostPort(c.Server); err == nil {
+		return c.Server
+	}
+	return net.JoinHostPort(c.Server, "53")
+}
+
+// transferZone fetches every record in zone, preferring IXFR when enabled.
+func (c *rfc2136Client) transferZone(zone string) ([]dns.RR, error) {
+	if c.IXFR {
+		rrs, err := c.transfer(zone, dns.TypeIXFR)
+		if err == nil {
+			return rrs, nil
+		}
+	}
+	return c.transfer(zone, dns.TypeAXFR)
+}
+
+// transfer performs a single AXFR or IXFR. IXFR is sent with serial 0 so that a
+// server supporting it answers with the complete zone.
+func (c *rfc2136Client) transfer(zone string, qtype uint16) ([]dns.RR, error) {
+	kind := dns.TypeToString[qtype]
+
+	m := new(dns.Msg)
+	if qtype == dns.TypeIXFR {
+		m.SetIxfr(dns.Fqdn(zone), 0, ".", ".")
+	} else {
+		m.SetAxfr(dns.Fqdn(zone))
+	}
+	c.TSIG.sign(m)
+
+	t := &dns.Transfer{
+		DialTimeout:  c.Timeout,
+		ReadTimeout:  c.Timeout,
+		WriteTimeout: c.Timeout,
+		TsigSecret:   c.TSIG.secrets(),
+	}
+
+	env, err := t.In(m, c.serverAddr())
+	if err != nil {
+		return nil, fmt.Errorf("%s %s from %s: %w", kind, zone, c.Server, err)
+	}
+
+	var rrs []dns.RR
+	for e := range env {
+		if e.Error != nil {
+			return nil, fmt.Errorf("%s %s from %s: %w", kind, zone, c.Server, e.Error)
+		}
+		rrs = append(rrs, e.RR...)
+	}
+
+	if qtype == dns.TypeIXFR && len(rrs) > 2 && rrs[1].Header().Rrtype == dns.TypeSOA {
+		return nil, fmt.Errorf("%s %s from %s: %w", kind, zone, c.Server, errIncrementalIXFR)
+	}
+
+	return rrs, nil
+}
+
+// ListRecords returns the managed records currently served for zone.
+func (c *rfc2136Client) ListRecords(zone string) ([]resourceRecord, error) {
+	rrs, err := c.transferZone(zone)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	var out []resourceRecord
+	for _, rr := range rrs {
+		r := resourceFromRR(rr)
+		// AXFR repeats the SOA at the end; de-duplicate defensively.
+		if seen[r.key()] {
+			continue
+		}
+		seen[r.key()] = true
+		out = append(out, r)
+	}
+
+	return managedRecords(out, dns.Fqdn(zone)), nil
+}
+
+// ApplyChanges sends a single RFC 2136 UPDATE message applying all changes to zone.
+func (c *rfc2136Client) ApplyChanges(zone string, changes []recordChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	m := new(dns.Msg)
+	m.SetUpdate(dns.Fqdn(zone))
+
+	var removes, inserts []dns.RR
+	for _, ch := range changes {
+		if ch.Action == changeDelete || ch.Action == changeUpdate {
+			rr, err := dns.NewRR(ch.Old.String())
+			if err != nil {
+				return err
+			}
+			removes = append(removes, rr)
+		}
+		if ch.Action == changeCreate || ch.Action == changeUpdate {
+			rr, err := dns.NewRR(ch.New.String())
+			if err != nil {
+				return err
+			}
+			inserts = append(inserts, rr)
+		}
+	}
+
+	if len(removes) > 0 {
+		m.Remove(removes)
+	}
+	if len(inserts) > 0 {
+		m.Insert(inserts)
+	}
+	c.TSIG.sign(m)
+
+	client := &dns.Client{
+		Net:        "tcp",
+		Timeout:    c.Timeout,
+		TsigSecret: c.TSIG.secrets(),
+	}
+
+	resp, _, err := client.Exchange(m, c.serverAddr())
+	if err != nil {
+		return fmt.Errorf("update %s on %s: %w", zone, c.Server, err)
+	}
+	if resp.Rcode != dns.RcodeSuccess {
+		return fmt.Errorf("update %s on %s: server returned %s", zone, c.Server, dns.RcodeToString[resp.Rcode])
+	}
+
+	return nil
+}
+
+// CreateRecord adds a single record with an RFC 2136 update.
+func (c *rfc2136Client) CreateRecord(zone string, rec resourceRecord) error {
+	return singleChange(c, zone, recordChange{Action: changeCreate, New: rec})
+}
+
+// UpdateRecord replaces old with rec in a single RFC 2136 update.
+func (c *rfc2136Client) UpdateRecord(zone string, old, rec resourceRecord) error {
+	return singleChange(c, zone, recordChange{Action: changeUpdate, Old: old, New: rec})
+}
+
+// DeleteRecord removes a single record with an RFC 2136 update.
+func (c *rfc2136Client) DeleteRecord(zone string, rec resourceRecord) error {
+	return singleChange(c, zone, recordChange{Action: changeDelete, Old: rec})
+}
@@ -0,0 +1,244 @@
+package cmd
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const testTSIGSecret = "c2VjcmV0LXNlY3JldC1zZWNyZXQtc2VjcmV0IQ=="
+
+// fakeZoneServer is an in-process authoritative server that answers AXFR and
+// applies RFC 2136 updates to an in-memory zone.
+type fakeZoneServer struct {
+	mu      sync.Mutex
+	zone    string
+	serial  uint32
+	records []dns.RR
+	tsig    tsigConfig
+	addr    string
+	updates int
+
+	// refuseAXFR makes the server answer AXFR with REFUSED, as servers that only allow IXFR do.
+	refuseAXFR bool
+	transfers  []uint16
+}
+
+func startFakeZoneServer(t *testing.T, zone string, tsig tsigConfig, rrs ...string) *fakeZoneServer {
+	t.Helper()
+
+	fs := &fakeZoneServer{zone: dns.Fqdn(zone), serial: 1, tsig: tsig}
+	for _, s := range rrs {
+		rr, err := dns.NewRR(s)
+		if err != nil {
+			t.Fatalf("invalid fixture record %q: %v", s, err)
+		}
+		fs.records = append(fs.records, rr)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	fs.addr = l.Addr().String()
+
+	started := make(chan struct{})
+	srv := &dns.Server{
+		Listener:          l,
+		Handler:           fs,
+		TsigSecret:        tsig.secrets(),
+		NotifyStartedFunc: func() { close(started) },
+		MsgAcceptFunc:     func(dns.Header) dns.MsgAcceptAction { return dns.MsgAccept },
+	}
+	go srv.ActivateAndServe()
+	<-started
+	t.Cleanup(func() { srv.Shutdown() })
+
+	return fs
+}
+
+func (fs *fakeZoneServer) soa() dns.RR {
+	return &dns.SOA{
+		Hdr:     dns.RR_Header{Name: fs.zone, Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: 3600},
+		Ns:      "ns1." + fs.zone,
+		Mbox:    "hostmaster." + fs.zone,
+		Serial:  fs.serial,
+		Refresh: 3600,
+		Retry:   600,
+		Expire:  86400,
+		Minttl:  300,
+	}
+}
+
+func (fs *fakeZoneServer) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	m := new(dns.Msg)
+	m.SetReply(r)
+
+	if fs.tsig.enabled() && (r.IsTsig() == nil || w.TsigStatus() != nil) {
+		m.Rcode = dns.RcodeNotAuth
+		w.WriteMsg(m)
+		return
+	}
+
+	switch {
+	case r.Opcode == dns.OpcodeUpdate:
+		fs.applyUpdate(r.Ns)
+	case len(r.Question) == 1 && r.Question[0].Qtype == dns.TypeAXFR && fs.refuseAXFR:
+		fs.transfers = append(fs.transfers, dns.TypeAXFR)
+		m.Rcode = dns.RcodeRefused
+	case len(r.Question) == 1 && (r.Question[0].Qtype == dns.TypeAXFR || r.Question[0].Qtype == dns.TypeIXFR):
+		fs.transfers = append(fs.transfers, r.Question[0].Qtype)
+		m.Answer = append([]dns.RR{fs.soa()}, fs.records...)
+		m.Answer = append(m.Answer, fs.soa())
+	default:
+		m.Rcode = dns.RcodeNotImplemented
+	}
+
+	if r.IsTsig() != nil {
+		m.SetTsig(dns.Fqdn(fs.tsig.Name), dns.HmacSHA256, 300, time.Now().Unix())
+	}
+	w.WriteMsg(m)
+}
+
+func (fs *fakeZoneServer) applyUpdate(ns []dns.RR) {
+	fs.updates++
+	fs.serial++
+
+	for _, rr := range ns {
+		switch rr.Header().Class {
+		case dns.ClassNONE:
+			key := resourceFromRR(rr).key()
+			kept := fs.records[:0]
+			for _, have := range fs.records {
+				if resourceFromRR(have).key() != key {
+					kept = append(kept, have)
+				}
+			}
+			fs.records = kept
+		default:
+			fs.records = append(fs.records, rr)
+		}
+	}
+}
+
+func (fs *fakeZoneServer) snapshot() map[string]uint32 {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	out := map[string]uint32{}
+	for _, rr := range fs.records {
+		r := resourceFromRR(rr)
+		out[r.key()] = r.TTL
+	}
+	return out
+}
+
+func TestRFC2136Client_ListRecordsFiltersUnmanaged(t *testing.T) {
+	fs := startFakeZoneServer(t, "example.lan.", tsigConfig{},
+		"example.lan. 3600 IN NS ns1.example.lan.",
+		"web01.example.lan. 3600 IN A 10.0.1.5",
+		"sub.example.lan. 3600 IN NS ns.sub.example.lan.",
+	)
+
+	c := &rfc2136Client{Server: fs.addr, Timeout: 2 * time.Second}
+	records, err := c.ListRecords("example.lan")
+	if err != nil {
+		t.Fatalf("ListRecords returned error: %v", err)
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("ListRecords returned %d records, want 2: %v", len(records), records)
+	}
+	for _, r := range records {
+		if r.Type == "SOA" || (r.Type == "NS" && r.Name == "example.lan.") {
+			t.Fatalf("ListRecords returned unmanaged record %s", r)
+		}
+	}
+}
+
+func TestRFC2136Client_UpdateWithTSIG(t *testing.T) {
+	tsig := tsigConfig{Name: "dnsctl", Secret: testTSIGSecret, Algorithm: "hmac-sha256"}
+	fs := startFakeZoneServer(t, "example.lan.", tsig,
+		"old.example.lan. 3600 IN A 10.0.1.9",
+		"web01.example.lan. 300 IN A 10.0.1.5",
+	)
+
+	c := &rfc2136Client{Server: fs.addr, TSIG: tsig, Timeout: 2 * time.Second}
+	changes := []recordChange{
+		{Action: changeDelete, Old: resourceRecord{Name: "old.example.lan.", Type: "A", TTL: 3600, Data: "10.0.1.9"}},
+		{
+			Action: changeUpdate,
+			Old:    resourceRecord{Name: "web01.example.lan.", Type: "A", TTL: 300, Data: "10.0.1.5"},
+			New:    resourceRecord{Name: "web01.example.lan.", Type: "A", TTL: 3600, Data: "10.0.1.5"},
+		},
+		{Action: changeCreate, New: resourceRecord{Name: "web02.example.lan.", Type: "A", TTL: 3600, Data: "10.0.1.6"}},
+	}
+
+	if err := c.ApplyChanges("example.lan.", changes); err != nil {
+		t.Fatalf("ApplyChanges returned error: %v", err)
+	}
+
+	got := fs.snapshot()
+	want := map[string]uint32{
+		"web01.example.lan. A 10.0.1.5": 3600,
+		"web02.example.lan. A 10.0.1.6": 3600,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("zone after update = %v, want %v", got, want)
+	}
+	for k, ttl := range want {
+		if got[k] != ttl {
+			t.Fatalf("zone after update = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestRFC2136Client_UpdateRejectedWithoutTSIG(t *testing.T) {
+	tsig := tsigConfig{Name: "dnsctl", Secret: testTSIGSecret}
+	fs := startFakeZoneServer(t, "example.lan.", tsig)
+
+	c := &rfc2136Client{Server: fs.addr, Timeout: 2 * time.Second}
+	err := c.ApplyChanges("example.lan.", []recordChange{
+		{Action: changeCreate, New: resourceRecord{Name: "web02.example.lan.", Type: "A", TTL: 3600, Data: "10.0.1.6"}},
+	})
+	if err == nil {
+		t.Fatalf("ApplyChanges without TSIG returned nil, want NOTAUTH error")
+	}
+}
+
+func TestRFC2136Client_UpdateNoChanges(t *testing.T) {
+	c := &rfc2136Client{Server: "192.0.2.1:53", Timeout: time.Millisecond}
+	if err := c.ApplyChanges("example.lan.", nil); err != nil {
+		t.Fatalf("ApplyChanges with no changes returned error: %v", err)
+	}
+}
+
+func TestRFC2136Client_IXFRFallsBackToAXFR(t *testing.T) {
+	fs := startFakeZoneServer(t, "example.lan.", tsigConfig{},
+		"web01.example.lan. 3600 IN A 10.0.1.5",
+	)
+	fs.refuseAXFR = true
+
+	c := &rfc2136Client{Server: fs.addr, Timeout: 2 * time.Second, IXFR: true}
+	records, err := c.ListRecords("example.lan.")
+	if err != nil {
+		t.Fatalf("ListRecords with IXFR returned error: %v", err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("ListRecords returned %d records, want 1", len(records))
+	}
+	if len(fs.transfers) != 1 || fs.transfers[0] != dns.TypeIXFR {
+		t.Fatalf("transfers = %v, want a single IXFR", fs.transfers)
+	}
+
+	c.IXFR = false
+	if _, err := c.ListRecords("example.lan."); err == nil {
+		t.Fatalf("ListRecords with refused AXFR returned nil, want error")
+	}
+}
@@ -7,23 +7,347 @@ import (
 )
 
 var (
+	// global flags
+	profileName string
+	logLevel    string
+	logFormat   string
+
 	// clean-zones flags
-	file    string
-	timeout time.Duration
-	workers int
-	dryRun  bool
+	file         string
+	timeout      time.Duration
+	workers      int
+	dryRun       bool
+	allowPartial bool
+	maxDuration  time.Duration
+	probeRate    float64
+	perSubnet    int
+	quiet        bool
+	cacheTTL     time.Duration
+	noCache      bool
+	notifyHooks  []string
+	notifySlack  []string
+	notifyCmd    string
+	failOn       []string
+	interactive  bool
+	gitCommit    bool
+	gitBranch    string
+	checkMode    string
+	arpRequest   bool
+
+	// plan/apply flags
+	provider      string
+	apiURL        string
+	apiKey        string
+	serverID      string
+	server        string
+	zones         []string
+	tsigName      string
+	tsigSecret    string
+	tsigAlgorithm string
+	dnsTimeout    time.Duration
+
+	// export flags
+	exportFormat string
+	exportOutput string
+
+	// drift/pull flags
+	servers []string
+	useIXFR bool
+
+	// monitor flags
+	monitorInterval time.Duration
+	monitorListen   string
+
+	// record flags
+	recordHost  string
+	recordType  string
+	recordZone  string
+	recordValue string
+	recordTTL   uint32
+	recordPTR   bool
+
+	// ipam flags
+	ipamCIDR          string
+	ipamCount         int
+	ipamReserve       []string
+	ipamReuseDisabled bool
+	ipamSubnets       []string
+	ipamFormat        string
+
+	// query flags
+	queryHost   string
+	queryIP     string
+	queryCIDR   string
+	queryZone   string
+	queryType   string
+	queryState  string
+	queryFormat string
 )
 
 var rootCmd = &cobra.Command{
 	Use:   "dnsctl",
 	Short: "DNS zone management tool",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := applyConfig(cmd); err != nil {
+			return err
+		}
+		return setupLogging(logLevel, logFormat)
+	},
 }
 
 var cleanZonesCmd = &cobra.Command{
 	Use:   "clean-zones",
 	Short: "Clean and validate DNS zones",
+	Long: `Probe every nameserver and A/AAAA record of the inventory and comment out the ones
+that do not answer with a "# DISABLED: unreachable" marker. Entries carrying that marker
+from an earlier run are re-enabled once they answer again; DISABLED markers written by
+hand are never lifted. Missing PTR records are added for A records.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// findings and interruptions are reported through the exit code, not with usage
+		cmd.SilenceUsage = true
+
+		return runCleanZones(cleanOptions{
+			File:         file,
+			Timeout:      timeout,
+			Workers:      workers,
+			DryRun:       dryRun,
+			AllowPartial: allowPartial,
+			MaxDuration:  maxDuration,
+			Limits: probeLimits{
+				Rate:      probeRate,
+				PerSubnet: perSubnet,
+			},
+			Liveness: livenessOptions{
+				Check:      checkMode,
+				ARPRequest: arpRequest,
+			},
+			Quiet:       quiet,
+			CacheTTL:    cacheTTL,
+			NoCache:     noCache,
+			FailOn:      failOn,
+			Interactive: interactive,
+			Git: gitOptions{
+				Commit: gitCommit,
+				Branch: gitBranch,
+			},
+			Notify: notifyOptions{
+				Webhooks: notifyHooks,
+				Slack:    notifySlack,
+				Command:  notifyCmd,
+				Timeout:  10 * time.Second,
+			},
+		})
+	},
+}
+
+var planCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "Show the changes apply would make to a DNS server",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runApply(applyFlags(true), cmd.OutOrStdout())
+	},
+}
+
+var applyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Push inventory records to a DNS server",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runApply(applyFlags(false), cmd.OutOrStdout())
+	},
+}
+
+// applyFlags collects the shared plan/apply flag values into applyOptions.
+func applyFlags(planOnly bool) applyOptions {
+	return applyOptions{
+		File:     file,
+		Provider: provider,
+		Server:   server,
+		Zones:    zones,
+		TSIG: tsigConfig{
+			Name:      tsigName,
+			Secret:    tsigSecret,
+			Algorithm: tsigAlgorithm,
+		},
+		API: apiConfig{
+			URL:      apiURL,
+			Key:      apiKey,
+			ServerID: serverID,
+		},
+		Timeout:  dnsTimeout,
+		PlanOnly: planOnly,
+	}
+}
+
+var driftCmd = &cobra.Command{
+	Use:   "drift",
+	Short: "Compare the inventory with the zones served by its nameservers",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runDrift(driftFlags(false), cmd.OutOrStdout())
+	},
+}
+
+var pullCmd = &cobra.Command{
+	Use:   "pull",
+	Short: "Add records served by the nameservers but missing from the inventory",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runDrift(driftFlags(true), cmd.OutOrStdout())
+	},
+}
+
+// driftFlags collects the shared drift/pull flag values into driftOptions.
+func driftFlags(write bool) driftOptions {
+	return driftOptions{
+		File:    file,
+		Servers: servers,
+		Zones:   zones,
+		TSIG: tsigConfig{
+			Name:      tsigName,
+			Secret:    tsigSecret,
+			Algorithm: tsigAlgorithm,
+		},
+		Timeout: dnsTimeout,
+		IXFR:    useIXFR,
+		Write:   write,
+	}
+}
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Render inventory records as dnsmasq, unbound or hosts configuration",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runExport(file, exportFormat, exportOutput, cmd.OutOrStdout())
+	},
+}
+
+var monitorCmd = &cobra.Command{
+	Use:   "monitor",
+	Short: "Continuously probe inventory hosts and expose Prometheus metrics",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runMonitor(cmd.Context(), monitorOptions{
+			File:     file,
+			Interval: monitorInterval,
+			Timeout:  timeout,
+			Workers:  workers,
+			Limits: probeLimits{
+				Rate:      probeRate,
+				PerSubnet: perSubnet,
+			},
+			Liveness: livenessOptions{
+				Check:      checkMode,
+				ARPRequest: arpRequest,
+			},
+			Listen: monitorListen,
+		})
+	},
+}
+
+var queryCmd = &cobra.Command{
+	Use:   "query",
+	Short: "Search inventory records and nameservers",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return runCleanZones(file, timeout, workers, dryRun)
+		return runQuery(queryOptions{
+			File:   file,
+			Host:   queryHost,
+			IP:     queryIP,
+			CIDR:   queryCIDR,
+			Zone:   queryZone,
+			Type:   queryType,
+			State:  queryState,
+			Format: queryFormat,
+		}, cmd.OutOrStdout())
+	},
+}
+
+var recordCmd = &cobra.Command{
+	Use:   "record",
+	Short: "Add, remove or change dns_records entries",
+}
+
+var recordAddCmd = &cobra.Command{
+	Use:   "add",
+	Short: "Add a record to the inventory",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runRecordAdd(recordFlags(), cmd.OutOrStdout())
+	},
+}
+
+var recordRmCmd = &cobra.Command{
+	Use:   "rm",
+	Short: "Remove a record from the inventory",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runRecordRemove(recordFlags(), cmd.OutOrStdout())
+	},
+}
+
+var recordSetCmd = &cobra.Command{
+	Use:   "set",
+	Short: "Change the value or TTL of a record in the inventory",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runRecordSet(recordFlags(), cmd.OutOrStdout())
+	},
+}
+
+// recordFlags collects the shared record add/rm/set flag values into recordOptions.
+func recordFlags() recordOptions {
+	return recordOptions{
+		File:  file,
+		Host:  recordHost,
+		Type:  recordType,
+		Zone:  recordZone,
+		Value: recordValue,
+		TTL:   recordTTL,
+		PTR:   recordPTR,
+	}
+}
+
+var ipamCmd = &cobra.Command{
+	Use:   "ipam",
+	Short: "Manage IP address allocation from the inventory",
+}
+
+var ipamNextCmd = &cobra.Command{
+	Use:   "next",
+	Short: "Print the next free address of a subnet, or allocate it to a new record",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runIPAMNext(ipamOptions{
+			File:          file,
+			CIDR:          ipamCIDR,
+			Count:         ipamCount,
+			Reserve:       ipamReserve,
+			ReuseDisabled: ipamReuseDisabled,
+			Record: recordOptions{
+				File: file,
+				Host: recordHost,
+				Zone: recordZone,
+				TTL:  recordTTL,
+				PTR:  recordPTR,
+			},
+		}, cmd.OutOrStdout())
+	},
+}
+
+var ipamReportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Show subnet utilization, IP conflicts and missing reverse zones",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runIPAMReport(ipamReportOptions{
+			File:    file,
+			Subnets: ipamSubnets,
+			Format:  ipamFormat,
+		}, cmd.OutOrStdout())
+	},
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect dnsctl configuration",
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show [command...]",
+	Short: "Show the effective settings of each command and where they come from",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runConfigShow(args, cmd.OutOrStdout())
 	},
 }
 
@@ -50,14 +374,127 @@ var zshCompletionCmd = &cobra.Command{
 }
 
 func init() {
-	cleanZonesCmd.Flags().StringVar(&file, "file", "", "YAML file to process (required)")
+	cleanZonesCmd.Flags().StringVar(&file, "file", "", "YAML file to process, or - for stdin (required)")
 	cleanZonesCmd.Flags().DurationVar(&timeout, "timeout", 2*time.Second, "Ping timeout")
 	cleanZonesCmd.Flags().IntVar(&workers, "workers", 8, "Number of parallel ping workers")
 	cleanZonesCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Do not modify output")
+	cleanZonesCmd.Flags().BoolVar(&allowPartial, "allow-partial", false, "Write output even if interrupted before all hosts were probed")
+	cleanZonesCmd.Flags().DurationVar(&maxDuration, "max-duration", 0, "Stop probing after this long; unprobed hosts count as interrupted (0 = no limit)")
+	cleanZonesCmd.Flags().Float64Var(&probeRate, "rate", 0, "Maximum probes per second across all workers (0 = unlimited)")
+	cleanZonesCmd.Flags().IntVar(&perSubnet, "per-subnet", 0, "Maximum concurrent probes per /24 or /64 subnet (0 = unlimited)")
+	cleanZonesCmd.Flags().StringVar(&checkMode, "check", checkICMP, "Liveness check: icmp, arp (neighbor table for on-link hosts) or auto (icmp, then neighbor table)")
+	cleanZonesCmd.Flags().BoolVar(&arpRequest, "arp-request", false, "Send ARP requests for on-link IPv4 hosts the neighbor table has not confirmed (arp, auto)")
+	cleanZonesCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Do not report probe progress")
+	cleanZonesCmd.Flags().DurationVar(&cacheTTL, "cache-ttl", 10*time.Minute, "Reuse probe results younger than this from earlier runs (0 disables the cache)")
+	cleanZonesCmd.Flags().BoolVar(&noCache, "no-cache", false, "Probe every host even if a cached result exists")
+	cleanZonesCmd.Flags().StringSliceVar(&notifyHooks, "notify-webhook", nil, "POST state changes as JSON to this URL (repeatable)")
+	cleanZonesCmd.Flags().StringSliceVar(&notifySlack, "notify-slack", nil, "Post state changes to this Slack incoming webhook (repeatable)")
+	cleanZonesCmd.Flags().StringVar(&notifyCmd, "notify-command", "", "Run this shell command on state changes, with the event as JSON on stdin and DNSCTL_* variables set")
+	cleanZonesCmd.Flags().StringSliceVar(&failOn, "fail-on", defaultFailOn, "Exit with status 2 when these are found: unreachable, changes, nameservers; none always exits 0 after writing output")
+	cleanZonesCmd.Flags().BoolVarP(&interactive, "interactive", "i", false, "Review each unreachable entry (accept, skip or keep always) before anything is written")
+	cleanZonesCmd.Flags().BoolVar(&gitCommit, "git-commit", false, "Write the result back to --file and commit it to the local git repository")
+	cleanZonesCmd.Flags().StringVar(&gitBranch, "git-branch", "", "Create this branch for the commit (implies --git-commit)")
 	cleanZonesCmd.MarkFlagRequired("file")
 
+	for _, c := range []*cobra.Command{planCmd, applyCmd} {
+		c.Flags().StringVar(&file, "file", "", "YAML file to process, or - for stdin (required)")
+		c.Flags().StringVar(&provider, "provider", "rfc2136", "Backend to sync with: rfc2136, powerdns or cloudflare")
+		c.Flags().StringVar(&server, "server", "", "DNS server address, host[:port] (rfc2136)")
+		c.Flags().StringSliceVar(&zones, "zone", nil, "Zone to reconcile (repeatable, default: all zones in the file)")
+		c.Flags().StringVar(&tsigName, "tsig-name", "", "TSIG key name")
+		c.Flags().StringVar(&tsigSecret, "tsig-secret", "", "TSIG key secret (base64)")
+		c.Flags().StringVar(&tsigAlgorithm, "tsig-algorithm", "hmac-sha256", "TSIG algorithm")
+		c.Flags().StringVar(&apiURL, "api-url", "", "Provider API base URL, e.g. http://pdns:8081 (powerdns, cloudflare)")
+		c.Flags().StringVar(&apiKey, "api-key", "", "Provider API key or token (powerdns, cloudflare)")
+		c.Flags().StringVar(&serverID, "server-id", "localhost", "PowerDNS server id (powerdns)")
+		c.Flags().DurationVar(&dnsTimeout, "timeout", 5*time.Second, "DNS transfer and update timeout")
+		c.MarkFlagRequired("file")
+	}
+
+	for _, c := range []*cobra.Command{driftCmd, pullCmd} {
+		c.Flags().StringVar(&file, "file", "", "YAML file to process, or - for stdin (required)")
+		c.Flags().StringSliceVar(&servers, "server", nil, "Nameserver to query, host[:port] (repeatable, default: nameservers from the file)")
+		c.Flags().StringSliceVar(&zones, "zone", nil, "Zone to transfer (repeatable, default: all zones in the file)")
+		c.Flags().StringVar(&tsigName, "tsig-name", "", "TSIG key name")
+		c.Flags().StringVar(&tsigSecret, "tsig-secret", "", "TSIG key secret (base64)")
+		c.Flags().StringVar(&tsigAlgorithm, "tsig-algorithm", "hmac-sha256", "TSIG algorithm")
+		c.Flags().DurationVar(&dnsTimeout, "timeout", 5*time.Second, "DNS transfer timeout")
+		c.Flags().BoolVar(&useIXFR, "ixfr", false, "Try IXFR before falling back to AXFR")
+		c.MarkFlagRequired("file")
+	}
+
+	exportCmd.Flags().StringVar(&file, "file", "", "YAML file to process, or - for stdin (required)")
+	exportCmd.Flags().StringVar(&exportFormat, "format", "hosts", "Output format: dnsmasq, unbound or hosts")
+	exportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "Write to this file instead of stdout")
+	exportCmd.MarkFlagRequired("file")
+
+	monitorCmd.Flags().StringVar(&file, "file", "", "YAML file to monitor (required)")
+	monitorCmd.Flags().DurationVar(&monitorInterval, "interval", time.Minute, "Time between probe cycles")
+	monitorCmd.Flags().StringVar(&monitorListen, "listen", ":9553", "Address to serve /metrics on")
+	monitorCmd.Flags().DurationVar(&timeout, "timeout", 2*time.Second, "Ping timeout")
+	monitorCmd.Flags().IntVar(&workers, "workers", 8, "Number of parallel ping workers")
+	monitorCmd.Flags().Float64Var(&probeRate, "rate", 0, "Maximum probes per second across all workers (0 = unlimited)")
+	monitorCmd.Flags().IntVar(&perSubnet, "per-subnet", 0, "Maximum concurrent probes per /24 or /64 subnet (0 = unlimited)")
+	monitorCmd.Flags().StringVar(&checkMode, "check", checkICMP, "Liveness check: icmp, arp (neighbor table for on-link hosts) or auto (icmp, then neighbor table)")
+	monitorCmd.Flags().BoolVar(&arpRequest, "arp-request", false, "Send ARP requests for on-link IPv4 hosts the neighbor table has not confirmed (arp, auto)")
+	monitorCmd.MarkFlagRequired("file")
+
+	queryCmd.Flags().StringVar(&file, "file", "", "YAML file to search, or - for stdin (required)")
+	queryCmd.Flags().StringVar(&queryHost, "host", "", "Host or nameserver name, glob patterns allowed (e.g. 'web*')")
+	queryCmd.Flags().StringVar(&queryIP, "ip", "", "Address of A, AAAA and PTR records and nameservers")
+	queryCmd.Flags().StringVar(&queryCIDR, "cidr", "", "Match addresses inside this network, e.g. 10.0.1.0/24")
+	queryCmd.Flags().StringVar(&queryZone, "zone", "", "Zone of the record")
+	queryCmd.Flags().StringVar(&queryType, "type", "", "Record type")
+	queryCmd.Flags().StringVar(&queryState, "state", "", "Entry state: active or disabled")
+	queryCmd.Flags().StringVar(&queryFormat, "format", "table", "Output format: table, json or csv")
+	queryCmd.MarkFlagRequired("file")
+
+	for _, c := range []*cobra.Command{recordAddCmd, recordRmCmd, recordSetCmd} {
+		c.Flags().StringVar(&file, "file", "", "YAML file to edit, or - for stdin (required)")
+		c.Flags().StringVar(&recordHost, "host", "", "Record host label, @ for the zone apex (required)")
+		c.Flags().StringVar(&recordType, "type", "", "Record type, e.g. A, AAAA, CNAME (required)")
+		c.Flags().StringVar(&recordZone, "zone", "", "Zone of the record (required)")
+		c.MarkFlagRequired("file")
+		c.MarkFlagRequired("host")
+		c.MarkFlagRequired("type")
+		c.MarkFlagRequired("zone")
+	}
+	recordAddCmd.Flags().StringVar(&recordValue, "value", "", "Record value (required)")
+	recordAddCmd.Flags().Uint32Var(&recordTTL, "ttl", 0, "Record TTL in seconds (default: no ttl key, 3600)")
+	recordAddCmd.Flags().BoolVar(&recordPTR, "ptr", false, "Also add the PTR record of an A or AAAA record")
+	recordAddCmd.MarkFlagRequired("value")
+	recordRmCmd.Flags().StringVar(&recordValue, "value", "", "Value of the record to remove, when several match")
+	recordRmCmd.Flags().BoolVar(&recordPTR, "ptr", false, "Also remove the PTR record pointing at the host")
+	recordSetCmd.Flags().StringVar(&recordValue, "value", "", "New record value")
+	recordSetCmd.Flags().Uint32Var(&recordTTL, "ttl", 0, "New record TTL in seconds")
+	recordSetCmd.Flags().BoolVar(&recordPTR, "ptr", false, "Also move the PTR record to the new address")
+
+	ipamNextCmd.Flags().StringVar(&file, "file", "", "YAML file to allocate from, or - for stdin (required)")
+	ipamNextCmd.Flags().StringVar(&ipamCIDR, "cidr", "", "Subnet to allocate from, e.g. 10.0.1.0/24 (required)")
+	ipamNextCmd.Flags().IntVar(&ipamCount, "count", 1, "Number of free addresses to print")
+	ipamNextCmd.Flags().StringSliceVar(&ipamReserve, "reserve", nil, "Never allocate these addresses, a-b ranges or prefixes (repeatable)")
+	ipamNextCmd.Flags().BoolVar(&ipamReuseDisabled, "reuse-disabled", false, "Treat addresses of disabled records and nameservers as free")
+	ipamNextCmd.Flags().StringVar(&recordHost, "host", "", "Add an A or AAAA record for this host with the first free address")
+	ipamNextCmd.Flags().StringVar(&recordZone, "zone", "", "Zone of the added record (required with --host)")
+	ipamNextCmd.Flags().Uint32Var(&recordTTL, "ttl", 0, "TTL of the added record in seconds")
+	ipamNextCmd.Flags().BoolVar(&recordPTR, "ptr", false, "Also add the PTR record of the added record")
+	ipamNextCmd.MarkFlagRequired("file")
+	ipamNextCmd.MarkFlagRequired("cidr")
+
+	ipamReportCmd.Flags().StringVar(&file, "file", "", "YAML file to report on, or - for stdin (required)")
+	ipamReportCmd.Flags().StringSliceVar(&ipamSubnets, "subnet", nil, "Declared subnet, in addition to the inventory's subnets section (repeatable)")
+	ipamReportCmd.Flags().StringVar(&ipamFormat, "format", "text", "Output format: text or json")
+	ipamReportCmd.MarkFlagRequired("file")
+
+	rootCmd.PersistentFlags().StringVar(&profileName, "profile", "", "Config profile to use (default from DNSCTL_PROFILE or the config files)")
+	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info", "Log level: debug, info, warn or error")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "text", "Log format: text or json")
+
+	recordCmd.AddCommand(recordAddCmd, recordRmCmd, recordSetCmd)
+	ipamCmd.AddCommand(ipamNextCmd, ipamReportCmd)
+	configCmd.AddCommand(configShowCmd)
 	completionCmd.AddCommand(bashCompletionCmd, zshCompletionCmd)
-	rootCmd.AddCommand(cleanZonesCmd, completionCmd)
+	rootCmd.AddCommand(cleanZonesCmd, planCmd, applyCmd, driftCmd, pullCmd, exportCmd, monitorCmd, queryCmd, recordCmd, ipamCmd, configCmd, completionCmd)
 }
 
 // Execute runs the root command.
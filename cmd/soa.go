@@ -0,0 +1,180 @@
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-yaml/ast"
+)
+
+// soaSection is the top-level YAML key holding one SOA block per zone.
+const soaSection = "soa"
+
+// Serial styles selectable per zone with serial_style.
+const (
+	// serialDate is YYYYMMDDnn: the UTC date of the change followed by a two-digit revision.
+	serialDate = "date"
+	// serialUnix is the Unix time of the change.
+	serialUnix = "unix"
+)
+
+// soaBlock is a flattened view of one entry of the soa section.
+type soaBlock struct {
+	Zone   string
+	Serial uint32
+	// Style is serialDate or serialUnix; serialDate when serial_style is missing.
+	Style string
+	Node  *ast.MappingNode
+}
+
+// collectSOA returns the SOA blocks of the inventory in file order.
+func collectSOA(root *ast.MappingNode) ([]soaBlock, error) {
+	seq, ok := mappingValue(root, soaSection).(*ast.SequenceNode)
+	if !ok {
+		return nil, nil
+	}
+
+	var blocks []soaBlock
+	seen := map[string]bool{}
+	for _, item := range seq.Values {
+		m, ok := item.(*ast.MappingNode)
+		if !ok {
+			continue
+		}
+
+		b := soaBlock{
+			Zone:  canonicalZone(stringValue(m, "zone")),
+			Style: strings.ToLower(stringValue(m, "serial_style")),
+			Node:  m,
+		}
+		if b.Zone == "" {
+			return nil, fmt.Errorf("%s entry without a zone", soaSection)
+		}
+		if seen[b.Zone] {
+			return nil, fmt.Errorf("%s: zone %s is listed twice", soaSection, b.Zone)
+		}
+		seen[b.Zone] = true
+
+		switch b.Style {
+		case "":
+			b.Style = serialDate
+		case serialDate, serialUnix:
+		default:
+			return nil, fmt.Errorf("%s %s: unknown serial_style %q (want %s or %s)", soaSection, b.Zone, b.Style, serialDate, serialUnix)
+		}
+		if s := stringValue(m, "serial"); s != "" {
+			serial, err := strconv.ParseUint(s, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: invalid serial %q", soaSection, b.Zone, s)
+			}
+			b.Serial = uint32(serial)
+		}
+		blocks = append(blocks, b)
+	}
+	return blocks, nil
+}
+
+// nextSerial returns the serial that follows old in style for a change made at now.
+// Serials only move forward: a date serial already at or past today's date is
+// incremented, as is a unix serial at or past now.
+func nextSerial(old uint32, style string, now time.Time) (uint32, error) {
+	var base uint64
+	switch style {
+	case serialDate:
+		y, m, d := now.UTC().Date()
+		base = uint64(y*10000+int(m)*100+d) * 100
+	case serialUnix:
+		base = uint64(now.Unix())
+	default:
+		return 0, fmt.Errorf("unknown serial style %q", style)
+	}
+
+	next := max(uint64(old)+1, base)
+	if next > math.MaxUint32 {
+		return 0, fmt.Errorf("serial %d cannot be incremented in %s style", old, style)
+	}
+	return uint32(next), nil
+}
+
+// zoneContents renders the active records of each zone in a canonical order, so that
+// two inventories can be compared zone by zone.
+func zoneContents(records []dnsRecord) map[string]string {
+	lines := map[string][]string{}
+	for _, r := range records {
+		if r.Disabled || r.Zone == "" {
+			continue
+		}
+		lines[r.Zone] = append(lines[r.Zone], fmt.Sprintf("%s %s %s %d", r.Name(), r.Type, r.Value, r.TTL))
+	}
+
+	contents := make(map[string]string, len(lines))
+	for zone, l := range lines {
+		slices.Sort(l)
+		contents[zone] = strings.Join(l, "\n")
+	}
+	return contents
+}
+
+// changedZones returns the zones whose active records differ between before and after, sorted.
+func changedZones(before, after []dnsRecord) []string {
+	old, cur := zoneContents(before), zoneContents(after)
+
+	var zones []string
+	for zone, c := range cur {
+		if old[zone] != c {
+			zones = append(zones, zone)
+		}
+	}
+	for zone := range old {
+		if _, ok := cur[zone]; !ok {
+			zones = append(zones, zone)
+		}
+	}
+	slices.Sort(zones)
+	return zones
+}
+
+// serialBump is the serial change of one zone.
+type serialBump struct {
+	Zone     string
+	Old, New uint32
+}
+
+// String returns the line used in reports, e.g. "example.lan. serial 2026101901 -> 2026101902".
+func (b serialBump) String() string {
+	return fmt.Sprintf("%s serial %d -> %d", b.Zone, b.Old, b.New)
+}
+
+// bumpSerials increments the serial of every zone in zones that has an SOA block.
+// Zones without one are left alone.
+func bumpSerials(root *ast.MappingNode, zones []string, now time.Time) ([]serialBump, error) {
+	if len(zones) == 0 {
+		return nil, nil
+	}
+
+	blocks, err := collectSOA(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var bumps []serialBump
+	for _, b := range blocks {
+		if !slices.Contains(zones, b.Zone) {
+			continue
+		}
+
+		serial, err := nextSerial(b.Serial, b.Style, now)
+		if err != nil {
+			return nil, fmt.Errorf("%s %s: %w", soaSection, b.Zone, err)
+		}
+		if err := setMappingValue(b.Node, "serial", strconv.FormatUint(uint64(serial), 10)); err != nil {
+			return nil, err
+		}
+		bumps = append(bumps, serialBump{Zone: b.Zone, Old: b.Serial, New: serial})
+	}
+	return bumps, nil
+}
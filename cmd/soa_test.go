@@ -0,0 +1,194 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNextSerial(t *testing.T) {
+	now := time.Date(2026, 10, 19, 15, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		old   uint32
+		style string
+		want  uint32
+	}{
+		{0, serialDate, 2026101900},
+		{2026101500, serialDate, 2026101900},
+		{2026101900, serialDate, 2026101901},
+		{2026101999, serialDate, 2026102000},
+		{2027010100, serialDate, 2027010101},
+		{0, serialUnix, uint32(now.Unix())},
+		{uint32(now.Unix()) + 10, serialUnix, uint32(now.Unix()) + 11},
+	}
+	for _, tt := range tests {
+		got, err := nextSerial(tt.old, tt.style, now)
+		if err != nil || got != tt.want {
+			t.Errorf("nextSerial(%d, %s) = %d, %v, want %d", tt.old, tt.style, got, err, tt.want)
+		}
+	}
+
+	if _, err := nextSerial(4294967295, serialUnix, now); err == nil {
+		t.Errorf("nextSerial at the largest serial returned nil, want error")
+	}
+}
+
+func TestCollectSOA_Errors(t *testing.T) {
+	for _, content := range []string{
+		"soa:\n  - serial: 1\n",
+		"soa:\n  - zone: example.lan.\n    serial: soon\n",
+		"soa:\n  - zone: example.lan.\n    serial_style: weekly\n",
+		"soa:\n  - zone: example.lan.\n  - zone: EXAMPLE.LAN\n",
+	} {
+		_, root, err := loadInventory(writeInventory(t, content))
+		if err != nil {
+			t.Fatalf("loadInventory returned error: %v", err)
+		}
+		if _, err := collectSOA(root); err == nil {
+			t.Errorf("collectSOA(%q) returned nil, want error", content)
+		}
+	}
+}
+
+func TestChangedZones(t *testing.T) {
+	before := []dnsRecord{
+		{Host: "web01", Type: "A", Zone: "example.lan.", Value: "10.0.1.5", TTL: 300},
+		{Host: "web02", Type: "A", Zone: "example.lan.", Value: "10.0.1.6", TTL: 300},
+		{Host: "db", Type: "A", Zone: "lab.example.lan.", Value: "10.0.2.5", TTL: 300},
+		{Host: "old", Type: "A", Zone: "old.lan.", Value: "10.0.3.5", TTL: 300},
+	}
+	after := []dnsRecord{
+		// reordered, unchanged
+		{Host: "web02", Type: "A", Zone: "example.lan.", Value: "10.0.1.6", TTL: 300},
+		{Host: "web01", Type: "A", Zone: "example.lan.", Value: "10.0.1.5", TTL: 300},
+		// disabled
+		{Host: "db", Type: "A", Zone: "lab.example.lan.", Value: "10.0.2.5", TTL: 300, Disabled: true},
+		// new zone
+		{Host: "5", Type: "PTR", Zone: "1.0.10.in-addr.arpa.", Value: "5", TTL: 300},
+	}
+
+	got := changedZones(before, after)
+	want := []string{"1.0.10.in-addr.arpa.", "lab.example.lan.", "old.lan."}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("changedZones = %v, want %v", got, want)
+	}
+}
+
+const soaFixture = `soa:
+  - zone: example.lan.
+    mname: ns1.example.lan.
+    rname: hostmaster.example.lan.
+    serial: 2020010100 # bumped by dnsctl
+  - zone: 1.0.10.in-addr.arpa.
+    serial_style: unix
+  - zone: other.lan.
+    serial: 7
+dns_records:
+  - host: web01
+    type: A
+    zone: example.lan.
+    record_value: 10.0.1.5
+`
+
+func TestBumpSerials(t *testing.T) {
+	path := writeInventory(t, soaFixture)
+	file, root, err := loadInventory(path)
+	if err != nil {
+		t.Fatalf("loadInventory returned error: %v", err)
+	}
+
+	now := time.Date(2026, 10, 19, 15, 0, 0, 0, time.UTC)
+	bumps, err := bumpSerials(root, []string{"1.0.10.in-addr.arpa.", "example.lan.", "missing.lan."}, now)
+	if err != nil {
+		t.Fatalf("bumpSerials returned error: %v", err)
+	}
+	if len(bumps) != 2 || bumps[0].String() != "example.lan. serial 2020010100 -> 2026101900" {
+		t.Fatalf("bumps = %v", bumps)
+	}
+
+	out := file.String()
+	for _, want := range []string{
+		"    serial: 2026101900 # bumped by dnsctl\n",
+		fmt.Sprintf("    serial_style: unix\n    serial: %d\n", now.Unix()),
+		"    serial: 7\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("inventory is missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestRunRecordAdd_BumpsSerial(t *testing.T) {
+	path := writeInventory(t, soaFixture)
+
+	var out bytes.Buffer
+	err := runRecordAdd(recordOptions{File: path, Host: "web02", Type: "A", Zone: "example.lan.", Value: "10.0.1.6"}, &out)
+	if err != nil {
+		t.Fatalf("runRecordAdd returned error: %v", err)
+	}
+	if !strings.Contains(out.String(), "bumped example.lan. serial 2020010100 -> ") {
+		t.Fatalf("report does not mention the serial bump:\n%s", out.String())
+	}
+	if got := readInventory(t, path); strings.Contains(got, "serial: 2020010100") || !strings.Contains(got, "serial: 7\n") {
+		t.Fatalf("only the serial of example.lan. should change:\n%s", got)
+	}
+}
+
+func TestRunCleanZones_BumpsSerial(t *testing.T) {
+	stubProbe(t, func(ctx context.Context, ip string, timeout time.Duration) bool {
+		return true
+	})
+
+	path := writeInventory(t, soaFixture)
+	out, err := captureStdout(t, func() error {
+		return runCleanZones(cleanOptions{File: path, Timeout: time.Second, Workers: 1, Quiet: true})
+	})
+	if err != nil {
+		t.Fatalf("runCleanZones returned error: %v", err)
+	}
+
+	// the added PTR changes the reverse zone only
+	if !strings.Contains(out, "serial: 2020010100") || !strings.Contains(out, "serial_style: unix\n    serial: ") {
+		t.Fatalf("unexpected serials after clean-zones:\n%s", out)
+	}
+}
+
+// TestExportOmitsSOA tests that no export format renders the soa section: dnsmasq
+// and hosts files cannot serve a zone, and unbound local-data is not authoritative.
+func TestExportOmitsSOA(t *testing.T) {
+	path := writeInventory(t, soaFixture)
+	for _, format := range exportFormats {
+		var out bytes.Buffer
+		if err := runExport(path, format, "", &out); err != nil {
+			t.Fatalf("runExport(%s) returned error: %v", format, err)
+		}
+		if strings.Contains(out.String(), "SOA") || strings.Contains(out.String(), "2020010100") {
+			t.Errorf("%s export renders the soa section:\n%s", format, out.String())
+		}
+	}
+}
+
+func TestSOAValidatedUpFront(t *testing.T) {
+	stubProbe(t, func(ctx context.Context, ip string, timeout time.Duration) bool {
+		t.Errorf("probed %s despite a malformed soa section", ip)
+		return true
+	})
+
+	content := "soa:\n  - zone: example.lan.\n    serial: soon\n" + strings.TrimPrefix(soaFixture, "soa:\n")
+	path := writeInventory(t, content)
+	_, err := captureStdout(t, func() error {
+		return runCleanZones(cleanOptions{File: path, Timeout: time.Second, Workers: 1, Quiet: true})
+	})
+	if err == nil || !strings.Contains(err.Error(), "invalid serial") {
+		t.Fatalf("runCleanZones error = %v, want invalid serial", err)
+	}
+
+	err = runRecordAdd(recordOptions{File: path, Host: "web02", Type: "A", Zone: "example.lan.", Value: "10.0.1.6"}, &bytes.Buffer{})
+	if err == nil || !strings.Contains(err.Error(), "invalid serial") {
+		t.Fatalf("runRecordAdd error = %v, want invalid serial", err)
+	}
+}
@@ -1,7 +1,13 @@
 package cmd
 
 import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
 	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/parser"
 	"github.com/goccy/go-yaml/token"
 )
 
@@ -16,17 +22,24 @@ func mappingValue(m *ast.MappingNode, key string) ast.Node {
 }
 
 // stringValue retrieves the string value associated with the given key from a YAML mapping node.
+// Non-string scalars (e.g. `record_value: 5` or `ttl: 300`) are returned as written.
 func stringValue(m *ast.MappingNode, key string) string {
-	n := mappingValue(m, key)
-	if n == nil {
+	switch n := mappingValue(m, key).(type) {
+	case *ast.StringNode:
+		return n.Value
+	case *ast.NullNode:
 		return ""
+	case ast.ScalarNode:
+		return n.GetToken().Value
 	}
-	return n.(*ast.StringNode).Value
+	return ""
 }
 
 // commentOut adds a DISABLED comment to the given YAML node with an optional reason.
+// For mappings the comment is placed inline on the first value, which is where the
+// parser attaches it when the file is read back. An existing inline comment is kept.
 func commentOut(n ast.Node, reason string) {
-	if n == nil {
+	if n == nil || isDisabled(n) {
 		return
 	}
 
@@ -35,12 +48,305 @@ func commentOut(n ast.Node, reason string) {
 		text = "DISABLED: " + reason
 	}
 
-	n.SetComment(
+	target := n
+	if m, ok := n.(*ast.MappingNode); ok && len(m.Values) > 0 && m.Values[0].Value != nil {
+		target = m.Values[0].Value
+	}
+
+	if existing := target.GetComment(); existing != nil {
+		text += " -" + strings.TrimPrefix(existing.String(), "#")
+	}
+
+	target.SetComment(
 		ast.CommentGroup([]*token.Token{
 			{
 				Type:  token.CommentType,
-				Value: "# " + text,
+				Value: " " + text,
 			},
 		}),
 	)
 }
+
+// uncommentOut removes a DISABLED marker that commentOut added with the given reason,
+// restoring any inline comment it displaced. Markers with a different reason, e.g. ones
+// written by hand, are left alone. It reports whether the marker was removed.
+func uncommentOut(n ast.Node, reason string) bool {
+	if n == nil {
+		return false
+	}
+
+	target := n
+	if m, ok := n.(*ast.MappingNode); ok && len(m.Values) > 0 && m.Values[0].Value != nil {
+		target = m.Values[0].Value
+	}
+
+	c := target.GetComment()
+	if c == nil {
+		return false
+	}
+
+	marker := "DISABLED: " + reason
+	text := strings.TrimSpace(strings.TrimPrefix(c.String(), "#"))
+	if text != marker && !strings.HasPrefix(text, marker+" - ") {
+		return false
+	}
+
+	if rest := strings.TrimPrefix(text, marker); rest != "" {
+		target.SetComment(
+			ast.CommentGroup([]*token.Token{
+				{
+					Type:  token.CommentType,
+					Value: strings.TrimPrefix(rest, " -"),
+				},
+			}),
+		)
+		return true
+	}
+
+	target.SetComment(nil)
+	return true
+}
+
+// isDisabled reports whether a record node carries a DISABLED comment, either on the
+// node itself or inline on one of its mapping values.
+func isDisabled(n ast.Node) bool {
+	if n == nil {
+		return false
+	}
+
+	m, ok := n.(*ast.MappingNode)
+	// nodes built by hand rather than parsed have no BaseNode and carry no comments
+	if ok && m.BaseNode == nil {
+		return false
+	}
+
+	if hasDisabledComment(n.GetComment()) {
+		return true
+	}
+	if !ok {
+		return false
+	}
+
+	for _, mv := range m.Values {
+		if hasDisabledComment(mv.GetComment()) {
+			return true
+		}
+		if mv.Value != nil && hasDisabledComment(mv.Value.GetComment()) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasDisabledComment reports whether the comment group contains a DISABLED marker.
+func hasDisabledComment(c *ast.CommentGroupNode) bool {
+	if c == nil {
+		return false
+	}
+	return strings.Contains(c.String(), "DISABLED")
+}
+
+// keepKey marks a record or nameserver that clean-zones must never disable.
+const keepKey = "dnsctl_keep"
+
+// keepComment is the comment form of keepKey, written inline or on the line above the entry.
+const keepComment = "dnsctl:keep"
+
+// isKept reports whether a record node is marked with keepKey: true or an inline keepComment.
+func isKept(n ast.Node) bool {
+	m, ok := n.(*ast.MappingNode)
+	if !ok {
+		return false
+	}
+	if b, ok := mappingValue(m, keepKey).(*ast.BoolNode); ok && b.Value {
+		return true
+	}
+
+	// nodes built by hand rather than parsed have no BaseNode and carry no comments
+	if m.BaseNode == nil {
+		return false
+	}
+	if hasKeepComment(m.GetComment()) {
+		return true
+	}
+	for _, mv := range m.Values {
+		if hasKeepComment(mv.GetComment()) || (mv.Value != nil && hasKeepComment(mv.Value.GetComment())) {
+			return true
+		}
+	}
+	return false
+}
+
+// isKeptItem is isKept for item i of seq, also honoring a keepComment on the line above
+// the item. The parser attaches the head comment of the first item to the sequence itself.
+func isKeptItem(seq *ast.SequenceNode, i int) bool {
+	if isKept(seq.Values[i]) {
+		return true
+	}
+	if i < len(seq.ValueHeadComments) && hasKeepComment(seq.ValueHeadComments[i]) {
+		return true
+	}
+	return i == 0 && seq.BaseNode != nil && hasKeepComment(seq.Comment)
+}
+
+// hasKeepComment reports whether the comment group contains keepComment.
+func hasKeepComment(c *ast.CommentGroupNode) bool {
+	return c != nil && strings.Contains(c.String(), keepComment)
+}
+
+// markKept sets keepKey: true on a record node.
+func markKept(n ast.Node) error {
+	m, ok := n.(*ast.MappingNode)
+	if !ok {
+		return fmt.Errorf("cannot mark %s as kept: not a mapping", n.GetToken().Value)
+	}
+	return setMappingValue(m, keepKey, "true")
+}
+
+// plainScalar matches values that can be written as unquoted YAML scalars.
+var plainScalar = regexp.MustCompile(`^[A-Za-z0-9._/-][A-Za-z0-9._/:@-]*$`)
+
+// quoteScalar renders v as a YAML scalar, quoting it only when required.
+func quoteScalar(v string) string {
+	if plainScalar.MatchString(v) {
+		return v
+	}
+	return strconv.Quote(v)
+}
+
+// appendMapping appends a new mapping built from fields to the top-level sequence named
+// section, creating the section if it does not exist. The node is produced by the parser
+// so that it carries the token positions needed to render the file back to text.
+func appendMapping(root *ast.MappingNode, section string, fields [][2]string) (*ast.MappingNode, error) {
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("appendMapping: no fields")
+	}
+
+	var item strings.Builder
+	for i, f := range fields {
+		prefix := "  "
+		if i == 0 {
+			prefix = "- "
+		}
+		fmt.Fprintf(&item, "%s%s: %s\n", prefix, f[0], quoteScalar(f[1]))
+	}
+
+	existing := mappingValue(root, section)
+	// an empty sequence such as "dns_records: []" is replaced by a block sequence
+	if seq, ok := existing.(*ast.SequenceNode); ok && len(seq.Values) == 0 {
+		existing = nil
+	}
+	if existing == nil {
+		if root.IsFlowStyle {
+			return nil, fmt.Errorf("cannot add %s to a flow-style mapping", section)
+		}
+
+		var src strings.Builder
+		fmt.Fprintf(&src, "%s:\n", section)
+		for _, line := range strings.SplitAfter(strings.TrimSuffix(item.String(), "\n"), "\n") {
+			src.WriteString("  " + line)
+		}
+		src.WriteString("\n")
+
+		m, err := parseMapping(src.String())
+		if err != nil {
+			return nil, err
+		}
+		if root.Start == nil {
+			root.Values = append(root.Values, m.Values...)
+		} else {
+			// Merge replaces the value of a key that already exists
+			root.Merge(m)
+		}
+
+		seq := mappingValue(root, section).(*ast.SequenceNode)
+		return seq.Values[0].(*ast.MappingNode), nil
+	}
+
+	seq, ok := existing.(*ast.SequenceNode)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a sequence", section)
+	}
+
+	f, err := parser.ParseBytes([]byte(item.String()), parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	add := f.Docs[0].Body.(*ast.SequenceNode)
+
+	if seq.Start == nil {
+		seq.Values = append(seq.Values, add.Values...)
+	} else {
+		seq.Merge(add)
+	}
+
+	return add.Values[0].(*ast.MappingNode), nil
+}
+
+// parseMapping parses src and returns its top-level mapping node.
+func parseMapping(src string) (*ast.MappingNode, error) {
+	f, err := parser.ParseBytes([]byte(src), parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := f.Docs[0].Body.(*ast.MappingNode)
+	if !ok {
+		return nil, fmt.Errorf("expected a YAML mapping")
+	}
+	return m, nil
+}
+
+// setMappingValue sets key to the scalar value in m, adding the key at the end of the
+// mapping if it is missing. An inline comment on the replaced value, such as a DISABLED
+// marker, is kept.
+func setMappingValue(m *ast.MappingNode, key, value string) error {
+	add, err := parseMapping(fmt.Sprintf("%s: %s\n", key, quoteScalar(value)))
+	if err != nil {
+		return err
+	}
+
+	for _, mv := range m.Values {
+		if mv.Key.(*ast.StringNode).Value != key {
+			continue
+		}
+
+		v := add.Values[0].Value
+		if old := mv.Value.GetToken(); old != nil {
+			v.AddColumn(old.Position.Column - v.GetToken().Position.Column)
+		}
+		if c := mv.Value.GetComment(); c != nil {
+			v.SetComment(c)
+		}
+		mv.Value = v
+		return nil
+	}
+
+	if m.Start == nil {
+		m.Values = append(m.Values, add.Values...)
+		return nil
+	}
+	m.Merge(add)
+	return nil
+}
+
+// removeSequenceItem deletes the item at idx from seq together with the comment lines
+// written above it. The parser attaches the comment above the first item to the
+// sequence itself.
+func removeSequenceItem(seq *ast.SequenceNode, idx int) {
+	if idx == 0 {
+		seq.Comment = nil
+	}
+	if len(seq.ValueHeadComments) == len(seq.Values) {
+		seq.ValueHeadComments = append(seq.ValueHeadComments[:idx], seq.ValueHeadComments[idx+1:]...)
+	}
+	if len(seq.Entries) == len(seq.Values) {
+		seq.Entries = append(seq.Entries[:idx], seq.Entries[idx+1:]...)
+	}
+	seq.Values = append(seq.Values[:idx], seq.Values[idx+1:]...)
+
+	// an empty block sequence has no text form; render it as an indented []
+	if len(seq.Values) == 0 {
+		seq.IsFlowStyle = true
+	}
+}
@@ -1,6 +1,8 @@
 package cmd
 
 import (
+	"os"
+	"strings"
 	"testing"
 
 	"github.com/goccy/go-yaml/ast"
@@ -154,3 +156,332 @@ func TestCommentOut_NoopWithBasicNode(t *testing.T) {
 		})
 	}
 }
+
+// TestIsDisabled_InlineComment tests detection of DISABLED markers in parsed YAML.
+func TestIsDisabled_InlineComment(t *testing.T) {
+	path := writeInventory(t, `dns_records:
+  - host: web01
+    record_value: 10.0.1.5 # DISABLED: unreachable
+  - host: web02
+    record_value: 10.0.1.6 # owned by ops
+`)
+
+	_, root, err := loadInventory(path)
+	if err != nil {
+		t.Fatalf("loadInventory returned error: %v", err)
+	}
+
+	seq := mappingValue(root, "dns_records").(*ast.SequenceNode)
+	if !isDisabled(seq.Values[0]) {
+		t.Errorf("isDisabled(web01) = false, want true")
+	}
+	if isDisabled(seq.Values[1]) {
+		t.Errorf("isDisabled(web02) = true, want false")
+	}
+	if isDisabled(nil) {
+		t.Errorf("isDisabled(nil) = true, want false")
+	}
+}
+
+// TestStringValue_NonStringScalar tests that integer scalars are returned as written.
+func TestStringValue_NonStringScalar(t *testing.T) {
+	path := writeInventory(t, "record_value: 5\nttl: 300\n")
+
+	_, root, err := loadInventory(path)
+	if err != nil {
+		t.Fatalf("loadInventory returned error: %v", err)
+	}
+
+	if got := stringValue(root, "record_value"); got != "5" {
+		t.Errorf("stringValue(record_value) = %q, want 5", got)
+	}
+	if got := stringValue(root, "ttl"); got != "300" {
+		t.Errorf("stringValue(ttl) = %q, want 300", got)
+	}
+}
+
+// TestQuoteScalar tests that only values needing it are quoted.
+func TestQuoteScalar(t *testing.T) {
+	cases := map[string]string{
+		"10.0.1.5":      "10.0.1.5",
+		"2001:db8::1":   "2001:db8::1",
+		"web01.lan.":    "web01.lan.",
+		"@":             `"@"`,
+		"v=spf1 -all":   `"v=spf1 -all"`,
+		"":              `""`,
+		"10 mail.lan.":  `"10 mail.lan."`,
+		"key: value #x": `"key: value #x"`,
+	}
+
+	for in, want := range cases {
+		if got := quoteScalar(in); got != want {
+			t.Errorf("quoteScalar(%q) = %s, want %s", in, got, want)
+		}
+	}
+}
+
+// TestAppendMapping_ExistingAndNewSection tests that appended nodes render and re-parse.
+func TestAppendMapping_ExistingAndNewSection(t *testing.T) {
+	path := writeInventory(t, `# header
+dns_records:
+  - host: web01 # primary
+    type: A
+`)
+
+	file, root, err := loadInventory(path)
+	if err != nil {
+		t.Fatalf("loadInventory returned error: %v", err)
+	}
+
+	if _, err := appendMapping(root, "dns_records", [][2]string{{"host", "web02"}, {"type", "A"}}); err != nil {
+		t.Fatalf("appendMapping(existing) returned error: %v", err)
+	}
+	if _, err := appendMapping(root, "sub_zone_records", [][2]string{{"host", "db"}, {"type", "TXT"}, {"record_value", "a b"}}); err != nil {
+		t.Fatalf("appendMapping(new) returned error: %v", err)
+	}
+	if err := saveInventory(path, file); err != nil {
+		t.Fatalf("saveInventory returned error: %v", err)
+	}
+
+	_, root, err = loadInventory(path)
+	if err != nil {
+		t.Fatalf("rendered inventory does not parse: %v", err)
+	}
+
+	records := collectRecords(root)
+	if len(records) != 3 || records[1].Host != "web02" || records[2].Value != "a b" {
+		t.Fatalf("unexpected records after append: %+v", records)
+	}
+
+	if _, err := appendMapping(root, "dns_records", nil); err == nil {
+		t.Fatalf("appendMapping with no fields returned nil, want error")
+	}
+}
+
+// TestCommentOut_RoundTrip tests that a DISABLED marker survives rendering and re-parsing.
+func TestCommentOut_RoundTrip(t *testing.T) {
+	path := writeInventory(t, `dns_records:
+  - host: web01 # owned by ops
+    type: A
+  - host: web02
+    type: A
+`)
+
+	file, root, err := loadInventory(path)
+	if err != nil {
+		t.Fatalf("loadInventory returned error: %v", err)
+	}
+
+	seq := mappingValue(root, "dns_records").(*ast.SequenceNode)
+	commentOut(seq.Values[0], "unreachable")
+	commentOut(seq.Values[1], "unreachable")
+	commentOut(seq.Values[1], "again")
+
+	if err := saveInventory(path, file); err != nil {
+		t.Fatalf("saveInventory returned error: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	for _, want := range []string{
+		"host: web01 # DISABLED: unreachable - owned by ops",
+		"host: web02 # DISABLED: unreachable\n",
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("rendered YAML missing %q:\n%s", want, data)
+		}
+	}
+
+	_, root, err = loadInventory(path)
+	if err != nil {
+		t.Fatalf("rendered YAML does not parse: %v", err)
+	}
+	for _, r := range collectRecords(root) {
+		if !r.Disabled {
+			t.Fatalf("record %s lost its DISABLED marker after round trip", r.Host)
+		}
+	}
+}
+
+func TestUncommentOut(t *testing.T) {
+	path := writeInventory(t, `dns_records:
+  - host: a # DISABLED: unreachable - owned by ops
+    type: A
+  - host: b # DISABLED: unreachable
+    type: A
+  - host: c # DISABLED: retired
+    type: A
+  - host: d
+    type: A
+`)
+
+	file, root, err := loadInventory(path)
+	if err != nil {
+		t.Fatalf("loadInventory returned error: %v", err)
+	}
+
+	want := map[string]bool{"a": true, "b": true, "c": false, "d": false}
+	for _, r := range collectRecords(root) {
+		if got := uncommentOut(r.Node, "unreachable"); got != want[r.Host] {
+			t.Errorf("uncommentOut(%s) = %v, want %v", r.Host, got, want[r.Host])
+		}
+	}
+
+	if err := saveInventory(path, file); err != nil {
+		t.Fatalf("saveInventory returned error: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), "host: a # owned by ops\n") || !strings.Contains(string(data), "host: b\n") {
+		t.Fatalf("unexpected YAML after uncommentOut:\n%s", data)
+	}
+
+	_, root, _ = loadInventory(path)
+	for _, r := range collectRecords(root) {
+		if r.Disabled != (r.Host == "c") {
+			t.Errorf("record %s Disabled = %v after round trip", r.Host, r.Disabled)
+		}
+	}
+}
+
+// TestSetMappingValue tests replacing and adding scalar keys while keeping inline comments.
+func TestSetMappingValue(t *testing.T) {
+	path := writeInventory(t, `dns_records:
+  - host: web01 # DISABLED: unreachable
+    type: A
+    record_value: 10.0.1.5 # primary
+`)
+
+	file, root, err := loadInventory(path)
+	if err != nil {
+		t.Fatalf("loadInventory returned error: %v", err)
+	}
+	m := mappingValue(root, "dns_records").(*ast.SequenceNode).Values[0].(*ast.MappingNode)
+
+	if err := setMappingValue(m, "record_value", "10.0.1.9"); err != nil {
+		t.Fatalf("setMappingValue(existing) returned error: %v", err)
+	}
+	if err := setMappingValue(m, "ttl", "300"); err != nil {
+		t.Fatalf("setMappingValue(new) returned error: %v", err)
+	}
+
+	want := `dns_records:
+  - host: web01 # DISABLED: unreachable
+    type: A
+    record_value: 10.0.1.9 # primary
+    ttl: 300
+`
+	if got := file.String(); got != want {
+		t.Fatalf("rendered inventory =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestMarkKept(t *testing.T) {
+	path := writeInventory(t, `dns_records:
+  - host: web01
+    type: A
+    record_value: 10.0.1.5
+  - host: vip
+    type: A
+    record_value: 10.0.1.9
+    dnsctl_keep: true
+`)
+
+	file, root, err := loadInventory(path)
+	if err != nil {
+		t.Fatalf("loadInventory returned error: %v", err)
+	}
+	items := mappingValue(root, "dns_records").(*ast.SequenceNode).Values
+	if isKept(items[0]) || !isKept(items[1]) {
+		t.Fatalf("isKept = %v, %v, want false, true", isKept(items[0]), isKept(items[1]))
+	}
+
+	if err := markKept(items[0]); err != nil {
+		t.Fatalf("markKept returned error: %v", err)
+	}
+	if !isKept(items[0]) || !strings.Contains(file.String(), "record_value: 10.0.1.5\n    dnsctl_keep: true\n  - host: vip") {
+		t.Fatalf("markKept did not add the key:\n%s", file.String())
+	}
+}
+
+func TestIsKeptItem(t *testing.T) {
+	path := writeInventory(t, `dns_records:
+  # dnsctl:keep
+  - host: vip01
+    type: A
+    record_value: 10.0.1.1
+  - host: web01
+    type: A
+    record_value: 10.0.1.5
+  # VIP, dnsctl:keep
+  - host: vip02
+    type: A
+    record_value: 10.0.1.2
+  - host: vip03 # dnsctl:keep
+    type: A
+    record_value: 10.0.1.3
+  - host: vip04
+    type: A
+    record_value: 10.0.1.4
+    dnsctl_keep: true
+  - host: web02
+    type: A
+    record_value: 10.0.1.6
+    dnsctl_keep: false
+`)
+
+	_, root, err := loadInventory(path)
+	if err != nil {
+		t.Fatalf("loadInventory returned error: %v", err)
+	}
+	seq := mappingValue(root, "dns_records").(*ast.SequenceNode)
+
+	want := []bool{true, false, true, true, true, false}
+	for i := range seq.Values {
+		if got := isKeptItem(seq, i); got != want[i] {
+			t.Errorf("isKeptItem(%d) = %v, want %v", i, got, want[i])
+		}
+	}
+}
+
+// TestRemoveSequenceItem tests that removed items take their head comments with them.
+func TestRemoveSequenceItem(t *testing.T) {
+	path := writeInventory(t, `dns_records:
+  # web
+  - host: web01
+  # db
+  - host: db01
+  # mail
+  - host: mail01
+nameservers: []
+`)
+
+	file, root, err := loadInventory(path)
+	if err != nil {
+		t.Fatalf("loadInventory returned error: %v", err)
+	}
+	seq := mappingValue(root, "dns_records").(*ast.SequenceNode)
+
+	removeSequenceItem(seq, 1)
+	removeSequenceItem(seq, 0)
+
+	want := `dns_records:
+  # mail
+  - host: mail01
+nameservers: []
+`
+	if got := file.String(); got != want {
+		t.Fatalf("rendered inventory =\n%s\nwant\n%s", got, want)
+	}
+
+	removeSequenceItem(seq, 0)
+	if err := saveInventory(path, file); err != nil {
+		t.Fatalf("saveInventory returned error: %v", err)
+	}
+	if _, root, err = loadInventory(path); err != nil {
+		t.Fatalf("inventory with an emptied section does not parse: %v", err)
+	}
+	if _, err := appendMapping(root, "dns_records", [][2]string{{"host", "web02"}}); err != nil {
+		t.Fatalf("appendMapping to emptied section returned error: %v", err)
+	}
+	if records := collectRecords(root); len(records) != 1 || records[0].Host != "web02" {
+		t.Fatalf("unexpected records after re-adding: %+v", records)
+	}
+}
@@ -20,13 +20,62 @@ _dnsctl() {
 
   case "${COMP_WORDS[1]}" in
     clean-zones)
-      COMPREPLY=( $(compgen -W "--file --timeout --workers --dry-run" -- "$cur") )
+      COMPREPLY=( $(compgen -W "--file --timeout --workers --dry-run --allow-partial --max-duration --rate --per-subnet --check --arp-request --quiet --cache-ttl --no-cache --notify-webhook --notify-slack --notify-command --fail-on --interactive --git-commit --git-branch" -- "$cur") )
+      ;;
+    plan|apply)
+      COMPREPLY=( $(compgen -W "--file --provider --server --zone --tsig-name --tsig-secret --tsig-algorithm --api-url --api-key --server-id --timeout" -- "$cur") )
+      ;;
+    drift|pull)
+      COMPREPLY=( $(compgen -W "--file --server --zone --tsig-name --tsig-secret --tsig-algorithm --timeout --ixfr" -- "$cur") )
+      ;;
+    export)
+      if [[ "$prev" == "--format" ]]; then
+        COMPREPLY=( $(compgen -W "dnsmasq unbound hosts" -- "$cur") )
+      else
+        COMPREPLY=( $(compgen -W "--file --format --output" -- "$cur") )
+      fi
+      ;;
+    monitor)
+      COMPREPLY=( $(compgen -W "--file --interval --listen --timeout --workers --rate --per-subnet --check --arp-request" -- "$cur") )
+      ;;
+    query)
+      case "$prev" in
+        --format) COMPREPLY=( $(compgen -W "table json csv" -- "$cur") ) ;;
+        --state) COMPREPLY=( $(compgen -W "active disabled" -- "$cur") ) ;;
+        --type) COMPREPLY=( $(compgen -W "A AAAA CNAME MX TXT PTR SRV NS" -- "$cur") ) ;;
+        *) COMPREPLY=( $(compgen -W "--file --host --ip --cidr --zone --type --state --format" -- "$cur") ) ;;
+      esac
+      ;;
+    record)
+      if [[ "$COMP_CWORD" -eq 2 ]]; then
+        COMPREPLY=( $(compgen -W "add rm set" -- "$cur") )
+      elif [[ "$prev" == "--type" ]]; then
+        COMPREPLY=( $(compgen -W "A AAAA CNAME MX TXT PTR SRV NS" -- "$cur") )
+      else
+        COMPREPLY=( $(compgen -W "--file --host --type --zone --value --ttl --ptr" -- "$cur") )
+      fi
+      ;;
+    ipam)
+      if [[ "$COMP_CWORD" -eq 2 ]]; then
+        COMPREPLY=( $(compgen -W "next report" -- "$cur") )
+      elif [[ "${COMP_WORDS[2]}" == "report" ]]; then
+        COMPREPLY=( $(compgen -W "--file --subnet --format" -- "$cur") )
+      else
+        COMPREPLY=( $(compgen -W "--file --cidr --count --reserve --reuse-disabled --host --zone --ttl --ptr" -- "$cur") )
+      fi
+      ;;
+    config)
+      if [[ "$COMP_CWORD" -eq 2 ]]; then
+        COMPREPLY=( $(compgen -W "show" -- "$cur") )
+      else
+        COMPREPLY=( $(compgen -W "clean-zones plan apply drift pull export monitor query record ipam" -- "$cur") )
+      fi
       ;;
     completion)
       COMPREPLY=( $(compgen -W "bash zsh" -- "$cur") )
       ;;
     *)
-      COMPREPLY=( $(compgen -W "clean-zones completion" -- "$cur") )
+      COMPREPLY=( $(compgen -W "clean-zones plan apply drift pull export monitor query record ipam config completion --profile --log-level --log-format" -- "$cur") )
       ;;
   esac
 }